@@ -0,0 +1,194 @@
+package main
+
+// проверка целостности цепочки событий аудита
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"usekit-auth/internal/audit"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/storage/sqlite"
+)
+
+const batchSize = 1000
+
+func main() {
+	var storagePath, publicKey string
+	var keygen bool
+	flag.StringVar(&storagePath, "storage-path", "", "Path to the storage")
+	flag.StringVar(&publicKey, "public-key", "", "Base64 Ed25519 public key to verify checkpoint signatures")
+	flag.BoolVar(&keygen, "keygen", false, "Generate a checkpoint signing key and print it with its public key")
+	flag.Parse()
+
+	if keygen {
+		printNewKey()
+		return
+	}
+
+	if storagePath == "" {
+		panic("storage-path is required")
+	}
+
+	var pub ed25519.PublicKey
+	if publicKey != "" {
+		raw, err := base64.StdEncoding.DecodeString(publicKey)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			panic("public-key must be a base64 Ed25519 public key")
+		}
+		pub = raw
+	}
+
+	storage, err := sqlite.New(storagePath)
+	if err != nil {
+		panic(err)
+	}
+
+	report, err := verify(context.Background(), storage, pub)
+	if err != nil {
+		panic(err)
+	}
+
+	writeReport(os.Stdout, report, pub != nil)
+	if report.broken != "" {
+		os.Exit(1)
+	}
+}
+
+type report struct {
+	unchained   int
+	chained     int
+	checkpoints int
+	// broken describes the first broken link, empty if the chain is intact
+	broken string
+}
+
+type chainReader interface {
+	AuditEventsAfter(ctx context.Context, afterId int64, limit int) ([]models.AuditEvent, error)
+	AuditCheckpoints(ctx context.Context) ([]models.AuditCheckpoint, error)
+}
+
+func verify(ctx context.Context, reader chainReader, pub ed25519.PublicKey) (report, error) {
+	var r report
+
+	checkpoints, err := reader.AuditCheckpoints(ctx)
+	if err != nil {
+		return r, err
+	}
+
+	checkpointsAt := make(map[int64][]models.AuditCheckpoint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		if pub != nil && !audit.VerifyCheckpoint(pub, checkpoint) {
+			r.broken = fmt.Sprintf("checkpoint %d has an invalid signature", checkpoint.Id)
+			return r, nil
+		}
+		checkpointsAt[checkpoint.EventId] = append(checkpointsAt[checkpoint.EventId], checkpoint)
+	}
+
+	var lastId int64
+	var prevHash string
+	started := false
+
+	for {
+		events, err := reader.AuditEventsAfter(ctx, lastId, batchSize)
+		if err != nil {
+			return r, err
+		}
+
+		for _, event := range events {
+			if broken := checkLink(event, lastId, prevHash, started); broken != "" {
+				r.broken = broken
+				return r, nil
+			}
+
+			if event.Hash == "" {
+				r.unchained++
+			} else {
+				started = true
+				r.chained++
+				for _, checkpoint := range checkpointsAt[event.Id] {
+					if checkpoint.Hash != event.Hash {
+						r.broken = fmt.Sprintf("event %d does not match the hash signed by checkpoint %d", event.Id, checkpoint.Id)
+						return r, nil
+					}
+					r.checkpoints++
+				}
+				delete(checkpointsAt, event.Id)
+			}
+
+			lastId = event.Id
+			prevHash = event.Hash
+		}
+
+		if len(events) < batchSize {
+			break
+		}
+	}
+
+	// checkpoints left over point past the end of the chain
+	for eventId, list := range checkpointsAt {
+		r.broken = fmt.Sprintf("checkpoint %d covers event %d which is missing, the chain was truncated", list[0].Id, eventId)
+		return r, nil
+	}
+
+	return r, nil
+}
+
+// writeReport prints the summary, checkpoints are only reported as verified when their signatures were checked
+func writeReport(w io.Writer, r report, signaturesChecked bool) {
+	fmt.Fprintf(w, "unchained events (written before the chain was enabled): %d\n", r.unchained)
+	fmt.Fprintf(w, "chained events verified: %d\n", r.chained)
+	if signaturesChecked {
+		fmt.Fprintf(w, "checkpoints verified: %d\n", r.checkpoints)
+	} else {
+		fmt.Fprintf(w, "checkpoints: %d skipped (no key)\n", r.checkpoints)
+		fmt.Fprintln(w, "warning: checkpoint signatures were not verified, pass -public-key")
+	}
+
+	if r.broken != "" {
+		fmt.Fprintf(w, "BROKEN: %s\n", r.broken)
+		return
+	}
+	fmt.Fprintln(w, "audit chain is intact")
+}
+
+// checkLink describes why event does not continue the chain after the event with prevId and prevHash
+func checkLink(event models.AuditEvent, prevId int64, prevHash string, started bool) string {
+	if event.Hash == "" {
+		if started {
+			return fmt.Sprintf("event %d has no hash although the chain started before it", event.Id)
+		}
+		return ""
+	}
+
+	if started && event.Id != prevId+1 {
+		return fmt.Sprintf("events %d to %d are missing before event %d", prevId+1, event.Id-1, event.Id)
+	}
+	if event.PrevHash != prevHash {
+		return fmt.Sprintf("event %d does not link to event %d, an event was removed or rewritten", event.Id, prevId)
+	}
+	if audit.ChainHash(event.PrevHash, event) != event.Hash {
+		return fmt.Sprintf("event %d was modified after it was written", event.Id)
+	}
+	// the client is not covered by the hash directly, it is checked against the digest until it is erased
+	if event.ClientSalt != nil && audit.ClientDigest(event.ClientSalt, event.IP, event.UserAgent) != event.ClientDigest {
+		return fmt.Sprintf("client of event %d was modified after it was written", event.Id)
+	}
+
+	return ""
+}
+
+func printNewKey() {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("checkpoint_key (keep secret): %s\n", base64.StdEncoding.EncodeToString(key.Seed()))
+	fmt.Printf("public key (for auditverify): %s\n", base64.StdEncoding.EncodeToString(pub))
+}
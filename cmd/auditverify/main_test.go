@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"usekit-auth/internal/audit"
+	"usekit-auth/internal/domain/models"
+)
+
+type fakeChain struct {
+	events      []models.AuditEvent
+	checkpoints []models.AuditCheckpoint
+}
+
+func (c *fakeChain) AuditEventsAfter(_ context.Context, afterId int64, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	for _, event := range c.events {
+		if event.Id > afterId && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (c *fakeChain) AuditCheckpoints(context.Context) ([]models.AuditCheckpoint, error) {
+	return c.checkpoints, nil
+}
+
+// newChain returns unchained events followed by chained ones, as written by the storage
+func newChain(unchained, chained int) []models.AuditEvent {
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var events []models.AuditEvent
+	var prevHash string
+	for i := 1; i <= unchained+chained; i++ {
+		event := models.AuditEvent{
+			Id:        int64(i),
+			Type:      models.AuditLogin,
+			Outcome:   models.AuditSuccess,
+			ActorId:   int64(i),
+			SubjectId: int64(i),
+			AppId:     1,
+			IP:        "192.0.2.1",
+			UserAgent: "grpc-go/1.67.1",
+			CreatedAt: createdAt.Add(time.Duration(i) * time.Second),
+		}
+		if i > unchained {
+			// events with even ids keep the client apart from the chain, as written since migration 14
+			if i%2 == 0 {
+				event.ClientSalt = []byte{byte(i)}
+				event.ClientDigest = audit.ClientDigest(event.ClientSalt, event.IP, event.UserAgent)
+			}
+			event.PrevHash = prevHash
+			event.Hash = audit.ChainHash(prevHash, event)
+			prevHash = event.Hash
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func checkpointAt(key ed25519.PrivateKey, id int64, event models.AuditEvent) models.AuditCheckpoint {
+	return audit.SignCheckpoint(key, models.AuditCheckpoint{
+		Id:        id,
+		EventId:   event.Id,
+		Hash:      event.Hash,
+		CreatedAt: event.CreatedAt,
+	})
+}
+
+func TestVerify(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		chain  func() *fakeChain
+		pub    ed25519.PublicKey
+		broken string
+		want   report
+	}{
+		{
+			name: "intact",
+			chain: func() *fakeChain {
+				events := newChain(2, 5)
+				return &fakeChain{events: events, checkpoints: []models.AuditCheckpoint{
+					checkpointAt(key, 1, events[3]),
+					checkpointAt(key, 2, events[6]),
+				}}
+			},
+			pub:  pub,
+			want: report{unchained: 2, chained: 5, checkpoints: 2},
+		},
+		{
+			name:  "empty",
+			chain: func() *fakeChain { return &fakeChain{} },
+			pub:   pub,
+		},
+		{
+			name: "longer than a batch",
+			chain: func() *fakeChain {
+				return &fakeChain{events: newChain(0, batchSize+3)}
+			},
+			want: report{chained: batchSize + 3},
+		},
+		{
+			name: "field modified",
+			chain: func() *fakeChain {
+				events := newChain(0, 5)
+				events[2].Outcome = models.AuditFailure
+				return &fakeChain{events: events}
+			},
+			broken: "event 3 was modified after it was written",
+		},
+		{
+			name: "field modified and hash recomputed",
+			chain: func() *fakeChain {
+				events := newChain(0, 5)
+				events[2].IP = "198.51.100.7"
+				events[2].Hash = audit.ChainHash(events[2].PrevHash, events[2])
+				return &fakeChain{events: events}
+			},
+			broken: "event 4 does not link to event 3, an event was removed or rewritten",
+		},
+		{
+			name: "client erased",
+			chain: func() *fakeChain {
+				events := newChain(0, 5)
+				events[3].IP, events[3].UserAgent, events[3].ClientSalt = "", "", nil
+				return &fakeChain{events: events}
+			},
+			want: report{chained: 5},
+		},
+		{
+			name: "client modified",
+			chain: func() *fakeChain {
+				events := newChain(0, 5)
+				events[3].IP = "198.51.100.7"
+				return &fakeChain{events: events}
+			},
+			broken: "client of event 4 was modified after it was written",
+		},
+		{
+			name: "events reordered",
+			chain: func() *fakeChain {
+				events := newChain(0, 5)
+				// the rows keep their ids, their contents are swapped
+				events[1], events[2] = events[2], events[1]
+				events[1].Id, events[2].Id = 2, 3
+				return &fakeChain{events: events}
+			},
+			broken: "event 2 does not link to event 1, an event was removed or rewritten",
+		},
+		{
+			name: "event deleted",
+			chain: func() *fakeChain {
+				events := newChain(0, 5)
+				return &fakeChain{events: append(events[:2:2], events[3:]...)}
+			},
+			broken: "events 3 to 3 are missing before event 4",
+		},
+		{
+			name: "event deleted and ids shifted",
+			chain: func() *fakeChain {
+				events := newChain(0, 5)
+				events = append(events[:2:2], events[3:]...)
+				for i := range events {
+					events[i].Id = int64(i + 1)
+				}
+				return &fakeChain{events: events}
+			},
+			broken: "event 3 does not link to event 2, an event was removed or rewritten",
+		},
+		{
+			name: "first chained event deleted",
+			chain: func() *fakeChain {
+				events := newChain(2, 3)
+				return &fakeChain{events: append(events[:2:2], events[3:]...)}
+			},
+			broken: "event 4 does not link to event 2, an event was removed or rewritten",
+		},
+		{
+			name: "hash removed after the chain started",
+			chain: func() *fakeChain {
+				events := newChain(0, 5)
+				events[3].Hash, events[3].PrevHash = "", ""
+				return &fakeChain{events: events}
+			},
+			broken: "event 4 has no hash although the chain started before it",
+		},
+		{
+			name: "tail deleted behind a checkpoint",
+			chain: func() *fakeChain {
+				events := newChain(0, 5)
+				return &fakeChain{
+					events:      events[:3],
+					checkpoints: []models.AuditCheckpoint{checkpointAt(key, 1, events[4])},
+				}
+			},
+			pub:    pub,
+			broken: "checkpoint 1 covers event 5 which is missing, the chain was truncated",
+		},
+		{
+			name: "chain rewritten after a checkpoint",
+			chain: func() *fakeChain {
+				events := newChain(0, 3)
+				checkpoint := checkpointAt(key, 1, events[2])
+				events[2].Reason = "rewritten"
+				events[2].Hash = audit.ChainHash(events[2].PrevHash, events[2])
+				return &fakeChain{events: events, checkpoints: []models.AuditCheckpoint{checkpoint}}
+			},
+			pub:    pub,
+			broken: "event 3 does not match the hash signed by checkpoint 1",
+		},
+		{
+			name: "checkpoint signed by another key",
+			chain: func() *fakeChain {
+				events := newChain(0, 3)
+				return &fakeChain{events: events, checkpoints: []models.AuditCheckpoint{checkpointAt(key, 1, events[2])}}
+			},
+			pub:    otherPub,
+			broken: "checkpoint 1 has an invalid signature",
+		},
+		{
+			name: "forged checkpoint without key",
+			chain: func() *fakeChain {
+				events := newChain(0, 3)
+				checkpoint := checkpointAt(key, 1, events[2])
+				checkpoint.Signature = []byte("forged")
+				return &fakeChain{events: events, checkpoints: []models.AuditCheckpoint{checkpoint}}
+			},
+			want: report{chained: 3, checkpoints: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verify(context.Background(), tt.chain(), tt.pub)
+			require.NoError(t, err)
+			require.Equal(t, tt.broken, got.broken)
+			if tt.broken == "" {
+				require.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	r := report{unchained: 1, chained: 4, checkpoints: 2}
+
+	var withKey bytes.Buffer
+	writeReport(&withKey, r, true)
+	require.Contains(t, withKey.String(), "checkpoints verified: 2\n")
+	require.NotContains(t, withKey.String(), "skipped")
+	require.Contains(t, withKey.String(), "audit chain is intact\n")
+
+	var withoutKey bytes.Buffer
+	writeReport(&withoutKey, r, false)
+	require.Contains(t, withoutKey.String(), "checkpoints: 2 skipped (no key)\n")
+	require.NotContains(t, withoutKey.String(), "verified: 2")
+	require.Contains(t, withoutKey.String(), "audit chain is intact\n")
+
+	var broken bytes.Buffer
+	r.broken = "event 3 was modified after it was written"
+	writeReport(&broken, r, true)
+	require.Contains(t, broken.String(), "BROKEN: event 3 was modified after it was written\n")
+	require.NotContains(t, broken.String(), "intact")
+}
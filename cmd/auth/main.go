@@ -1,7 +1,7 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -19,32 +19,44 @@ const (
 func main() {
 	// TODO: инициализировать объект конфига
 	cfg := config.MustLoad()
-	fmt.Println(cfg)
 
 	// TODO: инициализировать логгер
 	logger := setupLogger(cfg.Env)
-	fmt.Println(logger)
-	logger.Info("starting application", slog.String("env", cfg.Env))
+	logger.Info("starting application", slog.String("env", cfg.Env), slog.Any("config", cfg))
 
 	// TODO: инициализировать приложение (app)
-	application := app.New(logger, cfg.GRPC.Port, cfg.StoragePath, cfg.TokenTTL)
+	application := app.New(logger, cfg.GRPC, cfg.HTTP.Port, cfg.Gateway.Port, cfg.StoragePath, cfg.TokenTTL, cfg.UserErasure, cfg.Audit, cfg.Password, cfg.Login, cfg.Tracing)
 
 	// TODO: запустить grpc-сервер приложения
-	go application.GrpcServer.MustRun()
+	errs := application.Start()
 
 	// Graceful shutdown
 	stop := make(chan os.Signal, 1)
 	// слушаются события ОС, при вызове которых в канал stop запишется ...
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 
-	// читаем из канала stop(блокирующая операция, пока выполняется горутина MustRun)
-	// и пишем сигнал в переменную sign, который пришел от ОС в канал stop
-	sign := <-stop
-	logger.Info("stopping", slog.String("signal", sign.String()))
+	// ждем сигнал от ОС или ошибку сервера (например, порт занят)
+	exitCode := 0
+	select {
+	case sign := <-stop:
+		logger.Info("stopping", slog.String("signal", sign.String()))
+	case err := <-errs:
+		logger.Error("server failed, stopping", slog.String("error", err.Error()))
+		exitCode = 1
+	}
+
+	// завершаем работу приложения: текущие запросы выполняются до конца, но не дольше shutdown_timeout
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := application.Stop(ctx); err != nil {
+		logger.Error("failed to stop application", slog.String("error", err.Error()))
+		exitCode = 1
+	}
 
-	// завершаем работу приложения(работающие в это время процессы выполнятся до конца)
-	application.GrpcServer.Stop()
-	logger.Info("application stopped")
+	if exitCode != 0 {
+		cancel()
+		os.Exit(exitCode)
+	}
 }
 
 func setupLogger(env string) *slog.Logger {
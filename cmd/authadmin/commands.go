@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/lib/appsecret"
+	"usekit-auth/internal/lib/emailaddr"
+)
+
+func createUser(ctx context.Context, a *admin, args []string) error {
+	flags := flag.NewFlagSet("create-user", flag.ContinueOnError)
+	password := flags.String("password", "", "Password, read from stdin if empty")
+	isAdmin := flags.Bool("admin", false, "Grant admin rights to the user")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return fmt.Errorf("%w: create-user [-password P] [-admin] EMAIL", errUsage)
+	}
+
+	if *password == "" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read password from stdin: %w", err)
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+	if *password == "" {
+		return fmt.Errorf("%w: password is empty", errUsage)
+	}
+
+	id, err := a.auth.RegisterNewUser(ctx, flags.Arg(0), *password)
+	if err != nil {
+		return err
+	}
+	if *isAdmin {
+		if err := a.auth.SetAdmin(ctx, id, true); err != nil {
+			return fmt.Errorf("user %d was created, but: %w", id, err)
+		}
+	}
+
+	fmt.Printf("user %d created, admin: %t\n", id, *isAdmin)
+	return nil
+}
+
+func promote(ctx context.Context, a *admin, args []string) error {
+	return setAdmin(ctx, a, "promote", args, true)
+}
+
+func demote(ctx context.Context, a *admin, args []string) error {
+	return setAdmin(ctx, a, "demote", args, false)
+}
+
+func setAdmin(ctx context.Context, a *admin, name string, args []string, isAdmin bool) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: %s USER", errUsage, name)
+	}
+
+	user, err := a.user(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if user.IsAdmin == isAdmin {
+		fmt.Printf("user %d (%s) is unchanged, admin: %t\n", user.Id, user.Email, isAdmin)
+		return nil
+	}
+
+	if err := a.auth.SetAdmin(ctx, user.Id, isAdmin); err != nil {
+		return err
+	}
+
+	fmt.Printf("user %d (%s) updated, admin: %t\n", user.Id, user.Email, isAdmin)
+	return nil
+}
+
+// user finds a user by id or by email
+func (a *admin) user(ctx context.Context, ref string) (models.User, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return a.storage.UserById(ctx, id)
+	}
+
+	email, err := emailaddr.Normalize(ref)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%w: %q is neither a user id nor an email", errUsage, ref)
+	}
+	return a.storage.User(ctx, email)
+}
+
+func createApp(ctx context.Context, a *admin, args []string) error {
+	flags := flag.NewFlagSet("create-app", flag.ContinueOnError)
+	secret := flags.String("secret", "", "Secret of the app, generated if empty")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return fmt.Errorf("%w: create-app [-secret S] NAME", errUsage)
+	}
+
+	app, err := a.saveApp(ctx, flags.Arg(0), *secret)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("app %d (%s) created, secret: %s\n", app.Id, app.Name, app.Secret)
+	return nil
+}
+
+// saveApp creates an app, the secret is generated if it is empty
+func (a *admin) saveApp(ctx context.Context, name, secret string) (models.App, error) {
+	if secret == "" {
+		generated, err := appsecret.New()
+		if err != nil {
+			return models.App{}, err
+		}
+		secret = generated
+	}
+
+	id, err := a.storage.SaveApp(ctx, name, secret)
+	if err != nil {
+		return models.App{}, err
+	}
+	return models.App{Id: id, Name: name, Secret: secret}, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"usekit-auth/internal/domain/models"
+)
+
+// exported users of both formats are compared by id, email and admin rights
+type exported struct {
+	id      string
+	email   string
+	isAdmin string
+}
+
+func readExport(t *testing.T, path, format string) []exported {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var users []exported
+	switch format {
+	case "jsonl":
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var fields map[string]any
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &fields))
+			require.NotContains(t, fields, "password_hash")
+			users = append(users, exported{
+				id:      fmt.Sprint(fields["id"]),
+				email:   fields["email"].(string),
+				isAdmin: fmt.Sprint(fields["is_admin"]),
+			})
+		}
+		require.NoError(t, scanner.Err())
+	case "csv":
+		records, err := csv.NewReader(f).ReadAll()
+		require.NoError(t, err)
+		require.Equal(t, exportColumns, records[0])
+		for _, record := range records[1:] {
+			users = append(users, exported{id: record[0], email: record[1], isAdmin: record[2]})
+		}
+	}
+	return users
+}
+
+func TestExportUsers(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAdmin(t)
+
+	// more than two pages, users created within the same second are paged by id
+	var want []exported
+	for i := range 2*exportPageSize + 1 {
+		email := fmt.Sprintf("user%d@example.com", i)
+		id, err := a.storage.SaveUser(ctx, email, []byte{0}, 0)
+		require.NoError(t, err)
+
+		switch i {
+		case exportPageSize:
+			// erased users are skipped
+			require.NoError(t, a.storage.SetUserStatus(ctx, id, models.UserStatusDeleted))
+			continue
+		case 1:
+			require.NoError(t, a.auth.SetAdmin(ctx, id, true))
+			want = append(want, exported{id: fmt.Sprint(id), email: email, isAdmin: "true"})
+			continue
+		}
+		want = append(want, exported{id: fmt.Sprint(id), email: email, isAdmin: "false"})
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		file   string
+		format string
+	}{
+		{name: "jsonl", file: "users.jsonl", format: "jsonl"},
+		{name: "csv", file: "users.csv", format: "csv"},
+		{name: "format flag", args: []string{"-format", "csv"}, file: "users.out", format: "csv"},
+		{name: "jsonl by default", file: "users.out", format: "jsonl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			require.NoError(t, exportUsers(ctx, a, append(tt.args, "-out", path)))
+			require.Equal(t, want, readExport(t, path, tt.format))
+		})
+	}
+
+	require.ErrorIs(t, exportUsers(ctx, a, []string{"-format", "xml"}), errUsage)
+	require.ErrorIs(t, exportUsers(ctx, a, []string{"users.jsonl"}), errUsage)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// importRow is a user of another system, line is the line of the input it was read from
+type importRow struct {
+	line         int
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// rowReader returns rows one by one and io.EOF after the last one.
+// An error together with a row number describes an invalid row, reading can go on.
+type rowReader interface {
+	next() (importRow, error)
+}
+
+// importUsers saves users with their password hashes, rows that fail are reported and skipped
+func importUsers(ctx context.Context, a *admin, args []string) error {
+	flags := flag.NewFlagSet("import-users", flag.ContinueOnError)
+	format := flags.String("format", "", "csv or jsonl, taken from the file extension if empty")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return fmt.Errorf("%w: import-users [-format csv|jsonl] FILE", errUsage)
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = formatOf(path)
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var rows rowReader
+	switch *format {
+	case "csv":
+		reader, err := newCSVRows(in)
+		if err != nil {
+			return err
+		}
+		rows = reader
+	case "jsonl":
+		rows = newJSONLRows(in)
+	default:
+		return fmt.Errorf("%w: unknown format %q, use -format csv or jsonl", errUsage, *format)
+	}
+
+	var imported, failed int
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("import interrupted after %d users: %w", imported, err)
+		}
+
+		row, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil {
+			_, err = a.auth.ImportUser(ctx, row.Email, []byte(row.PasswordHash))
+		}
+		if err != nil {
+			// строка без номера значит, что файл нельзя читать дальше
+			if row.line == 0 {
+				return err
+			}
+			failed++
+			fmt.Fprintf(os.Stderr, "line %d: %s: %v\n", row.line, row.Email, err)
+			continue
+		}
+		imported++
+	}
+
+	fmt.Printf("imported %d users, failed %d\n", imported, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d rows were not imported", failed)
+	}
+	return nil
+}
+
+func formatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	default:
+		return ""
+	}
+}
+
+// csvRows reads a CSV file with a header, the email and password_hash columns are required
+// and may be in any order, other columns are ignored
+type csvRows struct {
+	reader        *csv.Reader
+	emailCol      int
+	passHashCol   int
+	minFieldCount int
+}
+
+func newCSVRows(in io.Reader) (*csvRows, error) {
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	rows := &csvRows{reader: reader, emailCol: -1, passHashCol: -1}
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "email":
+			rows.emailCol = i
+		case "password_hash":
+			rows.passHashCol = i
+		}
+	}
+	if rows.emailCol < 0 || rows.passHashCol < 0 {
+		return nil, errors.New("CSV header has to contain email and password_hash columns")
+	}
+	rows.minFieldCount = max(rows.emailCol, rows.passHashCol) + 1
+
+	return rows, nil
+}
+
+func (r *csvRows) next() (importRow, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		return importRow{}, err
+	}
+
+	line, _ := r.reader.FieldPos(0)
+	if len(record) < r.minFieldCount {
+		return importRow{line: line}, fmt.Errorf("expected at least %d fields, got %d", r.minFieldCount, len(record))
+	}
+
+	return importRow{
+		line:         line,
+		Email:        strings.TrimSpace(record[r.emailCol]),
+		PasswordHash: strings.TrimSpace(record[r.passHashCol]),
+	}, nil
+}
+
+// jsonlRows reads one JSON object per line, blank lines are skipped
+type jsonlRows struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newJSONLRows(in io.Reader) *jsonlRows {
+	scanner := bufio.NewScanner(in)
+	// длинные argon2 хэши и лишние поля не должны упираться в размер буфера по умолчанию
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	return &jsonlRows{scanner: scanner}
+}
+
+func (r *jsonlRows) next() (importRow, error) {
+	for r.scanner.Scan() {
+		r.line++
+		text := bytes.TrimSpace(r.scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		row := importRow{line: r.line}
+		if err := json.Unmarshal(text, &row); err != nil {
+			return importRow{line: r.line}, fmt.Errorf("invalid JSON: %w", err)
+		}
+		row.line = r.line
+		return row, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return importRow{}, err
+	}
+	return importRow{}, io.EOF
+}
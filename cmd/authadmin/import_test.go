@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"usekit-auth/internal/lib/password"
+)
+
+// readRows reads all rows, errors of invalid rows are returned by line
+func readRows(t *testing.T, rows rowReader) ([]importRow, map[int]string) {
+	t.Helper()
+
+	var read []importRow
+	invalid := map[int]string{}
+	for {
+		row, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			return read, invalid
+		}
+		if err != nil {
+			require.NotZero(t, row.line, err.Error())
+			invalid[row.line] = err.Error()
+			continue
+		}
+		read = append(read, row)
+	}
+}
+
+func TestCSVRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		rows    []importRow
+		invalid map[int]string
+	}{
+		{
+			name:  "columns in any order with extra ones",
+			input: "id,Password_Hash, email \n1,$2a$10$x,a@example.com\n2,\" $2a$10$y \",\" b@example.com\"\n",
+			rows: []importRow{
+				{line: 2, Email: "a@example.com", PasswordHash: "$2a$10$x"},
+				{line: 3, Email: "b@example.com", PasswordHash: "$2a$10$y"},
+			},
+			invalid: map[int]string{},
+		},
+		{
+			name:  "quoted fields span lines",
+			input: "email,password_hash,note\na@example.com,$2a$10$x,\"multi\nline\"\nb@example.com,$2a$10$y,\n",
+			rows: []importRow{
+				{line: 2, Email: "a@example.com", PasswordHash: "$2a$10$x"},
+				{line: 4, Email: "b@example.com", PasswordHash: "$2a$10$y"},
+			},
+			invalid: map[int]string{},
+		},
+		{
+			name:    "short row is reported and skipped",
+			input:   "email,password_hash\na@example.com\nb@example.com,$2a$10$y\n",
+			rows:    []importRow{{line: 3, Email: "b@example.com", PasswordHash: "$2a$10$y"}},
+			invalid: map[int]string{2: "expected at least 2 fields, got 1"},
+		},
+		{
+			name:    "header only",
+			input:   "email,password_hash\n",
+			invalid: map[int]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := newCSVRows(strings.NewReader(tt.input))
+			require.NoError(t, err)
+
+			rows, invalid := readRows(t, reader)
+			require.Equal(t, tt.rows, rows)
+			require.Equal(t, tt.invalid, invalid)
+		})
+	}
+}
+
+func TestNewCSVRows_Header(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "empty", input: ""},
+		{name: "no password_hash", input: "email,hash\n"},
+		{name: "no email", input: "login,password_hash\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newCSVRows(strings.NewReader(tt.input))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestJSONLRows(t *testing.T) {
+	input := strings.Join([]string{
+		`{"email": "a@example.com", "password_hash": "$2a$10$x", "id": 1}`,
+		``,
+		`   `,
+		`{"email": "b@example.com"`,
+		`["c@example.com"]`,
+		`{"email": "d@example.com", "password_hash": "$argon2id$y"}`,
+	}, "\n")
+
+	rows, invalid := readRows(t, newJSONLRows(strings.NewReader(input)))
+	require.Equal(t, []importRow{
+		{line: 1, Email: "a@example.com", PasswordHash: "$2a$10$x"},
+		{line: 6, Email: "d@example.com", PasswordHash: "$argon2id$y"},
+	}, rows)
+	require.Len(t, invalid, 2)
+	require.Contains(t, invalid[4], "invalid JSON")
+	require.Contains(t, invalid[5], "invalid JSON")
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]string{
+		"users.csv":    "csv",
+		"USERS.CSV":    "csv",
+		"users.jsonl":  "jsonl",
+		"users.ndjson": "jsonl",
+		"users.json":   "",
+		"-":            "",
+	}
+	for path, format := range tests {
+		require.Equal(t, format, formatOf(path), path)
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAdmin(t)
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	require.NoError(t, err)
+	argon, err := password.NewArgon2id(password.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1})
+	require.NoError(t, err)
+	argonHash, err := argon.Hash(testPassword)
+	require.NoError(t, err)
+	// the key is not derived for hashes above the limits, the row fails fast
+	expensive := strings.Replace(string(argonHash), "m=64", "m=4194304", 1)
+
+	file := writeFile(t, "users.csv", "email,password_hash\n"+
+		"Bcrypt@Example.com,"+string(bcryptHash)+"\n"+
+		"argon@example.com,\""+string(argonHash)+"\"\n"+
+		"plain@example.com,"+testPassword+"\n"+
+		"expensive@example.com,\""+expensive+"\"\n"+
+		"argon@example.com,\""+string(argonHash)+"\"\n")
+
+	err = importUsers(ctx, a, []string{file})
+	require.EqualError(t, err, "3 rows were not imported")
+
+	apps, err := a.storage.Apps(ctx)
+	require.NoError(t, err)
+	for _, email := range []string{"bcrypt@example.com", "argon@example.com"} {
+		_, err := a.auth.Login(ctx, email, testPassword, apps[0].Id)
+		require.NoError(t, err, email)
+	}
+	for _, email := range []string{"plain@example.com", "expensive@example.com"} {
+		_, err := a.storage.User(ctx, email)
+		require.Error(t, err, email)
+	}
+
+	require.ErrorIs(t, importUsers(ctx, a, []string{writeFile(t, "users.txt", "")}), errUsage)
+	require.ErrorIs(t, importUsers(ctx, a, []string{"-format", "xml", file}), errUsage)
+}
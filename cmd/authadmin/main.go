@@ -0,0 +1,157 @@
+package main
+
+// администрирование хранилища: первый админ, права админов и приложения без прямых запросов к SQLite
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"usekit-auth/internal/app"
+	"usekit-auth/internal/audit"
+	"usekit-auth/internal/config"
+	"usekit-auth/internal/services/auth"
+	"usekit-auth/internal/storage/sqlite"
+)
+
+const usage = `Usage: authadmin [flags] command [command flags] [args]
+
+Commands:
+  create-user [-password P] [-admin] EMAIL  create a user, the password has to satisfy the password policy
+  promote USER                              grant admin rights, USER is a user id or an email
+  demote USER                               revoke admin rights
+  create-app [-secret S] NAME               create an app, the secret is generated unless given
+  seed FILE                                 create the apps and users of a YAML seed, see config/seed.example.yaml;
+                                            existing apps and users are kept, admin rights are set as in the seed
+  import-users [-format F] FILE             import users of another system with their bcrypt or argon2id password
+                                            hashes from CSV (header with email and password_hash) or JSONL
+                                            ({"email": ..., "password_hash": ...}), FILE may be "-" for stdin;
+                                            failed rows are reported and skipped
+  export-users [-format F] [-out FILE]      write users without password hashes as JSONL or CSV
+
+Passwords are read from the first line of stdin when -password is not set.
+Storage, password hashing and audit sinks are the ones the server uses with the same config,
+changes of users are recorded in the audit log.
+
+Flags:
+`
+
+// errUsage is returned by commands called with wrong arguments
+var errUsage = errors.New("invalid arguments")
+
+type command func(ctx context.Context, a *admin, args []string) error
+
+var commands = map[string]command{
+	"create-user":  createUser,
+	"promote":      promote,
+	"demote":       demote,
+	"create-app":   createApp,
+	"seed":         seed,
+	"import-users": importUsers,
+	"export-users": exportUsers,
+}
+
+type admin struct {
+	auth    *auth.Auth
+	storage *sqlite.Storage
+}
+
+func main() {
+	var configPath string
+	flag.StringVar(&configPath, "config", "", "Path to the config file, CONFIG_PATH if empty")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if configPath == "" {
+		configPath = os.Getenv("CONFIG_PATH")
+	}
+	if configPath == "" {
+		panic("config file path is empty")
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fail(2, fmt.Errorf("unknown command %q, see authadmin -h", flag.Arg(0)))
+	}
+
+	cfg := config.MustLoadByPath(configPath)
+	// сервис пишет info логи на каждую операцию, для консоли достаточно предупреждений
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	storage, err := sqlite.New(cfg.StoragePath)
+	if err != nil {
+		panic(err)
+	}
+
+	auditSink, err := app.NewAuditSink(logger, storage, cfg.Audit)
+	if err != nil {
+		panic(err)
+	}
+
+	passwords, err := app.NewPasswords(logger, cfg.Password)
+	if err != nil {
+		panic(err)
+	}
+
+	a := &admin{
+		auth: auth.New(
+			logger,
+			storage,
+			storage,
+			storage,
+			storage,
+			auditSink,
+			storage,
+			passwords.Policy,
+			passwords.Hasher,
+			passwords.Peppers,
+			cfg.TokenTTL,
+			cfg.Login.ConstantTime,
+		),
+		storage: storage,
+	}
+
+	// импорт можно прервать, уже сохраненные пользователи останутся
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err = cmd(ctx, a, flag.Args()[1:])
+	stop()
+
+	closeAll(auditSink, storage, passwords)
+
+	if err != nil {
+		if errors.Is(err, errUsage) {
+			fail(2, err)
+		}
+		fail(1, err)
+	}
+}
+
+// closeAll flushes audit events before the storage they are written to is closed
+func closeAll(auditSink audit.Sink, storage *sqlite.Storage, passwords app.Passwords) {
+	if err := passwords.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to close breached password list: %v\n", err)
+	}
+	if err := auditSink.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to close audit sink: %v\n", err)
+	}
+	if err := storage.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to close storage: %v\n", err)
+	}
+}
+
+func fail(code int, err error) {
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	os.Exit(code)
+}
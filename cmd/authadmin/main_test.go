@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"usekit-auth/internal/audit"
+	"usekit-auth/internal/lib/password"
+	"usekit-auth/internal/services/auth"
+	"usekit-auth/internal/storage"
+	"usekit-auth/internal/storage/sqlite"
+)
+
+const testPassword = "Correct-Horse-7"
+
+// newTestAdmin returns the admin on a migrated database in a temporary directory
+// with cheap argon2id parameters, bcrypt hashes are accepted as legacy ones
+func newTestAdmin(t *testing.T) *admin {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "auth.db")
+	migrator, err := migrate.New("file://../../migrations", "sqlite3://"+path)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+	sourceErr, dbErr := migrator.Close()
+	require.NoError(t, sourceErr)
+	require.NoError(t, dbErr)
+
+	st, err := sqlite.New(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = st.Close() })
+
+	argon, err := password.NewArgon2id(password.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1})
+	require.NoError(t, err)
+	bcr, err := password.NewBcrypt(bcrypt.MinCost)
+	require.NoError(t, err)
+
+	return &admin{
+		auth: auth.New(
+			slog.New(slog.NewTextHandler(io.Discard, nil)),
+			st,
+			st,
+			st,
+			st,
+			audit.NewStorageSink(st),
+			st,
+			password.Policy{MinLength: 8},
+			password.NewHasher(argon, bcr),
+			&password.Peppers{},
+			time.Hour,
+			false,
+		),
+		storage: st,
+	}
+}
+
+// writeFile writes content to a file in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func isAdmin(t *testing.T, a *admin, email string) bool {
+	t.Helper()
+	user, err := a.storage.User(context.Background(), email)
+	require.NoError(t, err)
+	return user.IsAdmin
+}
+
+func TestCommands_Usage(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+	}{
+		{name: "create-user without email", command: "create-user", args: []string{"-password", testPassword}},
+		{name: "create-user with two emails", command: "create-user", args: []string{"-password", testPassword, "a@example.com", "b@example.com"}},
+		{name: "create-user with unknown flag", command: "create-user", args: []string{"-root", "a@example.com"}},
+		{name: "promote without user", command: "promote"},
+		{name: "promote neither id nor email", command: "promote", args: []string{"not a user"}},
+		{name: "demote with two users", command: "demote", args: []string{"1", "2"}},
+		{name: "create-app without name", command: "create-app"},
+		{name: "seed without file", command: "seed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, ok := commands[tt.command]
+			require.True(t, ok)
+
+			err := cmd(context.Background(), newTestAdmin(t), tt.args)
+			require.ErrorIs(t, err, errUsage)
+		})
+	}
+}
+
+func TestCreateUser_PromoteDemote(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAdmin(t)
+
+	require.NoError(t, createUser(ctx, a, []string{"-password", testPassword, "-admin", "Admin@Example.com"}))
+	require.True(t, isAdmin(t, a, "admin@example.com"))
+
+	require.NoError(t, createUser(ctx, a, []string{"-password", testPassword, "user@example.com"}))
+	require.False(t, isAdmin(t, a, "user@example.com"))
+
+	// the password policy of the server applies
+	require.ErrorIs(t, createUser(ctx, a, []string{"-password", "short", "weak@example.com"}), auth.ErrWeakPassword)
+	require.ErrorIs(t, createUser(ctx, a, []string{"-password", testPassword, "user@example.com"}), auth.ErrUserExists)
+
+	// users are found by email or by id
+	require.NoError(t, promote(ctx, a, []string{"USER@example.com"}))
+	require.True(t, isAdmin(t, a, "user@example.com"))
+	user, err := a.storage.User(ctx, "user@example.com")
+	require.NoError(t, err)
+	id := strconv.FormatInt(user.Id, 10)
+	require.NoError(t, demote(ctx, a, []string{id}))
+	require.False(t, isAdmin(t, a, "user@example.com"))
+	// demoting again changes nothing
+	require.NoError(t, demote(ctx, a, []string{id}))
+
+	require.ErrorIs(t, promote(ctx, a, []string{"nobody@example.com"}), storage.ErrUserNotFound)
+	require.ErrorIs(t, promote(ctx, a, []string{"100"}), storage.ErrUserNotFound)
+}
+
+func TestCreateApp(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAdmin(t)
+
+	require.NoError(t, createApp(ctx, a, []string{"-secret", "web-secret", "web"}))
+	require.NoError(t, createApp(ctx, a, []string{"mobile"}))
+
+	web, err := a.storage.AppByName(ctx, "web")
+	require.NoError(t, err)
+	require.Equal(t, "web-secret", web.Secret)
+	mobile, err := a.storage.AppByName(ctx, "mobile")
+	require.NoError(t, err)
+	require.NotEmpty(t, mobile.Secret)
+	require.NotEqual(t, web.Secret, mobile.Secret)
+}
+
+func TestSeed(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAdmin(t)
+	t.Setenv("TEST_SEED_SECRET", "mobile-secret")
+	t.Setenv("TEST_SEED_PASSWORD", testPassword)
+
+	file := writeFile(t, "seed.yaml", `
+apps:
+  - name: web
+  - name: mobile
+    secret_env: TEST_SEED_SECRET
+users:
+  - email: Admin@Example.com
+    password_env: TEST_SEED_PASSWORD
+    admin: true
+  - email: user@example.com
+    password: Other-Horse-8
+`)
+	require.NoError(t, seed(ctx, a, []string{file}))
+
+	web, err := a.storage.AppByName(ctx, "web")
+	require.NoError(t, err)
+	mobile, err := a.storage.AppByName(ctx, "mobile")
+	require.NoError(t, err)
+	require.Equal(t, "mobile-secret", mobile.Secret)
+	require.True(t, isAdmin(t, a, "admin@example.com"))
+	require.False(t, isAdmin(t, a, "user@example.com"))
+
+	// applying the seed again changes nothing
+	require.NoError(t, seed(ctx, a, []string{file}))
+	again, err := a.storage.AppByName(ctx, "web")
+	require.NoError(t, err)
+	require.Equal(t, web, again)
+	apps, err := a.storage.Apps(ctx)
+	require.NoError(t, err)
+
+	// only admin rights of existing users follow the seed, passwords are not changed
+	changed := writeFile(t, "seed.yaml", `
+users:
+  - email: admin@example.com
+    password: Changed-Horse-9
+    admin: false
+`)
+	require.NoError(t, seed(ctx, a, []string{changed}))
+	require.False(t, isAdmin(t, a, "admin@example.com"))
+	_, err = a.auth.Login(ctx, "admin@example.com", testPassword, apps[0].Id)
+	require.NoError(t, err)
+}
+
+func TestReadSeed_Example(t *testing.T) {
+	file, err := readSeed("../../config/seed.example.yaml")
+	require.NoError(t, err)
+	require.Len(t, file.Apps, 2)
+	require.Equal(t, []seedUser{{Email: "admin@example.com", PasswordEnv: "ADMIN_PASSWORD", Admin: true}}, file.Users)
+}
+
+func TestSeed_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "unknown key", content: "users:\n  - email: a@example.com\n    password: " + testPassword + "\n    is_admin: true\n", wantErr: "field is_admin not found"},
+		{name: "app without name", content: "apps:\n  - secret: s\n", wantErr: "apps[0] has no name"},
+		{name: "user without email", content: "users:\n  - password: " + testPassword + "\n", wantErr: "users[0] has no email"},
+		{name: "new user without password", content: "users:\n  - email: a@example.com\n", wantErr: "password or password_env is required"},
+		{name: "unset environment variable", content: "apps:\n  - name: web\n    secret_env: TEST_SEED_UNSET\n", wantErr: "TEST_SEED_UNSET is not set"},
+		{name: "weak password", content: "users:\n  - email: a@example.com\n    password: short\n", wantErr: "users[0] a@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := seed(context.Background(), newTestAdmin(t), []string{writeFile(t, "seed.yaml", tt.content)})
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+	"usekit-auth/internal/lib/emailaddr"
+	"usekit-auth/internal/storage"
+)
+
+// seedFile lists apps and users that have to exist, see config/seed.example.yaml
+type seedFile struct {
+	Apps  []seedApp  `yaml:"apps"`
+	Users []seedUser `yaml:"users"`
+}
+
+type seedApp struct {
+	Name string `yaml:"name"`
+	// Secret or the environment variable SecretEnv is the secret of a new app, it is generated if both are empty
+	Secret    string `yaml:"secret"`
+	SecretEnv string `yaml:"secret_env"`
+}
+
+type seedUser struct {
+	Email string `yaml:"email"`
+	// Password or the environment variable PasswordEnv is the password of a new user
+	Password    string `yaml:"password"`
+	PasswordEnv string `yaml:"password_env"`
+	Admin       bool   `yaml:"admin"`
+}
+
+// seed creates missing apps and users of the seed file and sets admin rights of users as in the file.
+// Existing apps and passwords are never changed, so running the same seed again changes nothing.
+func seed(ctx context.Context, a *admin, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: seed FILE", errUsage)
+	}
+
+	file, err := readSeed(args[0])
+	if err != nil {
+		return err
+	}
+
+	for i, app := range file.Apps {
+		if err := a.seedApp(ctx, app); err != nil {
+			return fmt.Errorf("apps[%d] %s: %w", i, app.Name, err)
+		}
+	}
+	for i, user := range file.Users {
+		if err := a.seedUser(ctx, user); err != nil {
+			return fmt.Errorf("users[%d] %s: %w", i, user.Email, err)
+		}
+	}
+
+	return nil
+}
+
+func readSeed(path string) (seedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return seedFile{}, err
+	}
+	defer f.Close()
+
+	var file seedFile
+	decoder := yaml.NewDecoder(f)
+	// опечатка в ключе не должна молча создать пользователя без прав админа
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return seedFile{}, fmt.Errorf("invalid seed file %s: %w", path, err)
+	}
+
+	for i, app := range file.Apps {
+		if app.Name == "" {
+			return seedFile{}, fmt.Errorf("invalid seed file %s: apps[%d] has no name", path, i)
+		}
+	}
+	for i, user := range file.Users {
+		if user.Email == "" {
+			return seedFile{}, fmt.Errorf("invalid seed file %s: users[%d] has no email", path, i)
+		}
+	}
+
+	return file, nil
+}
+
+func (a *admin) seedApp(ctx context.Context, app seedApp) error {
+	secret, err := valueOrEnv(app.Secret, app.SecretEnv)
+	if err != nil {
+		return err
+	}
+
+	existing, err := a.storage.AppByName(ctx, app.Name)
+	if err == nil {
+		if secret != "" && secret != existing.Secret {
+			fmt.Printf("app %d (%s) exists, warning: its secret differs from the seed and was not changed\n", existing.Id, existing.Name)
+			return nil
+		}
+		fmt.Printf("app %d (%s) exists\n", existing.Id, existing.Name)
+		return nil
+	}
+	if !errors.Is(err, storage.ErrAppNotFound) {
+		return err
+	}
+
+	created, err := a.saveApp(ctx, app.Name, secret)
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		fmt.Printf("app %d (%s) created, secret: %s\n", created.Id, created.Name, created.Secret)
+	} else {
+		fmt.Printf("app %d (%s) created\n", created.Id, created.Name)
+	}
+	return nil
+}
+
+func (a *admin) seedUser(ctx context.Context, user seedUser) error {
+	email, err := emailaddr.Normalize(user.Email)
+	if err != nil {
+		return err
+	}
+
+	existing, err := a.storage.User(ctx, email)
+	if err == nil {
+		if existing.IsAdmin == user.Admin {
+			fmt.Printf("user %d (%s) exists\n", existing.Id, existing.Email)
+			return nil
+		}
+		if err := a.auth.SetAdmin(ctx, existing.Id, user.Admin); err != nil {
+			return err
+		}
+		fmt.Printf("user %d (%s) exists, admin: %t\n", existing.Id, existing.Email, user.Admin)
+		return nil
+	}
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		return err
+	}
+
+	password, err := valueOrEnv(user.Password, user.PasswordEnv)
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		return errors.New("password or password_env is required to create the user")
+	}
+
+	id, err := a.auth.RegisterNewUser(ctx, email, password)
+	if err != nil {
+		return err
+	}
+	if user.Admin {
+		if err := a.auth.SetAdmin(ctx, id, true); err != nil {
+			return fmt.Errorf("user %d was created, but: %w", id, err)
+		}
+	}
+
+	fmt.Printf("user %d (%s) created, admin: %t\n", id, email, user.Admin)
+	return nil
+}
+
+// valueOrEnv returns value or, if it is empty, the environment variable env which has to be set
+func valueOrEnv(value, env string) (string, error) {
+	if value != "" || env == "" {
+		return value, nil
+	}
+
+	value, ok := os.LookupEnv(env)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", env)
+	}
+	return value, nil
+}
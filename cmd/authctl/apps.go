@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/lib/appsecret"
+	"usekit-auth/internal/storage/sqlite"
+)
+
+type appJSON struct {
+	Id     int    `json:"id"`
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+}
+
+// apps manages apps in the storage, the gRPC API has no methods for them
+func apps(ctx context.Context, e *env, args []string) (result, error) {
+	if len(args) == 0 {
+		return result{}, fmt.Errorf("%w: apps list|create", errUsage)
+	}
+	if e.storagePath == "" {
+		return result{}, fmt.Errorf("%w: apps commands require -storage-path", errUsage)
+	}
+
+	storage, err := sqlite.New(e.storagePath)
+	if err != nil {
+		return result{}, err
+	}
+	defer storage.Close()
+
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return result{}, fmt.Errorf("%w: apps list", errUsage)
+		}
+		list, err := storage.Apps(ctx)
+		if err != nil {
+			return result{}, err
+		}
+		return appsResult(list), nil
+	case "create":
+		return createApp(ctx, storage, args[1:])
+	default:
+		return result{}, fmt.Errorf("%w: unknown apps command %q", errUsage, args[0])
+	}
+}
+
+func createApp(ctx context.Context, storage *sqlite.Storage, args []string) (result, error) {
+	flags := flag.NewFlagSet("apps create", flag.ContinueOnError)
+	secret := flags.String("secret", "", "Secret of the app, generated if empty")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return result{}, fmt.Errorf("%w: apps create [-secret S] NAME", errUsage)
+	}
+
+	if *secret == "" {
+		generated, err := appsecret.New()
+		if err != nil {
+			return result{}, err
+		}
+		*secret = generated
+	}
+
+	id, err := storage.SaveApp(ctx, flags.Arg(0), *secret)
+	if err != nil {
+		return result{}, err
+	}
+
+	return appsResult([]models.App{{Id: id, Name: flags.Arg(0), Secret: *secret}}), nil
+}
+
+func appsResult(list []models.App) result {
+	rows := make([][]string, 0, len(list))
+	value := make([]appJSON, 0, len(list))
+	for _, app := range list {
+		rows = append(rows, []string{strconv.Itoa(app.Id), app.Name, app.Secret})
+		value = append(value, appJSON{Id: app.Id, Name: app.Name, Secret: app.Secret})
+	}
+
+	return result{
+		columns: []string{"id", "name", "secret"},
+		rows:    rows,
+		value:   value,
+	}
+}
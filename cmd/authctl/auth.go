@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+)
+
+func register(ctx context.Context, e *env, args []string) (result, error) {
+	flags := flag.NewFlagSet("register", flag.ContinueOnError)
+	password := flags.String("password", "", "Password, read from stdin if empty")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return result{}, fmt.Errorf("%w: register [-password P] EMAIL", errUsage)
+	}
+
+	if err := readPassword(password); err != nil {
+		return result{}, err
+	}
+
+	conn, err := e.client()
+	if err != nil {
+		return result{}, err
+	}
+	resp, err := authv1.NewAuthClient(conn).Register(ctx, &authv1.RegisterRequest{
+		Email:    flags.Arg(0),
+		Password: *password,
+	})
+	if err != nil {
+		return result{}, err
+	}
+
+	return result{
+		columns: []string{"user_id"},
+		rows:    [][]string{{strconv.FormatInt(resp.GetUserId(), 10)}},
+		value:   map[string]int64{"user_id": resp.GetUserId()},
+	}, nil
+}
+
+func login(ctx context.Context, e *env, args []string) (result, error) {
+	flags := flag.NewFlagSet("login", flag.ContinueOnError)
+	password := flags.String("password", "", "Password, read from stdin if empty")
+	appId := flags.Int("app-id", 0, "Id of the app to log in to")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 || *appId == 0 {
+		return result{}, fmt.Errorf("%w: login [-password P] -app-id N EMAIL", errUsage)
+	}
+
+	if err := readPassword(password); err != nil {
+		return result{}, err
+	}
+
+	conn, err := e.client()
+	if err != nil {
+		return result{}, err
+	}
+	resp, err := authv1.NewAuthClient(conn).Login(ctx, &authv1.LoginRequest{
+		Email:    flags.Arg(0),
+		Password: *password,
+		AppId:    int32(*appId),
+	})
+	if err != nil {
+		return result{}, err
+	}
+
+	return result{
+		columns: []string{"token"},
+		rows:    [][]string{{resp.GetToken()}},
+		value:   map[string]string{"token": resp.GetToken()},
+	}, nil
+}
+
+func isAdmin(ctx context.Context, e *env, args []string) (result, error) {
+	if len(args) != 1 {
+		return result{}, fmt.Errorf("%w: is-admin USER_ID", errUsage)
+	}
+	userId, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return result{}, fmt.Errorf("%w: USER_ID must be a number", errUsage)
+	}
+
+	conn, err := e.client()
+	if err != nil {
+		return result{}, err
+	}
+	resp, err := authv1.NewAuthClient(conn).IsAdmin(ctx, &authv1.IsAdminRequest{UserId: userId})
+	if err != nil {
+		return result{}, err
+	}
+
+	return result{
+		columns: []string{"user_id", "is_admin"},
+		rows:    [][]string{{args[0], strconv.FormatBool(resp.GetIsAdmin())}},
+		value:   map[string]any{"user_id": userId, "is_admin": resp.GetIsAdmin()},
+	}, nil
+}
+
+// services lists services with the reflection service of the server
+func services(ctx context.Context, e *env, args []string) (result, error) {
+	if len(args) != 0 {
+		return result{}, fmt.Errorf("%w: services", errUsage)
+	}
+
+	conn, err := e.client()
+	if err != nil {
+		return result{}, err
+	}
+	stream, err := reflectionv1.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return result{}, err
+	}
+	defer func() { _ = stream.CloseSend() }()
+
+	err = stream.Send(&reflectionv1.ServerReflectionRequest{
+		MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		return result{}, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return result{}, err
+	}
+	if errResp := resp.GetErrorResponse(); errResp != nil {
+		return result{}, fmt.Errorf("reflection: %s", errResp.GetErrorMessage())
+	}
+
+	var names []string
+	rows := make([][]string, 0)
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		names = append(names, service.GetName())
+		rows = append(rows, []string{service.GetName()})
+	}
+
+	return result{
+		columns: []string{"service"},
+		rows:    rows,
+		value:   map[string][]string{"services": names},
+	}, nil
+}
+
+// readPassword reads the password from the first line of stdin unless it is set
+func readPassword(password *string) error {
+	if *password != "" {
+		return nil
+	}
+
+	line, err := readLine(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read password from stdin: %w", err)
+	}
+	if line == "" {
+		return fmt.Errorf("%w: password is empty", errUsage)
+	}
+	*password = line
+	return nil
+}
+
+func readLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,182 @@
+package main
+
+// клиент gRPC API сервиса авторизации для отладки и ручных проверок
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const usage = `Usage: authctl [flags] command [command flags] [args]
+
+Commands:
+  register [-password P] EMAIL        register a user, prints the user id
+  login [-password P] -app-id N EMAIL log in to an app, prints the token
+  is-admin USER_ID                    check whether a user is an admin
+  decode-token [-secret S] TOKEN      print the header and claims of a token, the signature
+                                      is verified with -secret or the app secret from -storage-path
+  services                            list services of the server, requires grpc.reflection
+  apps list                           list apps with their secrets
+  apps create [-secret S] NAME        create an app, the secret is generated unless given
+
+Passwords are read from the first line of stdin when -password is not set, TOKEN may be "-" for stdin.
+The gRPC API has no methods for apps, apps commands work on the storage given by -storage-path.
+
+Flags:
+`
+
+// errUsage is returned by commands called with wrong arguments
+var errUsage = errors.New("invalid arguments")
+
+type command func(ctx context.Context, env *env, args []string) (result, error)
+
+var commands = map[string]command{
+	"register":     register,
+	"login":        login,
+	"is-admin":     isAdmin,
+	"decode-token": decodeToken,
+	"services":     services,
+	"apps":         apps,
+}
+
+// env holds the global flags, the connection is opened by the first command that needs it
+type env struct {
+	addr        string
+	storagePath string
+	useTLS      bool
+	caFile      string
+	certFile    string
+	keyFile     string
+	serverName  string
+
+	conn *grpc.ClientConn
+}
+
+func main() {
+	var e env
+	var timeout time.Duration
+	var output string
+	flag.StringVar(&e.addr, "addr", "localhost:44044", "Address of the gRPC server")
+	flag.DurationVar(&timeout, "timeout", 10*time.Second, "Deadline of the command")
+	flag.StringVar(&output, "o", "table", "Output format: table or json")
+	flag.StringVar(&e.storagePath, "storage-path", "", "Path to the storage, used by apps and decode-token")
+	flag.BoolVar(&e.useTLS, "tls", false, "Connect with TLS, implied by -ca and -cert")
+	flag.StringVar(&e.caFile, "ca", "", "CA to verify the server certificate with, system roots if empty")
+	flag.StringVar(&e.certFile, "cert", "", "Client certificate for mutual TLS")
+	flag.StringVar(&e.keyFile, "key", "", "Key of the client certificate")
+	flag.StringVar(&e.serverName, "server-name", "", "Server name to verify the certificate against, host of -addr if empty")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if output != "table" && output != "json" {
+		fail(2, fmt.Errorf("unknown output format %q", output))
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fail(2, fmt.Errorf("unknown command %q, see authctl -h", flag.Arg(0)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	res, err := cmd(ctx, &e, flag.Args()[1:])
+	cancel()
+	if e.conn != nil {
+		_ = e.conn.Close()
+	}
+	if err != nil {
+		if errors.Is(err, errUsage) {
+			fail(2, err)
+		}
+		fail(1, err)
+	}
+
+	if output == "json" {
+		err = res.writeJSON(os.Stdout)
+	} else {
+		err = res.writeTable(os.Stdout)
+	}
+	if err != nil {
+		fail(1, err)
+	}
+}
+
+// fail prints err and exits, statuses of failed calls are printed with their details
+func fail(code int, err error) {
+	if st, ok := status.FromError(err); ok {
+		fmt.Fprintf(os.Stderr, "error: %s: %s\n", st.Code(), st.Message())
+		for _, detail := range st.Proto().GetDetails() {
+			if text, err := protojson.Marshal(detail); err == nil {
+				fmt.Fprintf(os.Stderr, "  %s\n", text)
+			}
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+	os.Exit(code)
+}
+
+func (e *env) client() (*grpc.ClientConn, error) {
+	if e.conn != nil {
+		return e.conn, nil
+	}
+
+	creds, err := e.transportCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.NewClient(e.addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	e.conn = conn
+	return conn, nil
+}
+
+func (e *env) transportCredentials() (credentials.TransportCredentials, error) {
+	if !e.useTLS && e.caFile == "" && e.certFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig := &tls.Config{ServerName: e.serverName, MinVersion: tls.VersionTLS12}
+
+	if e.caFile != "" {
+		pem, err := os.ReadFile(e.caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", e.caFile)
+		}
+	}
+
+	if e.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(e.certFile, e.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
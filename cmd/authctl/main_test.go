@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/test/bufconn"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/lib/jwt"
+)
+
+// commands fail with errUsage before they connect to the server or open the storage
+func TestCommands_Usage(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		args        []string
+		storagePath string
+	}{
+		{name: "register without email", command: "register", args: []string{"-password", "p"}},
+		{name: "register with two emails", command: "register", args: []string{"-password", "p", "a@example.com", "b@example.com"}},
+		{name: "register with unknown flag", command: "register", args: []string{"-pass", "p", "a@example.com"}},
+		{name: "login without app id", command: "login", args: []string{"-password", "p", "a@example.com"}},
+		{name: "login with flag after email", command: "login", args: []string{"a@example.com", "-app-id", "1"}},
+		{name: "is-admin without user id", command: "is-admin"},
+		{name: "is-admin with not a number", command: "is-admin", args: []string{"one"}},
+		{name: "services with arguments", command: "services", args: []string{"auth.Auth"}},
+		{name: "decode-token without token", command: "decode-token"},
+		{name: "apps without command", command: "apps", storagePath: "auth.db"},
+		{name: "apps without storage", command: "apps", args: []string{"list"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, ok := commands[tt.command]
+			require.True(t, ok)
+
+			e := &env{addr: "invalid address", storagePath: tt.storagePath}
+			_, err := cmd(context.Background(), e, tt.args)
+			require.ErrorIs(t, err, errUsage)
+			require.Nil(t, e.conn)
+		})
+	}
+}
+
+func TestServices(t *testing.T) {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	healthv1.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := services(ctx, &env{conn: conn}, nil)
+	require.NoError(t, err)
+	require.Contains(t, res.rows, []string{healthv1.Health_ServiceDesc.ServiceName})
+
+	var out bytes.Buffer
+	require.NoError(t, res.writeJSON(&out))
+	require.Contains(t, out.String(), `"grpc.health.v1.Health"`)
+}
+
+func TestDecodeToken(t *testing.T) {
+	const secret = "test-secret"
+	token, err := jwt.NewToken(models.User{Id: 42, Email: "user@example.com"}, models.App{Id: 1, Secret: secret},
+		"session-id", time.Hour)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		args     []string
+		verified bool
+		reason   string
+	}{
+		{name: "no secret", args: []string{token}, reason: "no -secret or -storage-path"},
+		{name: "right secret", args: []string{"-secret", secret, token}, verified: true},
+		{name: "wrong secret", args: []string{"-secret", "other", token}, reason: "signature is invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := decodeToken(context.Background(), &env{}, tt.args)
+			require.NoError(t, err)
+
+			value := res.value.(map[string]any)
+			require.Equal(t, tt.verified, value["verified"])
+			require.Contains(t, res.rows, []string{"id", "42"})
+			require.Contains(t, res.rows, []string{"email", "user@example.com"})
+			require.Contains(t, res.rows, []string{"sid", "session-id"})
+			if tt.reason != "" {
+				require.Contains(t, value["verify_error"], tt.reason)
+			} else {
+				require.NotContains(t, value, "verify_error")
+			}
+		})
+	}
+
+	_, err = decodeToken(context.Background(), &env{}, []string{"not a token"})
+	require.Error(t, err)
+}
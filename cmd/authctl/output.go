@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// result is the output of a command, printed as a table with a header or as JSON
+type result struct {
+	columns []string
+	rows    [][]string
+	// value is encoded for JSON output, the rows are used if it is nil
+	value any
+}
+
+func (r result) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(r.columns) > 0 {
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(r.columns, "\t")))
+	}
+	for _, row := range r.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func (r result) writeJSON(w io.Writer) error {
+	value := r.value
+	if value == nil {
+		records := make([]map[string]string, 0, len(r.rows))
+		for _, row := range r.rows {
+			record := make(map[string]string, len(row))
+			for i, column := range r.columns {
+				record[column] = row[i]
+			}
+			records = append(records, record)
+		}
+		value = records
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
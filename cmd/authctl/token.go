@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	jwtgo "github.com/golang-jwt/jwt"
+	"usekit-auth/internal/lib/jwt"
+	"usekit-auth/internal/storage/sqlite"
+)
+
+// decodeToken prints a token without calling the server. The signature and expiration are checked
+// with -secret or with the secret of the app_id claim in the storage, otherwise verified is false.
+func decodeToken(ctx context.Context, e *env, args []string) (result, error) {
+	flags := flag.NewFlagSet("decode-token", flag.ContinueOnError)
+	secret := flags.String("secret", "", "Secret of the app to verify the signature with")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return result{}, fmt.Errorf("%w: decode-token [-secret S] TOKEN", errUsage)
+	}
+
+	tokenString := flags.Arg(0)
+	if tokenString == "-" {
+		line, err := readLine(os.Stdin)
+		if err != nil {
+			return result{}, fmt.Errorf("failed to read token from stdin: %w", err)
+		}
+		tokenString = line
+	}
+
+	token, _, err := new(jwtgo.Parser).ParseUnverified(tokenString, jwtgo.MapClaims{})
+	if err != nil {
+		return result{}, err
+	}
+	claims := token.Claims.(jwtgo.MapClaims)
+
+	decoded := map[string]any{
+		"header": token.Header,
+		"claims": claims,
+	}
+	rows := [][]string{{"alg", fmt.Sprint(token.Header["alg"])}}
+	for _, name := range []string{"id", "email", "app_id", "sid"} {
+		rows = append(rows, []string{name, claimString(claims[name])})
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		expiresAt := time.Unix(int64(exp), 0)
+		decoded["expires_at"] = expiresAt
+		decoded["expired"] = time.Now().After(expiresAt)
+		rows = append(rows,
+			[]string{"expires_at", expiresAt.Format(time.RFC3339)},
+			[]string{"expired", strconv.FormatBool(time.Now().After(expiresAt))})
+	}
+
+	verified, reason := false, "no -secret or -storage-path to verify the signature with"
+	if *secret != "" || e.storagePath != "" {
+		verified, reason = true, ""
+		if err := verifyToken(ctx, e, tokenString, *secret); err != nil {
+			verified, reason = false, err.Error()
+		}
+	}
+	decoded["verified"] = verified
+	rows = append(rows, []string{"verified", strconv.FormatBool(verified)})
+	if reason != "" {
+		decoded["verify_error"] = reason
+		rows = append(rows, []string{"verify_error", reason})
+	}
+
+	return result{
+		columns: []string{"field", "value"},
+		rows:    rows,
+		value:   decoded,
+	}, nil
+}
+
+func verifyToken(ctx context.Context, e *env, tokenString, secret string) error {
+	if secret != "" {
+		_, err := jwt.ParseToken(tokenString, func(int) (string, error) { return secret, nil })
+		return err
+	}
+
+	storage, err := sqlite.New(e.storagePath)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	_, err = jwt.ParseToken(tokenString, func(appId int) (string, error) {
+		app, err := storage.App(ctx, appId)
+		if err != nil {
+			return "", err
+		}
+		return app.Secret, nil
+	})
+	return err
+}
+
+// claimString formats a JSON claim, numbers are decoded as float64 and printed without exponent
+func claimString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+// отчет о пользователях, чьи email не в канонической форме или совпадают после нормализации
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/lib/emailaddr"
+	"usekit-auth/internal/storage"
+	"usekit-auth/internal/storage/sqlite"
+)
+
+const batchSize = 1000
+
+// userStorage is implemented by sqlite.Storage
+type userStorage interface {
+	ListUsers(ctx context.Context, filter models.UserFilter, cursor *models.UserCursor, limit int) ([]models.User, error)
+	SetUserEmail(ctx context.Context, id int64, email string) error
+}
+
+func main() {
+	var storagePath string
+	var backfill bool
+	flag.StringVar(&storagePath, "storage-path", "", "Path to the storage")
+	flag.BoolVar(&backfill, "backfill", false, "Rewrite emails that are not in the canonical form and do not collide with other accounts")
+	flag.Parse()
+
+	if storagePath == "" {
+		panic("storage-path is required")
+	}
+
+	db, err := sqlite.New(storagePath)
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	r, err := scan(ctx, db)
+	if err != nil {
+		panic(err)
+	}
+	printReport(os.Stdout, r)
+
+	left := len(r.nonCanonical)
+	if backfill {
+		left, err = backfillEmails(ctx, os.Stdout, db, r.nonCanonical)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// lookups compare emails exactly, users with colliding or non-canonical emails can not log in
+	if len(r.groups) > 0 || left > 0 {
+		os.Exit(1)
+	}
+}
+
+type group struct {
+	canonical string
+	users     []models.User
+}
+
+// rename is a user whose stored email differs from its canonical form
+type rename struct {
+	user      models.User
+	canonical string
+}
+
+type report struct {
+	// groups of users whose emails have the same canonical form, they have to be resolved manually
+	groups []group
+	// users whose email can not be normalized
+	invalid []models.User
+	// users with a non-canonical email that does not collide with other users
+	nonCanonical []rename
+}
+
+// scan reads all users and finds emails that collide after normalization, can not be normalized
+// or are stored not in the canonical form. Groups are ordered by their oldest account,
+// users by creation time. Erased users are skipped.
+func scan(ctx context.Context, users userStorage) (report, error) {
+	byEmail := map[string][]models.User{}
+	var r report
+
+	var cursor *models.UserCursor
+	for {
+		batch, err := users.ListUsers(ctx, models.UserFilter{}, cursor, batchSize)
+		if err != nil {
+			return report{}, err
+		}
+
+		for _, user := range batch {
+			if user.Status == models.UserStatusDeleted {
+				continue
+			}
+			canonical, err := emailaddr.Normalize(user.Email)
+			if err != nil {
+				r.invalid = append(r.invalid, user)
+				continue
+			}
+			byEmail[canonical] = append(byEmail[canonical], user)
+		}
+
+		if len(batch) < batchSize {
+			break
+		}
+		last := batch[len(batch)-1]
+		cursor = &models.UserCursor{CreatedAt: last.CreatedAt, Id: last.Id}
+	}
+
+	for canonical, users := range byEmail {
+		switch {
+		case len(users) > 1:
+			r.groups = append(r.groups, group{canonical: canonical, users: users})
+		case users[0].Email != canonical:
+			r.nonCanonical = append(r.nonCanonical, rename{user: users[0], canonical: canonical})
+		}
+	}
+	slices.SortFunc(r.groups, func(a, b group) int {
+		if c := a.users[0].CreatedAt.Compare(b.users[0].CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.canonical, b.canonical)
+	})
+	slices.SortFunc(r.nonCanonical, func(a, b rename) int {
+		if c := a.user.CreatedAt.Compare(b.user.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.user.Id, b.user.Id)
+	})
+
+	return r, nil
+}
+
+func printReport(w io.Writer, r report) {
+	for _, user := range r.invalid {
+		fmt.Fprintf(w, "invalid email: id=%d email=%q status=%s\n", user.Id, user.Email, user.Status)
+	}
+	for _, rn := range r.nonCanonical {
+		fmt.Fprintf(w, "non-canonical email: id=%d email=%q canonical=%q\n", rn.user.Id, rn.user.Email, rn.canonical)
+	}
+	for _, group := range r.groups {
+		fmt.Fprintf(w, "%s (%d accounts)\n", group.canonical, len(group.users))
+		for _, user := range group.users {
+			fmt.Fprintf(w, "  id=%d email=%q status=%s admin=%t created_at=%s\n",
+				user.Id, user.Email, user.Status, user.IsAdmin, user.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	fmt.Fprintf(w, "colliding emails: %d, non-canonical emails: %d, invalid emails: %d\n",
+		len(r.groups), len(r.nonCanonical), len(r.invalid))
+}
+
+// backfillEmails stores the canonical form of every email in renames and returns the number
+// of emails left unchanged because another account took the canonical form in the meantime
+func backfillEmails(ctx context.Context, w io.Writer, users userStorage, renames []rename) (int, error) {
+	var rewritten, left int
+	for _, rn := range renames {
+		err := users.SetUserEmail(ctx, rn.user.Id, rn.canonical)
+		switch {
+		case errors.Is(err, storage.ErrUserExists):
+			left++
+			fmt.Fprintf(w, "skipped id=%d: %q is taken by another account\n", rn.user.Id, rn.canonical)
+		case errors.Is(err, storage.ErrUserNotFound):
+			fmt.Fprintf(w, "skipped id=%d: erased\n", rn.user.Id)
+		case err != nil:
+			return 0, err
+		default:
+			rewritten++
+			fmt.Fprintf(w, "rewrote id=%d: %q -> %q\n", rn.user.Id, rn.user.Email, rn.canonical)
+		}
+	}
+	fmt.Fprintf(w, "rewritten emails: %d, left: %d\n", rewritten, left)
+	return left, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/stretchr/testify/require"
+	"usekit-auth/internal/storage"
+	"usekit-auth/internal/storage/sqlite"
+)
+
+// legacyUsers are inserted before emails were normalized, in order of creation
+var legacyUsers = []string{
+	"Alice@Example.COM",
+	"Bob@Bücher.de",
+	" carol@example.com",
+	"ÉVE@example.com",
+	"éve@example.com",
+	"not an email",
+	"dave@example.com",
+}
+
+// newLegacyStorage returns a storage with legacyUsers inserted at schema version 11
+// and then migrated to the latest version
+func newLegacyStorage(t *testing.T) (*sqlite.Storage, *sql.DB) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "auth.db")
+	migrator, err := migrate.New("file://../../migrations", "sqlite3://"+path+"?x-migrations-table=migrations")
+	require.NoError(t, err)
+	t.Cleanup(func() { migrator.Close() })
+	require.NoError(t, migrator.Migrate(11))
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	for i, email := range legacyUsers {
+		createdAt := fmt.Sprintf("2024-01-%02d 12:00:00", i+1)
+		_, err := db.Exec(`INSERT INTO users(email, pass_hash, created_at, updated_at) VALUES(?, x'00', ?, ?)`,
+			email, createdAt, createdAt)
+		require.NoError(t, err)
+	}
+	_, err = db.Exec(`INSERT INTO users(email, pass_hash, status, created_at, updated_at)
+		VALUES('deleted-100@erased.invalid', x'', 'deleted', '2024-02-01 12:00:00', '2024-02-01 12:00:00')`)
+	require.NoError(t, err)
+
+	require.NoError(t, migrator.Up())
+
+	st, err := sqlite.New(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { st.Close() })
+	return st, db
+}
+
+func emails(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT email FROM users ORDER BY id`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var email string
+		require.NoError(t, rows.Scan(&email))
+		result = append(result, email)
+	}
+	require.NoError(t, rows.Err())
+	return result
+}
+
+// the migration lower-cases ASCII letters, everything else is left to emaildedup
+func TestMigration_LowercasesEmails(t *testing.T) {
+	_, db := newLegacyStorage(t)
+
+	require.Equal(t, []string{
+		"alice@example.com",
+		"bob@bücher.de",
+		" carol@example.com",
+		"Éve@example.com",
+		"éve@example.com",
+		"not an email",
+		"dave@example.com",
+		"deleted-100@erased.invalid",
+	}, emails(t, db))
+
+	// the case-insensitive index is kept
+	_, err := db.Exec(`INSERT INTO users(email, pass_hash) VALUES('ALICE@example.com', x'00')`)
+	require.ErrorContains(t, err, "UNIQUE constraint failed")
+}
+
+func TestScan(t *testing.T) {
+	st, _ := newLegacyStorage(t)
+
+	r, err := scan(context.Background(), st)
+	require.NoError(t, err)
+
+	require.Len(t, r.groups, 1)
+	require.Equal(t, "éve@example.com", r.groups[0].canonical)
+	require.Equal(t, []int64{4, 5}, []int64{r.groups[0].users[0].Id, r.groups[0].users[1].Id})
+
+	require.Len(t, r.invalid, 1)
+	require.Equal(t, "not an email", r.invalid[0].Email)
+
+	var renames []string
+	for _, rn := range r.nonCanonical {
+		renames = append(renames, rn.user.Email+" -> "+rn.canonical)
+	}
+	require.Equal(t, []string{
+		"bob@bücher.de -> bob@xn--bcher-kva.de",
+		" carol@example.com -> carol@example.com",
+	}, renames)
+
+	var out bytes.Buffer
+	printReport(&out, r)
+	require.Contains(t, out.String(), "colliding emails: 1, non-canonical emails: 2, invalid emails: 1\n")
+}
+
+func TestBackfillEmails(t *testing.T) {
+	st, db := newLegacyStorage(t)
+	ctx := context.Background()
+
+	r, err := scan(ctx, st)
+	require.NoError(t, err)
+
+	// the canonical form of carol's email is registered after the scan
+	_, err = st.SaveUser(ctx, "carol@example.com", []byte{0}, 0)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	left, err := backfillEmails(ctx, &out, st, r.nonCanonical)
+	require.NoError(t, err)
+	require.Equal(t, 1, left)
+	require.Contains(t, out.String(), "rewritten emails: 1, left: 1\n")
+
+	require.Equal(t, "bob@xn--bcher-kva.de", emails(t, db)[1])
+	user, err := st.User(ctx, "bob@xn--bcher-kva.de")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), user.Id)
+
+	// lookups are exact now
+	_, err = st.User(ctx, "Alice@example.com")
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var errLintFailed = errors.New("migrations lint failed")
+
+// lint applies every migration up, down and up again on a scratch database
+// and checks that down restores the previous schema and that re-applying up
+// produces the same schema as the first run.
+func lint(w io.Writer, sourceURL, migrationsTable string) error {
+	dir, err := os.MkdirTemp("", "migrator-lint-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	scratchPath := filepath.Join(dir, "lint.db")
+
+	migrator, err := migrate.New(
+		sourceURL,
+		fmt.Sprintf("sqlite3://%s?x-migrations-table=%s", scratchPath, migrationsTable),
+	)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	db, err := sql.Open("sqlite3", scratchPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	src, err := source.Open(sourceURL)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	versions, err := sourceVersions(src)
+	if err != nil {
+		return err
+	}
+
+	var sqliteVersion string
+	if err := db.QueryRow(`SELECT sqlite_version()`).Scan(&sqliteVersion); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "linting %d migration(s) against SQLite %s\n", len(versions), sqliteVersion)
+
+	before, err := schemaOf(db, migrationsTable)
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, version := range versions {
+		identifier, err := identifierOf(src, version)
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%d_%s", version, identifier)
+
+		problems, after, err := lintVersion(migrator, src, db, migrationsTable, version, before)
+		if err != nil {
+			fmt.Fprintf(w, "  FAIL %s: %v\n", name, err)
+			return errLintFailed
+		}
+		if len(problems) == 0 {
+			fmt.Fprintf(w, "  ok   %s\n", name)
+		}
+		for _, problem := range problems {
+			fmt.Fprintf(w, "  FAIL %s: %s\n", name, problem)
+			failed = true
+		}
+		before = after
+	}
+
+	if failed {
+		return errLintFailed
+	}
+	return nil
+}
+
+// lintVersion runs up, down, up for a single version. Execution errors and an
+// incomplete down stop the lint since the scratch database is left in an unknown
+// state; drift on re-apply is returned as a problem so the remaining migrations
+// can still be checked.
+func lintVersion(
+	migrator *migrate.Migrate,
+	src source.Driver,
+	db *sql.DB,
+	migrationsTable string,
+	version uint,
+	before []string,
+) ([]string, []string, error) {
+	body, _, err := src.ReadDown(version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("missing down migration: %w", err)
+	}
+	body.Close()
+
+	if err := migrator.Steps(1); err != nil {
+		return nil, nil, fmt.Errorf("up: %w", err)
+	}
+	afterUp, err := schemaOf(db, migrationsTable)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := migrator.Steps(-1); err != nil {
+		return nil, nil, fmt.Errorf("down is not reversible on SQLite: %w", err)
+	}
+	afterDown, err := schemaOf(db, migrationsTable)
+	if err != nil {
+		return nil, nil, err
+	}
+	// re-applying up on top of an incompletely reverted schema would fail with
+	// a less helpful error, so a bad down stops the lint here
+	if diff := schemaDiff(before, afterDown); diff != "" {
+		return nil, nil, fmt.Errorf("down does not restore the previous schema:\n%s", diff)
+	}
+
+	if err := migrator.Steps(1); err != nil {
+		return nil, nil, fmt.Errorf("up after down: %w", err)
+	}
+	afterReUp, err := schemaOf(db, migrationsTable)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var problems []string
+	if diff := schemaDiff(afterUp, afterReUp); diff != "" {
+		problems = append(problems, "schema drifts when up is re-applied after down:\n"+diff)
+	}
+
+	return problems, afterReUp, nil
+}
+
+// schemaOf describes tables, columns, indexes, views and triggers as sorted lines.
+// Structure is read through pragmas rather than sqlite_master.sql because
+// ALTER TABLE rewrites the stored statement text.
+func schemaOf(db *sql.DB, migrationsTable string) ([]string, error) {
+	rows, err := db.Query(
+		`SELECT type, name, tbl_name, COALESCE(sql, '') FROM sqlite_master
+		WHERE name NOT LIKE 'sqlite_%' AND tbl_name != ?`,
+		migrationsTable,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	type object struct{ kind, name, table, sql string }
+	var objects []object
+	for rows.Next() {
+		var o object
+		if err := rows.Scan(&o.kind, &o.name, &o.table, &o.sql); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var schema []string
+	for _, o := range objects {
+		switch o.kind {
+		case "table":
+			columns, err := columnsOf(db, o.name)
+			if err != nil {
+				return nil, err
+			}
+			schema = append(schema, columns...)
+			indexes, err := indexesOf(db, o.name)
+			if err != nil {
+				return nil, err
+			}
+			schema = append(schema, indexes...)
+		case "view", "trigger":
+			schema = append(schema, fmt.Sprintf("%s %s: %s", o.kind, o.name, strings.Join(strings.Fields(o.sql), " ")))
+		}
+	}
+
+	slices.Sort(schema)
+	return schema, nil
+}
+
+func columnsOf(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query(`SELECT cid, name, type, "notnull", COALESCE(dflt_value, 'NULL'), pk FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ, dflt string
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, fmt.Sprintf(
+			"table %s column %d %s %s notnull=%d default=%s pk=%d",
+			table, cid, name, strings.ToUpper(typ), notNull, dflt, pk,
+		))
+	}
+	return columns, rows.Err()
+}
+
+func indexesOf(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query(`SELECT name, "unique", origin, partial FROM pragma_index_list(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+
+	type index struct {
+		name, origin    string
+		unique, partial int
+	}
+	var list []index
+	for rows.Next() {
+		var i index
+		if err := rows.Scan(&i.name, &i.unique, &i.origin, &i.partial); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		list = append(list, i)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var indexes []string
+	for _, i := range list {
+		columns, err := indexColumnsOf(db, i.name)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, fmt.Sprintf(
+			"table %s index %s unique=%d origin=%s partial=%d (%s)",
+			table, i.name, i.unique, i.origin, i.partial, strings.Join(columns, ", "),
+		))
+	}
+	return indexes, nil
+}
+
+func indexColumnsOf(db *sql.DB, index string) ([]string, error) {
+	rows, err := db.Query(`SELECT COALESCE(name, '<expr>') FROM pragma_index_info(?) ORDER BY seqno`, index)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// schemaDiff returns lines present in only one of the schemas, prefixed with - or +.
+func schemaDiff(want, got []string) string {
+	var b strings.Builder
+	for _, line := range want {
+		if !slices.Contains(got, line) {
+			fmt.Fprintf(&b, "      - %s\n", line)
+		}
+	}
+	for _, line := range got {
+		if !slices.Contains(want, line) {
+			fmt.Fprintf(&b, "      + %s\n", line)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// wantOutput are substrings of the output
+		wantOutput []string
+		wantErr    bool
+	}{
+		{
+			name:       "reversible",
+			files:      testMigrations,
+			wantOutput: []string{"linting 3 migration(s)", "  ok   1_create_users\n", "  ok   2_create_apps\n", "  ok   10_add_email_index\n"},
+		},
+		{
+			name: "missing down",
+			files: map[string]string{
+				"1_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+				"1_create_users.down.sql": "DROP TABLE users;",
+				"2_create_apps.up.sql":    "CREATE TABLE apps (id INTEGER PRIMARY KEY);",
+			},
+			wantOutput: []string{"  ok   1_create_users\n", "  FAIL 2_create_apps: missing down migration"},
+			wantErr:    true,
+		},
+		{
+			name: "down does not restore the schema",
+			files: map[string]string{
+				"1_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY); CREATE INDEX idx_id ON users (id);",
+				"1_create_users.down.sql": "DROP INDEX idx_id;",
+			},
+			wantOutput: []string{"FAIL 1_create_users: down does not restore the previous schema", "+ table users column 0 id INTEGER"},
+			wantErr:    true,
+		},
+		{
+			name: "down fails",
+			files: map[string]string{
+				"1_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+				"1_create_users.down.sql": "DROP TABLE missing;",
+			},
+			wantOutput: []string{"FAIL 1_create_users: down is not reversible on SQLite"},
+			wantErr:    true,
+		},
+		{
+			name: "up fails",
+			files: map[string]string{
+				"1_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY",
+				"1_create_users.down.sql": "DROP TABLE users;",
+			},
+			wantOutput: []string{"FAIL 1_create_users: up:"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := lint(&out, writeMigrations(t, tt.files), "migrations")
+			if tt.wantErr {
+				require.ErrorIs(t, err, errLintFailed)
+			} else {
+				require.NoError(t, err)
+			}
+			for _, want := range tt.wantOutput {
+				require.Contains(t, out.String(), want)
+			}
+		})
+	}
+}
+
+// the repository migrations have to pass the lint themselves
+func TestLint_RepositoryMigrations(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, lint(&out, "file://../../migrations", "migrations"), out.String())
+}
+
+func TestSchemaDiff(t *testing.T) {
+	require.Empty(t, schemaDiff([]string{"a", "b"}, []string{"a", "b"}))
+	require.Equal(t, "      - b\n      + c", schemaDiff([]string{"a", "b"}, []string{"a", "c"}))
+}
@@ -4,28 +4,73 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"strconv"
+
 	// библиотека для миграций
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
 	// драйвер для выполнения миграций SQLite3
 	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
 	// драйвер для получения миграций из файлов
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
+const usage = `Usage: migrator [flags] [command] [args]
+
+Commands:
+  up [N]      apply all (or the next N) pending migrations (default)
+  down N      roll back the last N applied migrations
+  goto V      migrate up or down to version V
+  version     print the current version and dirty flag
+  force V     set version V without running migrations (repairs a dirty state),
+              -1 clears the version as if nothing was applied
+  status      list applied and pending migrations
+  lint        check on a scratch database that every migration is reversible
+              (up, down, up) and does not drift; storage-path is not used
+
+Flags:
+`
+
 func main() {
 	var storagePath, migrationsPath, migrationsTable string
+	var dryRun bool
 	flag.StringVar(&storagePath, "storage-path", "", "Path to the storage")
 	flag.StringVar(&migrationsPath, "migrations-path", "", "Path to a directory containing the migration files")
 	flag.StringVar(&migrationsTable, "migrations-table", "migrations", "Path to a tables containing the migration files")
+	flag.BoolVar(&dryRun, "dry-run", false, "Print the SQL of migrations that would be applied instead of running them")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	if storagePath == "" {
-		panic("storage-path is required")
+	command, args := "up", []string(nil)
+	if flag.NArg() > 0 {
+		command, args = flag.Arg(0), flag.Args()[1:]
 	}
+
 	if migrationsPath == "" {
 		panic("migrations-path is required")
 	}
 
+	if command == "lint" {
+		if err := lint(os.Stdout, "file://"+migrationsPath, migrationsTable); err != nil {
+			if errors.Is(err, errLintFailed) {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			panic(err)
+		}
+		fmt.Println("Migrations are reversible")
+		return
+	}
+
+	if storagePath == "" {
+		panic("storage-path is required")
+	}
+
 	migrator, err := migrate.New(
 		"file://"+migrationsPath,
 		fmt.Sprintf("sqlite3://%s?x-migrations-table=%s", storagePath, migrationsTable),
@@ -33,14 +78,129 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	defer migrator.Close()
 
-	if err := migrator.Up(); err != nil {
+	if err := run(os.Stdout, migrator, "file://"+migrationsPath, command, args, dryRun); err != nil {
 		if errors.Is(err, migrate.ErrNoChange) {
 			fmt.Println("No migrations found")
 			return
 		}
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, err)
+			flag.Usage()
+			os.Exit(2)
+		}
 		panic(err)
 	}
+}
+
+var errUsage = errors.New("invalid usage")
+
+func run(w io.Writer, migrator *migrate.Migrate, sourceURL, command string, args []string, dryRun bool) error {
+	switch command {
+	case "up":
+		limit, err := optionalCount(args)
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			return printPlan(w, migrator, sourceURL, planUp, limit)
+		}
+		if limit == 0 {
+			err = migrator.Up()
+		} else {
+			err = migrator.Steps(limit)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "Migrations applied successfully")
+	case "down":
+		limit, err := requiredNumber(args, "down N")
+		if err != nil {
+			return err
+		}
+		if limit == 0 {
+			return fmt.Errorf("%w: down requires N > 0", errUsage)
+		}
+		if dryRun {
+			return printPlan(w, migrator, sourceURL, planDown, limit)
+		}
+		if err := migrator.Steps(-limit); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Rolled back %d migration(s)\n", limit)
+	case "goto":
+		version, err := requiredNumber(args, "goto V")
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			return printGotoPlan(w, migrator, sourceURL, uint(version))
+		}
+		if err := migrator.Migrate(uint(version)); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Migrated to version %d\n", version)
+	case "version":
+		version, dirty, err := migrator.Version()
+		if err != nil {
+			if errors.Is(err, migrate.ErrNilVersion) {
+				fmt.Fprintln(w, "No migrations applied")
+				return nil
+			}
+			return err
+		}
+		fmt.Fprintf(w, "%d (dirty: %t)\n", version, dirty)
+	case "force":
+		version, err := forceVersion(args)
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			fmt.Fprintf(w, "Would force version %d\n", version)
+			return nil
+		}
+		if err := migrator.Force(version); err != nil {
+			return err
+		}
+		if version == database.NilVersion {
+			fmt.Fprintln(w, "Cleared the version, no migrations are recorded as applied")
+			return nil
+		}
+		fmt.Fprintf(w, "Forced version %d\n", version)
+	case "status":
+		return printStatus(w, migrator, sourceURL)
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, command)
+	}
+
+	return nil
+}
+
+func optionalCount(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	return requiredNumber(args, "up [N]")
+}
 
-	fmt.Println("Migrations applied successfully")
+func requiredNumber(args []string, form string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("%w: expected %s", errUsage, form)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %q is not a non-negative number", errUsage, args[0])
+	}
+	return n, nil
+}
+
+// forceVersion is requiredNumber that also accepts -1, database.NilVersion, which
+// clears the version when the very first migration failed and left it dirty
+func forceVersion(args []string) (int, error) {
+	if len(args) == 1 && args[0] == strconv.Itoa(database.NilVersion) {
+		return database.NilVersion, nil
+	}
+	return requiredNumber(args, "force V")
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiredNumber(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{args: []string{"3"}, want: 3},
+		{args: []string{"0"}, want: 0},
+		{args: nil, wantErr: true},
+		{args: []string{"1", "2"}, wantErr: true},
+		{args: []string{"-1"}, wantErr: true},
+		{args: []string{"x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := requiredNumber(tt.args, "down N")
+		if tt.wantErr {
+			require.ErrorIs(t, err, errUsage, "%v", tt.args)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tt.want, got)
+	}
+}
+
+func TestForceVersion(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{args: []string{"12"}, want: 12},
+		{args: []string{"0"}, want: 0},
+		{args: []string{"-1"}, want: -1},
+		{args: []string{"-2"}, wantErr: true},
+		{args: []string{"-01"}, wantErr: true},
+		{args: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := forceVersion(tt.args)
+		if tt.wantErr {
+			require.ErrorIs(t, err, errUsage, "%v", tt.args)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tt.want, got)
+	}
+}
+
+// a failed first migration leaves version 1 dirty, force -1 returns the database to no version
+func TestRun_ForceNilVersion(t *testing.T) {
+	broken := map[string]string{
+		"1_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY); SELECT * FROM missing;",
+		"1_create_users.down.sql": "DROP TABLE users;",
+	}
+	sourceURL := writeMigrations(t, broken)
+	migrator := newTestMigrator(t, sourceURL)
+
+	var out bytes.Buffer
+	require.Error(t, run(&out, migrator, sourceURL, "up", nil, false))
+	version, dirty, err := migrator.Version()
+	require.NoError(t, err)
+	require.Equal(t, uint(1), version)
+	require.True(t, dirty)
+
+	out.Reset()
+	require.NoError(t, run(&out, migrator, sourceURL, "force", []string{"-1"}, true))
+	require.Equal(t, "Would force version -1\n", out.String())
+	_, dirty, err = migrator.Version()
+	require.NoError(t, err)
+	require.True(t, dirty, "dry run must not change the version")
+
+	out.Reset()
+	require.NoError(t, run(&out, migrator, sourceURL, "force", []string{"-1"}, false))
+	require.Equal(t, "Cleared the version, no migrations are recorded as applied\n", out.String())
+	_, _, err = migrator.Version()
+	require.ErrorIs(t, err, migrate.ErrNilVersion)
+
+	out.Reset()
+	require.NoError(t, run(&out, migrator, sourceURL, "version", nil, false))
+	require.Equal(t, "No migrations applied\n", out.String())
+}
+
+func TestRun(t *testing.T) {
+	sourceURL := writeMigrations(t, testMigrations)
+	migrator := newTestMigrator(t, sourceURL)
+
+	var out bytes.Buffer
+	require.NoError(t, run(&out, migrator, sourceURL, "up", []string{"2"}, false))
+	require.NoError(t, run(&out, migrator, sourceURL, "version", nil, false))
+	require.Contains(t, out.String(), "2 (dirty: false)\n")
+
+	require.NoError(t, run(&out, migrator, sourceURL, "goto", []string{"10"}, false))
+	require.NoError(t, run(&out, migrator, sourceURL, "down", []string{"3"}, false))
+	_, _, err := migrator.Version()
+	require.ErrorIs(t, err, migrate.ErrNilVersion)
+
+	require.NoError(t, run(&out, migrator, sourceURL, "force", []string{"2"}, false))
+	version, dirty, err := migrator.Version()
+	require.NoError(t, err)
+	require.Equal(t, uint(2), version)
+	require.False(t, dirty)
+
+	require.ErrorIs(t, run(&out, migrator, sourceURL, "down", []string{"0"}, false), errUsage)
+	require.ErrorIs(t, run(&out, migrator, sourceURL, "sideways", nil, false), errUsage)
+	require.ErrorIs(t, run(&out, migrator, sourceURL, "force", nil, false), errUsage)
+}
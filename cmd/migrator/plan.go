@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+type direction int
+
+const (
+	planUp direction = iota
+	planDown
+)
+
+func (d direction) String() string {
+	if d == planDown {
+		return "down"
+	}
+	return "up"
+}
+
+// step is a single migration file that would be executed.
+type step struct {
+	version   uint
+	direction direction
+}
+
+// sourceVersions returns all versions available in the migrations source in ascending order.
+func sourceVersions(src source.Driver) ([]uint, error) {
+	version, err := src.First()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	versions := []uint{version}
+	for {
+		version, err = src.Next(version)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return versions, nil
+			}
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+}
+
+// currentVersion returns the applied version, zero if nothing has been applied yet.
+func currentVersion(migrator *migrate.Migrate) (uint, bool, error) {
+	version, dirty, err := migrator.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+func printStatus(w io.Writer, migrator *migrate.Migrate, sourceURL string) error {
+	src, err := source.Open(sourceURL)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	versions, err := sourceVersions(src)
+	if err != nil {
+		return err
+	}
+	current, dirty, err := currentVersion(migrator)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "version: %d (dirty: %t)\n", current, dirty)
+	for _, version := range versions {
+		state := "pending"
+		switch {
+		case version == current && dirty:
+			state = "dirty"
+		case version <= current:
+			state = "applied"
+		}
+
+		identifier, err := identifierOf(src, version)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "  %-8s %d_%s\n", state, version, identifier)
+	}
+
+	return nil
+}
+
+// printPlan prints the SQL of up to limit migrations in the given direction; zero limit means all.
+func printPlan(w io.Writer, migrator *migrate.Migrate, sourceURL string, dir direction, limit int) error {
+	src, err := source.Open(sourceURL)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	versions, err := sourceVersions(src)
+	if err != nil {
+		return err
+	}
+	current, _, err := currentVersion(migrator)
+	if err != nil {
+		return err
+	}
+
+	var steps []step
+	if dir == planUp {
+		for _, version := range versions {
+			if version > current {
+				steps = append(steps, step{version: version, direction: planUp})
+			}
+		}
+	} else {
+		for i := len(versions) - 1; i >= 0; i-- {
+			if versions[i] <= current {
+				steps = append(steps, step{version: versions[i], direction: planDown})
+			}
+		}
+	}
+	if limit > 0 {
+		if limit > len(steps) {
+			return fmt.Errorf("requested %d migration(s) %s, only %d available", limit, dir, len(steps))
+		}
+		steps = steps[:limit]
+	}
+
+	return printSteps(w, src, steps)
+}
+
+func printGotoPlan(w io.Writer, migrator *migrate.Migrate, sourceURL string, target uint) error {
+	src, err := source.Open(sourceURL)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	versions, err := sourceVersions(src)
+	if err != nil {
+		return err
+	}
+	current, _, err := currentVersion(migrator)
+	if err != nil {
+		return err
+	}
+
+	known := false
+	for _, version := range versions {
+		known = known || version == target
+	}
+	if !known {
+		return fmt.Errorf("version %d not found in migrations source", target)
+	}
+
+	var steps []step
+	if target >= current {
+		for _, version := range versions {
+			if version > current && version <= target {
+				steps = append(steps, step{version: version, direction: planUp})
+			}
+		}
+	} else {
+		for i := len(versions) - 1; i >= 0; i-- {
+			if versions[i] <= current && versions[i] > target {
+				steps = append(steps, step{version: versions[i], direction: planDown})
+			}
+		}
+	}
+
+	return printSteps(w, src, steps)
+}
+
+func printSteps(w io.Writer, src source.Driver, steps []step) error {
+	if len(steps) == 0 {
+		return migrate.ErrNoChange
+	}
+
+	for _, s := range steps {
+		read := src.ReadUp
+		if s.direction == planDown {
+			read = src.ReadDown
+		}
+
+		body, identifier, err := read(s.version)
+		if err != nil {
+			return fmt.Errorf("read %s migration %d: %w", s.direction, s.version, err)
+		}
+		query, err := io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "-- %d_%s (%s)\n%s\n\n", s.version, identifier, s.direction, query)
+	}
+
+	return nil
+}
+
+func identifierOf(src source.Driver, version uint) (string, error) {
+	body, identifier, err := src.ReadUp(version)
+	if err != nil {
+		return "", fmt.Errorf("read migration %d: %w", version, err)
+	}
+	body.Close()
+	return identifier, nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/stretchr/testify/require"
+)
+
+var testMigrations = map[string]string{
+	"1_create_users.up.sql":       "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL);",
+	"1_create_users.down.sql":     "DROP TABLE users;",
+	"2_create_apps.up.sql":        "CREATE TABLE apps (id INTEGER PRIMARY KEY, name TEXT NOT NULL);",
+	"2_create_apps.down.sql":      "DROP TABLE apps;",
+	"10_add_email_index.up.sql":   "CREATE UNIQUE INDEX idx_email ON users (email);",
+	"10_add_email_index.down.sql": "DROP INDEX idx_email;",
+}
+
+// writeMigrations writes files into a temporary directory and returns its source URL
+func writeMigrations(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, body := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600))
+	}
+	return "file://" + dir
+}
+
+// newTestMigrator returns a migrator for sourceURL on a new database in a temporary directory
+func newTestMigrator(t *testing.T, sourceURL string) *migrate.Migrate {
+	t.Helper()
+
+	migrator, err := migrate.New(sourceURL, "sqlite3://"+filepath.Join(t.TempDir(), "test.db")+"?x-migrations-table=migrations")
+	require.NoError(t, err)
+	t.Cleanup(func() { migrator.Close() })
+	return migrator
+}
+
+func TestSourceVersions(t *testing.T) {
+	src, err := source.Open(writeMigrations(t, testMigrations))
+	require.NoError(t, err)
+	defer src.Close()
+
+	versions, err := sourceVersions(src)
+	require.NoError(t, err)
+	require.Equal(t, []uint{1, 2, 10}, versions)
+
+	empty, err := source.Open(writeMigrations(t, nil))
+	require.NoError(t, err)
+	defer empty.Close()
+
+	versions, err = sourceVersions(empty)
+	require.NoError(t, err)
+	require.Empty(t, versions)
+}
+
+func TestPrintStatus(t *testing.T) {
+	sourceURL := writeMigrations(t, testMigrations)
+	migrator := newTestMigrator(t, sourceURL)
+
+	var out bytes.Buffer
+	require.NoError(t, printStatus(&out, migrator, sourceURL))
+	require.Equal(t, "version: 0 (dirty: false)\n"+
+		"  pending  1_create_users\n"+
+		"  pending  2_create_apps\n"+
+		"  pending  10_add_email_index\n", out.String())
+
+	require.NoError(t, migrator.Steps(2))
+	out.Reset()
+	require.NoError(t, printStatus(&out, migrator, sourceURL))
+	require.Equal(t, "version: 2 (dirty: false)\n"+
+		"  applied  1_create_users\n"+
+		"  applied  2_create_apps\n"+
+		"  pending  10_add_email_index\n", out.String())
+}
+
+func TestPrintStatus_Dirty(t *testing.T) {
+	files := map[string]string{}
+	for name, body := range testMigrations {
+		files[name] = body
+	}
+	files["2_create_apps.up.sql"] = "CREATE TABLE apps (id INTEGER PRIMARY KEY); SELECT * FROM missing;"
+
+	sourceURL := writeMigrations(t, files)
+	migrator := newTestMigrator(t, sourceURL)
+	require.Error(t, migrator.Up())
+
+	var out bytes.Buffer
+	require.NoError(t, printStatus(&out, migrator, sourceURL))
+	require.Equal(t, "version: 2 (dirty: true)\n"+
+		"  applied  1_create_users\n"+
+		"  dirty    2_create_apps\n"+
+		"  pending  10_add_email_index\n", out.String())
+}
+
+func TestPrintPlan(t *testing.T) {
+	sourceURL := writeMigrations(t, testMigrations)
+
+	tests := []struct {
+		name    string
+		applied int
+		dir     direction
+		limit   int
+		want    []string
+		wantErr error
+		anyErr  bool
+	}{
+		{name: "up all", dir: planUp, want: []string{"-- 1_create_users (up)", "-- 2_create_apps (up)", "-- 10_add_email_index (up)"}},
+		{name: "up limited", dir: planUp, limit: 2, want: []string{"-- 1_create_users (up)", "-- 2_create_apps (up)"}},
+		{name: "up from applied", applied: 2, dir: planUp, want: []string{"-- 10_add_email_index (up)"}},
+		{name: "up nothing pending", applied: 3, dir: planUp, wantErr: migrate.ErrNoChange},
+		{name: "up more than pending", applied: 2, dir: planUp, limit: 2, anyErr: true},
+		{name: "down one", applied: 3, dir: planDown, limit: 1, want: []string{"-- 10_add_email_index (down)"}},
+		{name: "down all", applied: 2, dir: planDown, want: []string{"-- 2_create_apps (down)", "-- 1_create_users (down)"}},
+		{name: "down more than applied", applied: 1, dir: planDown, limit: 2, anyErr: true},
+		{name: "down nothing applied", dir: planDown, limit: 0, wantErr: migrate.ErrNoChange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrator := newTestMigrator(t, sourceURL)
+			if tt.applied > 0 {
+				require.NoError(t, migrator.Steps(tt.applied))
+			}
+
+			var out bytes.Buffer
+			err := printPlan(&out, migrator, sourceURL, tt.dir, tt.limit)
+			switch {
+			case tt.wantErr != nil:
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			case tt.anyErr:
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, headers(out.String()))
+
+			// printing the plan must not apply anything
+			version, _, err := currentVersion(migrator)
+			require.NoError(t, err)
+			require.Equal(t, []uint{0, 1, 2, 10}[tt.applied], version)
+		})
+	}
+}
+
+func TestPrintPlan_IncludesSQL(t *testing.T) {
+	sourceURL := writeMigrations(t, testMigrations)
+	migrator := newTestMigrator(t, sourceURL)
+
+	var out bytes.Buffer
+	require.NoError(t, printPlan(&out, migrator, sourceURL, planUp, 1))
+	require.Equal(t, "-- 1_create_users (up)\n"+testMigrations["1_create_users.up.sql"]+"\n\n", out.String())
+}
+
+func TestPrintGotoPlan(t *testing.T) {
+	sourceURL := writeMigrations(t, testMigrations)
+
+	tests := []struct {
+		name    string
+		applied int
+		target  uint
+		want    []string
+		wantErr error
+		anyErr  bool
+	}{
+		{name: "up", target: 2, want: []string{"-- 1_create_users (up)", "-- 2_create_apps (up)"}},
+		{name: "down", applied: 3, target: 1, want: []string{"-- 10_add_email_index (down)", "-- 2_create_apps (down)"}},
+		{name: "current", applied: 2, target: 2, wantErr: migrate.ErrNoChange},
+		{name: "unknown version", target: 3, anyErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrator := newTestMigrator(t, sourceURL)
+			if tt.applied > 0 {
+				require.NoError(t, migrator.Steps(tt.applied))
+			}
+
+			var out bytes.Buffer
+			err := printGotoPlan(&out, migrator, sourceURL, tt.target)
+			switch {
+			case tt.wantErr != nil:
+				require.ErrorIs(t, err, tt.wantErr)
+			case tt.anyErr:
+				require.Error(t, err)
+			default:
+				require.NoError(t, err)
+				require.Equal(t, tt.want, headers(out.String()))
+			}
+		})
+	}
+}
+
+// headers returns the "-- <version>_<name> (<direction>)" lines of a printed plan
+func headers(plan string) []string {
+	var lines []string
+	for _, line := range bytes.Split([]byte(plan), []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("-- ")) {
+			lines = append(lines, string(line))
+		}
+	}
+	return lines
+}
@@ -3,35 +3,280 @@ package app
 // main app
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/credentials"
 	"log/slog"
 	"time"
+	checkpointapp "usekit-auth/internal/app/checkpoint"
+	erasureapp "usekit-auth/internal/app/erasure"
 	grpcapp "usekit-auth/internal/app/grpc"
+	httpapp "usekit-auth/internal/app/http"
+	readinessapp "usekit-auth/internal/app/readiness"
+	"usekit-auth/internal/audit"
+	"usekit-auth/internal/config"
+	"usekit-auth/internal/gateway"
+	authgrpc "usekit-auth/internal/grpc/auth"
+	"usekit-auth/internal/lib/tlsreload"
+	"usekit-auth/internal/metrics"
 	"usekit-auth/internal/services/auth"
 	"usekit-auth/internal/storage/sqlite"
+	"usekit-auth/internal/tracing"
 )
 
+const serviceName = "usekit-auth"
+
+// telemetryFlushTimeout limits how long Stop waits for spans to be exported
+const telemetryFlushTimeout = 5 * time.Second
+
 type App struct {
+	logger     *slog.Logger
 	GrpcServer *grpcapp.AppGrpc
+	// HttpServer serves metrics and health checks, it is nil when no HTTP port is configured
+	HttpServer *httpapp.AppHttp
+	// GatewayServer serves the HTTP/JSON API, it is nil when no gateway port is configured
+	GatewayServer *httpapp.AppHttp
+	// ReadinessJob marks GrpcServer as serving once storage is reachable and migrated
+	ReadinessJob *readinessapp.AppReadiness
+	ErasureJob   *erasureapp.AppErasure
+	// AuditSink has to be closed after the servers are stopped to flush buffered events
+	AuditSink audit.Sink
+	// CheckpointJob is nil when no audit checkpoint key is configured
+	CheckpointJob *checkpointapp.AppCheckpoint
+	// Tracing has to be shut down after the other components to export their spans
+	Tracing   *tracing.Provider
+	Storage   *sqlite.Storage
+	Passwords Passwords
 }
 
 func New(
 	logger *slog.Logger,
-	grpcPort int,
+	grpcCfg config.GRPCConfig,
+	httpPort int,
+	gatewayPort int,
 	storagePath string,
 	tokenTTL time.Duration,
+	erasureCfg config.ErasureConfig,
+	auditCfg config.AuditConfig,
+	passwordCfg config.PasswordConfig,
+	loginCfg config.LoginConfig,
+	tracingCfg config.TracingConfig,
 ) *App {
+	tracingProvider, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: serviceName,
+		Exporter:    tracingCfg.Exporter,
+		Endpoint:    tracingCfg.Endpoint,
+		Insecure:    tracingCfg.Insecure,
+		FilePath:    tracingCfg.FilePath,
+		SampleRatio: tracingCfg.SampleRatio,
+	})
+	if err != nil {
+		panic(err)
+	}
+
 	// TODO: инициализировать хранилище (storage)
 	storage, err := sqlite.New(storagePath)
 	if err != nil {
 		panic(err)
 	}
 
+	appMetrics := metrics.New()
+	storage.SetQueryObserver(appMetrics)
+
+	auditSink, err := NewAuditSink(logger, storage, auditCfg)
+	if err != nil {
+		panic(err)
+	}
+	auditSink = append(auditSink, appMetrics.AuditSink())
+
+	passwords, err := NewPasswords(logger, passwordCfg)
+	if err != nil {
+		panic(err)
+	}
+
 	// TODO: инициализировать сервисный слой auth
-	authService := auth.New(logger, storage, storage, storage, tokenTTL)
+	authService := auth.New(
+		logger,
+		storage,
+		storage,
+		storage,
+		storage,
+		auditSink,
+		storage,
+		passwords.Policy,
+		appMetrics.InstrumentHasher(passwords.Hasher),
+		passwords.Peppers,
+		tokenTTL,
+		loginCfg.ConstantTime,
+	)
 
-	grpcApp := grpcapp.New(logger, authService, grpcPort)
+	// the gateway calls the same handlers, it is served with the same certificates and client authentication
+	var tlsConfig *tls.Config
+	var grpcCreds credentials.TransportCredentials
+	if grpcCfg.TLS.CertFile != "" || grpcCfg.TLS.KeyFile != "" {
+		tlsConfig, err = newServerTLSConfig(logger, grpcCfg.TLS)
+		if err != nil {
+			panic(err)
+		}
+		grpcCreds = credentials.NewTLS(tlsConfig)
+	} else {
+		logger.Warn("grpc tls is not configured, serving plaintext")
+	}
+
+	grpcApp := grpcapp.New(logger, authService, grpcCfg.Port, appMetrics, grpcCreds, grpcapp.Timeouts{
+		Default: grpcCfg.Timeout,
+		Methods: grpcCfg.MethodTimeouts,
+	}, grpcCfg.Reflection)
+	readinessJob := readinessapp.New(logger, storage, grpcApp, grpcCfg.ReadinessInterval)
+
+	var httpApp *httpapp.AppHttp
+	if httpPort != 0 {
+		httpApp = httpapp.New(logger, "ops", httpPort, httpapp.OpsHandler(appMetrics.Handler(), grpcApp.Health()), nil)
+	}
+
+	var gatewayApp *httpapp.AppHttp
+	if gatewayPort != 0 {
+		gw, err := gateway.New(authgrpc.NewServer(authService), grpcApp.UnaryInterceptor())
+		if err != nil {
+			panic(err)
+		}
+		gatewayApp = httpapp.New(logger, "gateway", gatewayPort, gw, tlsConfig)
+	}
+
+	erasureJob := erasureapp.New(logger, authService, erasureCfg.Retention, erasureCfg.SessionRetention, erasureCfg.Interval)
+
+	var checkpointJob *checkpointapp.AppCheckpoint
+	if auditCfg.CheckpointKey != "" {
+		key, err := audit.ParsePrivateKey(auditCfg.CheckpointKey)
+		if err != nil {
+			panic(err)
+		}
+		checkpointJob = checkpointapp.New(logger, audit.NewCheckpointer(storage, key), auditCfg.CheckpointInterval)
+	} else {
+		logger.Warn("audit checkpoint key is not configured, audit checkpoints are disabled")
+	}
 
 	return &App{
-		GrpcServer: grpcApp,
+		logger:        logger,
+		GrpcServer:    grpcApp,
+		HttpServer:    httpApp,
+		GatewayServer: gatewayApp,
+		ReadinessJob:  readinessJob,
+		ErasureJob:    erasureJob,
+		AuditSink:     auditSink,
+		CheckpointJob: checkpointJob,
+		Tracing:       tracingProvider,
+		Storage:       storage,
+		Passwords:     passwords,
+	}
+}
+
+// Start runs the servers and background jobs. Errors of servers that fail to start
+// or stop serving before Stop is called are sent to the returned channel.
+func (a *App) Start() <-chan error {
+	errs := make(chan error, 3)
+
+	go func() {
+		if err := a.GrpcServer.Run(); err != nil {
+			errs <- err
+		}
+	}()
+	for _, server := range []*httpapp.AppHttp{a.HttpServer, a.GatewayServer} {
+		if server == nil {
+			continue
+		}
+		go func() {
+			if err := server.Run(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	go a.ReadinessJob.Run()
+	go a.ErasureJob.Run()
+	if a.CheckpointJob != nil {
+		go a.CheckpointJob.Run()
 	}
+
+	return errs
+}
+
+// Stop stops the application in order: servers stop accepting requests and drain
+// until ctx is done, then background jobs finish, audit events and spans are flushed
+// and storage is closed. Errors of the cleanup steps are joined.
+func (a *App) Stop(ctx context.Context) error {
+	const op = "app.Stop"
+
+	a.ReadinessJob.Stop()
+	// gRPC server goes first: it marks the service as not serving for health checks
+	a.GrpcServer.Stop(ctx)
+	if a.GatewayServer != nil {
+		a.GatewayServer.Stop(ctx)
+	}
+	if a.HttpServer != nil {
+		a.HttpServer.Stop(ctx)
+	}
+
+	a.ErasureJob.Stop()
+	if a.CheckpointJob != nil {
+		a.CheckpointJob.Stop()
+	}
+
+	var errs []error
+	if err := a.AuditSink.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close audit sink: %w", err))
+	}
+
+	// спаны экспортируются даже если время на остановку серверов вышло
+	flushCtx, cancel := context.WithTimeout(context.Background(), telemetryFlushTimeout)
+	defer cancel()
+	if err := a.Tracing.Shutdown(flushCtx); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := a.Passwords.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close breached password list: %w", err))
+	}
+
+	// хранилище закрывается последним: sinks и jobs пишут в него до остановки
+	if err := a.Storage.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.logger.Info("application stopped")
+	return nil
+}
+
+// newServerTLSConfig returns a TLS config reloading certificates from the configured files,
+// client certificates are required or verified only when a client CA file is set
+func newServerTLSConfig(logger *slog.Logger, cfg config.TLSConfig) (*tls.Config, error) {
+	minVersion, err := tlsreload.ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	clientAuth := tls.NoClientCert
+	if cfg.ClientCAFile != "" {
+		switch cfg.ClientAuth {
+		case "require":
+			clientAuth = tls.RequireAndVerifyClientCert
+		case "verify_if_given":
+			clientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("unknown tls client auth %q", cfg.ClientAuth)
+		}
+	}
+
+	reloader, err := tlsreload.New(logger, cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile, cfg.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return reloader.ServerConfig(minVersion, clientAuth), nil
 }
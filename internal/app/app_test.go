@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/stretchr/testify/require"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"usekit-auth/internal/audit"
+	"usekit-auth/internal/config"
+)
+
+// closeCheckingSink runs check when the application closes the audit sink
+type closeCheckingSink struct {
+	audit.Sink
+	check func()
+}
+
+func (s *closeCheckingSink) Close() error {
+	s.check()
+	return s.Sink.Close()
+}
+
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "auth.db")
+	migrator, err := migrate.New("file://../../migrations", "sqlite3://"+path+"?x-migrations-table=migrations")
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+	sourceErr, dbErr := migrator.Close()
+	require.NoError(t, sourceErr)
+	require.NoError(t, dbErr)
+
+	return New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		config.GRPCConfig{ReadinessInterval: time.Hour},
+		0,
+		0,
+		path,
+		time.Hour,
+		config.ErasureConfig{Retention: time.Hour, SessionRetention: time.Hour, Interval: time.Hour},
+		config.AuditConfig{},
+		config.PasswordConfig{MinLength: 8, Hashing: config.HashingConfig{
+			Algorithm: "argon2id", BcryptCost: 4, Argon2Memory: 64, Argon2Iterations: 1, Argon2Parallelism: 1,
+		}},
+		config.LoginConfig{},
+		config.TracingConfig{Exporter: "none"},
+	)
+}
+
+// servers stop serving before audit events are flushed and storage is closed after that
+func TestApp_StopOrder(t *testing.T) {
+	a := newTestApp(t)
+	errs := a.Start()
+
+	serving := func() healthv1.HealthCheckResponse_ServingStatus {
+		resp, err := a.GrpcServer.Health().Check(context.Background(), &healthv1.HealthCheckRequest{})
+		require.NoError(t, err)
+		return resp.GetStatus()
+	}
+	require.Eventually(t, func() bool {
+		return serving() == healthv1.HealthCheckResponse_SERVING
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var sinkClosed bool
+	a.AuditSink = &closeCheckingSink{Sink: a.AuditSink, check: func() {
+		sinkClosed = true
+		require.Equal(t, healthv1.HealthCheckResponse_NOT_SERVING, serving())
+		require.NoError(t, a.Storage.Ready(context.Background()), "storage is closed before the audit sink")
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, a.Stop(ctx))
+
+	require.True(t, sinkClosed)
+	require.ErrorContains(t, a.Storage.Ready(context.Background()), "database is closed")
+	select {
+	case err := <-errs:
+		t.Fatalf("server failed: %v", err)
+	default:
+	}
+}
@@ -0,0 +1,28 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"usekit-auth/internal/audit"
+	"usekit-auth/internal/config"
+)
+
+// NewAuditSink returns the configured audit sinks: storage, which keeps the event chain, file and webhook.
+// Tools changing users use it so their actions are audited the same way as the server's.
+func NewAuditSink(logger *slog.Logger, storage audit.EventSaver, cfg config.AuditConfig) (audit.Multi, error) {
+	const op = "app.NewAuditSink"
+
+	sink := audit.Multi{audit.NewStorageSink(storage)}
+	if cfg.FilePath != "" {
+		fileSink, err := audit.NewFileSink(cfg.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sink = append(sink, fileSink)
+	}
+	if cfg.WebhookURL != "" {
+		sink = append(sink, audit.NewWebhookSink(logger, cfg.WebhookURL, cfg.WebhookTimeout))
+	}
+
+	return sink, nil
+}
@@ -0,0 +1,80 @@
+package checkpointapp
+
+// периодическая подпись последнего события цепочки аудита
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"usekit-auth/internal/domain/models"
+)
+
+type Checkpointer interface {
+	Checkpoint(ctx context.Context) (models.AuditCheckpoint, bool, error)
+}
+
+type AppCheckpoint struct {
+	logger       *slog.Logger
+	checkpointer Checkpointer
+	interval     time.Duration
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+func New(logger *slog.Logger, checkpointer Checkpointer, interval time.Duration) *AppCheckpoint {
+	return &AppCheckpoint{
+		logger:       logger,
+		checkpointer: checkpointer,
+		interval:     interval,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Run signs the head of the audit chain every interval until Stop is called
+func (app *AppCheckpoint) Run() {
+	const op = "checkpointapp.Run"
+
+	log := app.logger.With(slog.String("op", op))
+	log.Info("audit checkpoint job is running", slog.Duration("interval", app.interval))
+
+	defer close(app.done)
+
+	ticker := time.NewTicker(app.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			app.checkpoint(log)
+		case <-app.stop:
+			// the events written since the last tick are covered before shutting down
+			app.checkpoint(log)
+			return
+		}
+	}
+}
+
+func (app *AppCheckpoint) checkpoint(log *slog.Logger) {
+	checkpoint, created, err := app.checkpointer.Checkpoint(context.Background())
+	if err != nil {
+		log.Error("audit checkpoint failed", slog.String("error", err.Error()))
+		return
+	}
+	if created {
+		log.Info("audit checkpoint created",
+			slog.Int64("event_id", checkpoint.EventId),
+			slog.String("hash", checkpoint.Hash),
+		)
+	}
+}
+
+// Stop makes a final checkpoint and stops the job
+func (app *AppCheckpoint) Stop() {
+	const op = "checkpointapp.Stop"
+
+	app.logger.With(slog.String("op", op)).Info("audit checkpoint job is stopping")
+
+	close(app.stop)
+	<-app.done
+}
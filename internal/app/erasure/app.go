@@ -0,0 +1,88 @@
+package erasureapp
+
+// периодическое удаление персональных данных пользователей и завершенных сессий после срока хранения
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type UserEraser interface {
+	EraseUsers(ctx context.Context, requestedBefore time.Time) (int, error)
+	PruneSessions(ctx context.Context, endedBefore time.Time) (int64, error)
+}
+
+type AppErasure struct {
+	logger           *slog.Logger
+	eraser           UserEraser
+	retention        time.Duration
+	sessionRetention time.Duration
+	interval         time.Duration
+	stop             chan struct{}
+	done             chan struct{}
+}
+
+func New(logger *slog.Logger, eraser UserEraser, retention, sessionRetention, interval time.Duration) *AppErasure {
+	return &AppErasure{
+		logger:           logger,
+		eraser:           eraser,
+		retention:        retention,
+		sessionRetention: sessionRetention,
+		interval:         interval,
+		stop:             make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+}
+
+// Run erases users pending deletion and prunes ended sessions every interval until Stop is called
+func (app *AppErasure) Run() {
+	const op = "erasureapp.Run"
+
+	log := app.logger.With(slog.String("op", op))
+	log.Info("user erasure job is running",
+		slog.Duration("retention", app.retention),
+		slog.Duration("session_retention", app.sessionRetention),
+		slog.Duration("interval", app.interval),
+	)
+
+	defer close(app.done)
+
+	ticker := time.NewTicker(app.interval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for {
+		erased, err := app.eraser.EraseUsers(ctx, time.Now().Add(-app.retention))
+		if err != nil {
+			log.Error("user erasure failed", slog.String("error", err.Error()))
+		} else if erased > 0 {
+			log.Info("users erased", slog.Int("count", erased))
+		}
+
+		pruned, err := app.eraser.PruneSessions(ctx, time.Now().Add(-app.sessionRetention))
+		if err != nil {
+			log.Error("session pruning failed", slog.String("error", err.Error()))
+		} else if pruned > 0 {
+			log.Info("sessions pruned", slog.Int64("count", pruned))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-app.stop:
+			return
+		}
+	}
+}
+
+// Stop waits for the current erasure run to finish and stops the job
+func (app *AppErasure) Stop() {
+	const op = "erasureapp.Stop"
+
+	app.logger.With(slog.String("op", op)).Info("user erasure job is stopping")
+
+	close(app.stop)
+	<-app.done
+}
@@ -3,39 +3,116 @@ package grpcapp
 // grpc app
 
 import (
+	"context"
 	"fmt"
+	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	"log/slog"
 	"net"
 	authgrpc "usekit-auth/internal/grpc/auth"
 )
 
 type AppGrpc struct {
-	logger     *slog.Logger
-	grpcServer *grpc.Server
-	port       int
+	logger       *slog.Logger
+	grpcServer   *grpc.Server
+	healthServer *health.Server
+	interceptor  grpc.UnaryServerInterceptor
+	port         int
+	tls          bool
 }
 
-func New(logger *slog.Logger, authService authgrpc.Auth, port int) *AppGrpc {
+// New creates the gRPC server, it serves plaintext when creds is nil.
+// With withReflection the server reflection service describes the registered services to clients.
+func New(
+	logger *slog.Logger,
+	authService authgrpc.Auth,
+	port int,
+	observer RPCObserver,
+	creds credentials.TransportCredentials,
+	timeouts Timeouts,
+	withReflection bool,
+) *AppGrpc {
+	unary := unaryInterceptors(logger, observer, timeouts)
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(streamInterceptors(logger, observer, timeouts)...),
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+
 	// создается grpc сервер
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(opts...)
 
 	// регистрируется grpc сервер
 	authgrpc.Register(grpcServer, authService)
 
+	// сервис не готов, пока проверка хранилища не пройдет, см. SetServing
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthv1.HealthCheckResponse_NOT_SERVING)
+	healthServer.SetServingStatus(authv1.Auth_ServiceDesc.ServiceName, healthv1.HealthCheckResponse_NOT_SERVING)
+	healthv1.RegisterHealthServer(grpcServer, healthServer)
+
+	if withReflection {
+		reflection.Register(grpcServer)
+	}
+
+	warnUnknownMethods(logger, grpcServer, timeouts)
+
 	return &AppGrpc{
-		logger:     logger,
-		grpcServer: grpcServer,
-		port:       port,
+		logger:       logger,
+		grpcServer:   grpcServer,
+		healthServer: healthServer,
+		interceptor:  chainUnary(unary),
+		port:         port,
+		tls:          creds != nil,
 	}
 }
 
-func (app *AppGrpc) MustRun() {
-	if err := app.Run(); err != nil {
-		panic(err)
+// warnUnknownMethods logs timeout overrides that do not match any registered method, e.g. because of a typo
+func warnUnknownMethods(logger *slog.Logger, grpcServer *grpc.Server, timeouts Timeouts) {
+	methods := make(map[string]bool)
+	for service, info := range grpcServer.GetServiceInfo() {
+		for _, method := range info.Methods {
+			methods["/"+service+"/"+method.Name] = true
+		}
+	}
+
+	for method := range timeouts.Methods {
+		if !methods[method] {
+			logger.Warn("grpc timeout is set for unknown method", slog.String("method", method))
+		}
 	}
 }
 
+// SetServing reports the server and the auth service as serving or not serving
+// to health checks, it has no effect once Stop is called
+func (app *AppGrpc) SetServing(serving bool) {
+	status := healthv1.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthv1.HealthCheckResponse_SERVING
+	}
+
+	app.healthServer.SetServingStatus("", status)
+	app.healthServer.SetServingStatus(authv1.Auth_ServiceDesc.ServiceName, status)
+}
+
+// Health returns the health service, it also backs the HTTP /readyz endpoint
+func (app *AppGrpc) Health() healthv1.HealthServer {
+	return app.healthServer
+}
+
+// UnaryInterceptor returns the interceptors of the server as one, so handlers called
+// outside of gRPC, e.g. by the HTTP gateway, are traced, logged and limited the same way
+func (app *AppGrpc) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return app.interceptor
+}
+
+// Run serves until Stop is called, it returns an error if the server could not start or failed
 func (app *AppGrpc) Run() error {
 	const op = "grpcapp.Run"
 
@@ -47,7 +124,7 @@ func (app *AppGrpc) Run() error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("grpc server is running", slog.String("address", listener.Addr().String()))
+	log.Info("grpc server is running", slog.String("address", listener.Addr().String()), slog.Bool("tls", app.tls))
 
 	// запускается сервер и указывается listener для обработки запросов
 	if err := app.grpcServer.Serve(listener); err != nil {
@@ -57,11 +134,29 @@ func (app *AppGrpc) Run() error {
 	return nil
 }
 
-func (app *AppGrpc) Stop() {
+// Stop waits for in-flight calls to finish until ctx is done, then closes remaining connections
+func (app *AppGrpc) Stop(ctx context.Context) {
 	const op = "grpcapp.Stop"
 
-	app.logger.With(slog.String("op", op)).Info("grpc server is stopping", slog.Int("port", app.port))
+	log := app.logger.With(slog.String("op", op))
+	log.Info("grpc server is stopping", slog.Int("port", app.port))
+
+	// балансировщики перестают слать запросы, пока обрабатываются текущие
+	app.healthServer.Shutdown()
 
 	// прекращается прием новых запросов, блокируется выполнение кода до момента обработки уже выполняемых запросов
-	app.grpcServer.GracefulStop()
+	stopped := make(chan struct{})
+	go func() {
+		app.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warn("grpc server did not drain in time, closing connections")
+		// Stop отменяет контексты текущих запросов, GracefulStop после этого тоже завершится
+		app.grpcServer.Stop()
+		<-stopped
+	}
 }
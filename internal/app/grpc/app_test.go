@@ -0,0 +1,157 @@
+package grpcapp
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	authgrpc "usekit-auth/internal/grpc/auth"
+)
+
+// blockingAuth holds IsAdmin calls until release is closed or the call is cancelled
+type blockingAuth struct {
+	authgrpc.Auth
+	started chan struct{}
+	release chan struct{}
+}
+
+func (a *blockingAuth) IsAdmin(ctx context.Context, _ string, _ int64) (bool, error) {
+	a.started <- struct{}{}
+	select {
+	case <-a.release:
+		return true, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// newServedApp serves the app on an in-memory listener and returns a client connection to it
+func newServedApp(t *testing.T, auth authgrpc.Auth, withReflection bool) (*AppGrpc, *grpc.ClientConn) {
+	t.Helper()
+
+	app := New(slog.New(slog.NewTextHandler(io.Discard, nil)), auth, 0, &recordingObserver{codes: map[string]codes.Code{}},
+		nil, Timeouts{}, withReflection)
+	app.SetServing(true)
+
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = app.grpcServer.Serve(listener) }()
+	t.Cleanup(app.grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return app, conn
+}
+
+func TestStop(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		// release finishes the in-flight call while Stop drains
+		release bool
+		code    codes.Code
+	}{
+		{name: "in-flight call is drained", timeout: 5 * time.Second, release: true, code: codes.OK},
+		{name: "connections are closed after the timeout", timeout: 100 * time.Millisecond, code: codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &blockingAuth{started: make(chan struct{}, 1), release: make(chan struct{})}
+			app, conn := newServedApp(t, auth, false)
+
+			callErr := make(chan error, 1)
+			go func() {
+				_, err := authv1.NewAuthClient(conn).IsAdmin(context.Background(), &authv1.IsAdminRequest{UserId: 1})
+				callErr <- err
+			}()
+			<-auth.started
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			stopped := make(chan struct{})
+			go func() {
+				app.Stop(ctx)
+				close(stopped)
+			}()
+
+			// health checks report NOT_SERVING while the call is drained
+			require.Eventually(t, func() bool {
+				resp, err := app.Health().Check(context.Background(), &healthv1.HealthCheckRequest{})
+				return err == nil && resp.GetStatus() == healthv1.HealthCheckResponse_NOT_SERVING
+			}, time.Second, 10*time.Millisecond)
+
+			if tt.release {
+				select {
+				case <-stopped:
+					t.Fatal("Stop returned before the in-flight call finished")
+				case <-time.After(100 * time.Millisecond):
+				}
+				close(auth.release)
+			}
+
+			select {
+			case <-stopped:
+			case <-time.After(tt.timeout + 2*time.Second):
+				t.Fatal("Stop did not return after the timeout")
+			}
+			require.Equal(t, tt.code, status.Code(<-callErr))
+		})
+	}
+}
+
+func TestNew_Reflection(t *testing.T) {
+	tests := []struct {
+		name           string
+		withReflection bool
+		code           codes.Code
+	}{
+		{name: "enabled", withReflection: true, code: codes.OK},
+		{name: "disabled by default", code: codes.Unimplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, conn := newServedApp(t, &blockingAuth{}, tt.withReflection)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			stream, err := reflectionv1.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+			require.NoError(t, err)
+			require.NoError(t, stream.Send(&reflectionv1.ServerReflectionRequest{
+				MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{},
+			}))
+
+			resp, err := stream.Recv()
+			require.Equal(t, tt.code, status.Code(err))
+			if tt.code != codes.OK {
+				return
+			}
+
+			var names []string
+			for _, service := range resp.GetListServicesResponse().GetService() {
+				names = append(names, service.GetName())
+			}
+			require.Contains(t, names, authv1.Auth_ServiceDesc.ServiceName)
+			require.Contains(t, names, healthv1.Health_ServiceDesc.ServiceName)
+		})
+	}
+}
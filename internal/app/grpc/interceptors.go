@@ -0,0 +1,292 @@
+package grpcapp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"time"
+	"unicode"
+	"usekit-auth/internal/lib/clientinfo"
+	"usekit-auth/internal/lib/logctx"
+)
+
+const (
+	// deviceMetadataKey carries the device name shown in the user's session list
+	deviceMetadataKey = "x-device-name"
+	// requestIdMetadataKey carries the request id, it is generated if the client did not send one
+	// and is returned in response headers
+	requestIdMetadataKey = "x-request-id"
+	maxRequestIdLength   = 128
+)
+
+// RPCObserver receives every completed call, see metrics.Metrics
+type RPCObserver interface {
+	ObserveRPC(method string, code codes.Code, duration time.Duration)
+}
+
+// unaryInterceptors returns interceptors in the order they wrap the handler:
+// tracing, request id and logger, access log, metrics, deadline, panic recovery, client info
+func unaryInterceptors(logger *slog.Logger, observer RPCObserver, timeouts Timeouts) []grpc.UnaryServerInterceptor {
+	return []grpc.UnaryServerInterceptor{
+		tracingUnaryInterceptor,
+		requestIdUnaryInterceptor(logger),
+		accessLogUnaryInterceptor,
+		metricsUnaryInterceptor(observer),
+		timeoutUnaryInterceptor(timeouts),
+		recoveryUnaryInterceptor,
+		clientInfoInterceptor,
+	}
+}
+
+func streamInterceptors(logger *slog.Logger, observer RPCObserver, timeouts Timeouts) []grpc.StreamServerInterceptor {
+	return []grpc.StreamServerInterceptor{
+		tracingStreamInterceptor,
+		requestIdStreamInterceptor(logger),
+		accessLogStreamInterceptor,
+		metricsStreamInterceptor(observer),
+		timeoutStreamInterceptor(timeouts),
+		recoveryStreamInterceptor,
+		clientInfoStreamInterceptor,
+	}
+}
+
+// chainUnary combines interceptors in the same order as grpc.ChainUnaryInterceptor
+func chainUnary(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, req any) (any, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// requestIdUnaryInterceptor stores a logger with the request id and method in the request context,
+// services pick it up with logctx.From
+func requestIdUnaryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		requestId := requestIdFrom(ctx)
+		// the header is only a convenience for clients, failing to send it must not fail the request
+		_ = grpc.SetHeader(ctx, metadata.Pairs(requestIdMetadataKey, requestId))
+
+		return handler(withRequestLogger(ctx, logger, requestId, info.FullMethod), req)
+	}
+}
+
+func requestIdStreamInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		requestId := requestIdFrom(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(requestIdMetadataKey, requestId))
+
+		ctx := withRequestLogger(ss.Context(), logger, requestId, info.FullMethod)
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// withRequestLogger also adds the trace id when the request is traced so log lines can be matched with spans
+func withRequestLogger(ctx context.Context, logger *slog.Logger, requestId, method string) context.Context {
+	logger = logger.With(
+		slog.String("request_id", requestId),
+		slog.String("method", method),
+	)
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsSampled() {
+		logger = logger.With(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+	return logctx.With(ctx, logger)
+}
+
+// requestIdFrom returns the request id sent by the client if it is reasonable, a new random one otherwise
+func requestIdFrom(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIdMetadataKey); len(values) > 0 && validRequestId(values[0]) {
+			return values[0]
+		}
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for _, r := range id {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func accessLogUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logAccess(ctx, start, err)
+	return resp, err
+}
+
+func accessLogStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	logAccess(ss.Context(), start, err)
+	return err
+}
+
+// logAccess writes the access log line, server-side failures are logged as errors
+func logAccess(ctx context.Context, start time.Time, err error) {
+	code := status.Code(err)
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	}
+
+	logctx.From(ctx, slog.Default()).LogAttrs(ctx, level, "request finished",
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("peer", peerAddress(ctx)),
+	)
+}
+
+func metricsUnaryInterceptor(observer RPCObserver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observer.ObserveRPC(info.FullMethod, status.Code(err), time.Since(start))
+		return resp, err
+	}
+}
+
+func metricsStreamInterceptor(observer RPCObserver) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observer.ObserveRPC(info.FullMethod, status.Code(err), time.Since(start))
+		return err
+	}
+}
+
+func recoveryUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(ctx, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func recoveryStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(ss.Context(), r)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// recovered logs a panic of a handler and returns the error reported to the client
+func recovered(ctx context.Context, r any) error {
+	logctx.From(ctx, slog.Default()).Error("panic in handler",
+		slog.Any("panic", r),
+		slog.String("stack", string(debug.Stack())),
+	)
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// clientInfoInterceptor stores the client address and user agent in the request context for the audit log
+func clientInfoInterceptor(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	return handler(clientinfo.With(ctx, clientInfoFrom(ctx)), req)
+}
+
+func clientInfoStreamInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := clientinfo.With(ss.Context(), clientInfoFrom(ss.Context()))
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+func clientInfoFrom(ctx context.Context) clientinfo.Info {
+	var info clientinfo.Info
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		info.IP = host
+		info.Certificate = clientCertificate(p)
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if userAgent := md.Get("user-agent"); len(userAgent) > 0 {
+			info.UserAgent = userAgent[0]
+		}
+		if device := md.Get(deviceMetadataKey); len(device) > 0 {
+			info.Device = device[0]
+		}
+	}
+
+	return info
+}
+
+// clientCertificate returns the client certificate verified during the TLS handshake, nil if there is none
+func clientCertificate(p *peer.Peer) *clientinfo.Certificate {
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	cert := tlsInfo.State.VerifiedChains[0][0]
+
+	uris := make([]string, 0, len(cert.URIs))
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+
+	return &clientinfo.Certificate{
+		Subject:      cert.Subject.String(),
+		CommonName:   cert.Subject.CommonName,
+		SerialNumber: cert.SerialNumber.String(),
+		DNSNames:     cert.DNSNames,
+		URIs:         uris,
+		Emails:       cert.EmailAddresses,
+		Issuer:       cert.Issuer.String(),
+	}
+}
+
+func peerAddress(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// contextStream overrides the context of a server stream so interceptors can add values to it
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
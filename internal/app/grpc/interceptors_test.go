@@ -0,0 +1,152 @@
+package grpcapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"usekit-auth/internal/lib/logctx"
+)
+
+type recordingObserver struct {
+	mu    sync.Mutex
+	codes map[string]codes.Code
+}
+
+func (o *recordingObserver) ObserveRPC(method string, code codes.Code, _ time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.codes[method] = code
+}
+
+// healthStub panics for the "panic" service and logs with the request logger otherwise
+type healthStub struct {
+	healthv1.UnimplementedHealthServer
+}
+
+func (healthStub) Check(ctx context.Context, req *healthv1.HealthCheckRequest) (*healthv1.HealthCheckResponse, error) {
+	if req.GetService() == "panic" {
+		panic("boom")
+	}
+	logctx.From(ctx, slog.Default()).Info("handled")
+	return &healthv1.HealthCheckResponse{Status: healthv1.HealthCheckResponse_SERVING}, nil
+}
+
+func newInterceptedClient(t *testing.T) (healthv1.HealthClient, *bytes.Buffer, *recordingObserver) {
+	t.Helper()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	observer := &recordingObserver{codes: map[string]codes.Code{}}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(unaryInterceptors(logger, observer, Timeouts{})...))
+	healthv1.RegisterHealthServer(server, healthStub{})
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return healthv1.NewHealthClient(conn), &logs, observer
+}
+
+// logLines returns the JSON log records with message msg
+func logLines(t *testing.T, logs *bytes.Buffer, msg string) []map[string]any {
+	t.Helper()
+
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		if record["msg"] == msg {
+			lines = append(lines, record)
+		}
+	}
+	return lines
+}
+
+func TestInterceptors_PanicIsInternal(t *testing.T) {
+	client, logs, observer := newInterceptedClient(t)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), requestIdMetadataKey, "req-panic")
+	_, err := client.Check(ctx, &healthv1.HealthCheckRequest{Service: "panic"})
+
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.Internal, st.Code())
+	require.Equal(t, "internal server error", st.Message())
+
+	panics := logLines(t, logs, "panic in handler")
+	require.Len(t, panics, 1)
+	require.Equal(t, "req-panic", panics[0]["request_id"])
+	require.Equal(t, "boom", panics[0]["panic"])
+
+	access := logLines(t, logs, "request finished")
+	require.Len(t, access, 1)
+	require.Equal(t, "Internal", access[0]["code"])
+	require.Equal(t, "ERROR", access[0]["level"])
+
+	require.Equal(t, codes.Internal, observer.codes[healthv1.Health_Check_FullMethodName])
+}
+
+func TestInterceptors_RequestId(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestId string
+		want      *regexp.Regexp
+	}{
+		{name: "sent by client", requestId: "req-42", want: regexp.MustCompile(`^req-42$`)},
+		{name: "generated", want: regexp.MustCompile(`^[0-9a-f]{32}$`)},
+		{name: "too long is replaced", requestId: strings.Repeat("a", maxRequestIdLength+1), want: regexp.MustCompile(`^[0-9a-f]{32}$`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, logs, _ := newInterceptedClient(t)
+
+			ctx := context.Background()
+			if tt.requestId != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, requestIdMetadataKey, tt.requestId)
+			}
+
+			var header metadata.MD
+			_, err := client.Check(ctx, &healthv1.HealthCheckRequest{}, grpc.Header(&header))
+			require.NoError(t, err)
+
+			values := header.Get(requestIdMetadataKey)
+			require.Len(t, values, 1)
+			require.Regexp(t, tt.want, values[0])
+
+			handled := logLines(t, logs, "handled")
+			require.Len(t, handled, 1)
+			require.Equal(t, values[0], handled[0]["request_id"])
+			require.Equal(t, healthv1.Health_Check_FullMethodName, handled[0]["method"])
+
+			access := logLines(t, logs, "request finished")
+			require.Len(t, access, 1)
+			require.Equal(t, values[0], access[0]["request_id"])
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package grpcapp
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"time"
+)
+
+// Timeouts are deadlines applied by the server to calls whose client did not set one
+type Timeouts struct {
+	// Default applies to unary calls only, streams such as Health/Watch and reflection live
+	// as long as the client keeps them open
+	Default time.Duration
+	// Methods overrides Default by full method name, e.g. "/auth.Auth/Login",
+	// a stream gets a deadline only when it is listed here
+	Methods map[string]time.Duration
+}
+
+// For returns the deadline of unary method, zero means no deadline
+func (t Timeouts) For(method string) time.Duration {
+	if timeout, ok := t.Methods[method]; ok {
+		return timeout
+	}
+	return t.Default
+}
+
+// ForStream returns the deadline of streaming method, zero means no deadline
+func (t Timeouts) ForStream(method string) time.Duration {
+	return t.Methods[method]
+}
+
+// withDeadline applies timeout unless ctx already has a deadline, so a deadline
+// set by the client is kept even when it is longer than timeout
+func withDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func timeoutUnaryInterceptor(timeouts Timeouts) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, cancel := withDeadline(ctx, timeouts.For(info.FullMethod))
+		defer cancel()
+
+		return handler(ctx, req)
+	}
+}
+
+func timeoutStreamInterceptor(timeouts Timeouts) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := withDeadline(ss.Context(), timeouts.ForStream(info.FullMethod))
+		defer cancel()
+
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
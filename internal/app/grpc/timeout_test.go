@@ -0,0 +1,132 @@
+package grpcapp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestTimeoutUnaryInterceptor(t *testing.T) {
+	timeouts := Timeouts{
+		Default: time.Minute,
+		Methods: map[string]time.Duration{"/auth.Auth/Login": time.Second},
+	}
+	interceptor := timeoutUnaryInterceptor(timeouts)
+
+	tests := []struct {
+		name           string
+		method         string
+		clientDeadline time.Duration
+		want           time.Duration
+	}{
+		{name: "default", method: "/auth.Auth/IsAdmin", want: time.Minute},
+		{name: "method override", method: "/auth.Auth/Login", want: time.Second},
+		{name: "client deadline kept", method: "/auth.Auth/Login", clientDeadline: time.Hour, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.clientDeadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.clientDeadline)
+				defer cancel()
+			}
+
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, _ any) (any, error) {
+				deadline, ok := ctx.Deadline()
+				require.True(t, ok)
+				require.WithinDuration(t, time.Now().Add(tt.want), deadline, 5*time.Second)
+				return nil, nil
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestTimeoutStreamInterceptor(t *testing.T) {
+	timeouts := Timeouts{
+		Default: time.Minute,
+		Methods: map[string]time.Duration{"/auth.Auth/Export": time.Second},
+	}
+	interceptor := timeoutStreamInterceptor(timeouts)
+
+	tests := []struct {
+		name   string
+		method string
+		want   time.Duration
+	}{
+		{name: "no default deadline", method: healthv1.Health_Watch_FullMethodName},
+		{name: "listed stream", method: "/auth.Auth/Export", want: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss := &fakeStream{ctx: context.Background()}
+			err := interceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: tt.method}, func(_ any, stream grpc.ServerStream) error {
+				deadline, ok := stream.Context().Deadline()
+				require.Equal(t, tt.want > 0, ok)
+				if ok {
+					require.WithinDuration(t, time.Now().Add(tt.want), deadline, 5*time.Second)
+				}
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+// a Watch stream has to outlive the default deadline and keep receiving updates
+func TestTimeoutStreamInterceptor_HealthWatch(t *testing.T) {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(timeoutUnaryInterceptor(Timeouts{Default: 50 * time.Millisecond})),
+		grpc.ChainStreamInterceptor(timeoutStreamInterceptor(Timeouts{Default: 50 * time.Millisecond})),
+	)
+	healthServer := health.NewServer()
+	healthv1.RegisterHealthServer(server, healthServer)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := healthv1.NewHealthClient(conn).Watch(ctx, &healthv1.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, healthv1.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	time.Sleep(200 * time.Millisecond)
+	healthServer.SetServingStatus("", healthv1.HealthCheckResponse_NOT_SERVING)
+
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, healthv1.HealthCheckResponse_NOT_SERVING, resp.GetStatus())
+}
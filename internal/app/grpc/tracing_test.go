@@ -0,0 +1,102 @@
+package grpcapp
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	spansOnce sync.Once
+	spans     *tracetest.InMemoryExporter
+)
+
+// spanExporter installs the global tracer provider once, the package tracer is bound to the first one
+func spanExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	spansOnce.Do(func() {
+		spans = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	spans.Reset()
+	return spans
+}
+
+func TestTracingUnaryInterceptor(t *testing.T) {
+	const (
+		traceId  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentId = "00f067aa0ba902b7"
+	)
+
+	tests := []struct {
+		name        string
+		traceparent string
+		err         error
+		failed      bool
+	}{
+		{name: "ok"},
+		{name: "client error is not a failure", err: status.Error(codes.NotFound, "user not found")},
+		{name: "server error", err: status.Error(codes.Internal, "internal error"), failed: true},
+		{name: "continues the client trace", traceparent: "00-" + traceId + "-" + parentId + "-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := spanExporter(t)
+
+			ctx := context.Background()
+			if tt.traceparent != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("traceparent", tt.traceparent))
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: "/auth.Auth/Login"}
+
+			var handlerSpan trace.SpanContext
+			_, err := tracingUnaryInterceptor(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+				handlerSpan = trace.SpanContextFromContext(ctx)
+				return nil, tt.err
+			})
+			require.Equal(t, tt.err, err)
+
+			ended := exporter.GetSpans()
+			require.Len(t, ended, 1)
+			span := ended[0]
+
+			require.Equal(t, "auth.Auth/Login", span.Name)
+			require.Equal(t, trace.SpanKindServer, span.SpanKind)
+			require.Equal(t, span.SpanContext, handlerSpan, "the handler runs in the server span")
+			require.Contains(t, span.Attributes, attribute.String("rpc.system", "grpc"))
+			require.Contains(t, span.Attributes, attribute.String("rpc.service", "auth.Auth"))
+			require.Contains(t, span.Attributes, attribute.String("rpc.method", "Login"))
+			require.Contains(t, span.Attributes, attribute.Int("rpc.grpc.status_code", int(status.Code(tt.err))))
+
+			if tt.failed {
+				require.Equal(t, otelcodes.Error, span.Status.Code)
+			} else {
+				require.Equal(t, otelcodes.Unset, span.Status.Code)
+			}
+
+			if tt.traceparent != "" {
+				require.Equal(t, traceId, span.SpanContext.TraceID().String())
+				require.Equal(t, parentId, span.Parent.SpanID().String())
+				require.True(t, span.Parent.IsRemote())
+			} else {
+				require.False(t, span.Parent.IsValid())
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package httpapp
+
+// http app: служебные эндпоинты (метрики, health checks) и HTTP/JSON gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+type AppHttp struct {
+	logger *slog.Logger
+	// name tells the servers apart in logs
+	name   string
+	server *http.Server
+	port   int
+}
+
+// HealthChecker is the gRPC health service, see grpcapp.AppGrpc.Health
+type HealthChecker interface {
+	Check(ctx context.Context, req *healthv1.HealthCheckRequest) (*healthv1.HealthCheckResponse, error)
+}
+
+// New creates the HTTP server, it serves plaintext when tlsConfig is nil
+func New(logger *slog.Logger, name string, port int, handler http.Handler, tlsConfig *tls.Config) *AppHttp {
+	return &AppHttp{
+		logger: logger,
+		name:   name,
+		server: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: 5 * time.Second,
+			TLSConfig:         tlsConfig,
+		},
+		port: port,
+	}
+}
+
+// OpsHandler serves operational endpoints: /metrics, /healthz and /readyz
+func OpsHandler(metrics http.Handler, health HealthChecker) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics)
+	mux.HandleFunc("GET /healthz", healthz)
+	mux.Handle("GET /readyz", readyz(health))
+	return mux
+}
+
+// Run serves until Stop is called, it returns an error if the server could not start or failed
+func (app *AppHttp) Run() error {
+	const op = "httpapp.Run"
+
+	log := app.logger.With(slog.String("op", op), slog.String("server", app.name), slog.Int("port", app.port))
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", app.port))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("http server is running", slog.String("address", listener.Addr().String()),
+		slog.Bool("tls", app.server.TLSConfig != nil))
+
+	if err := app.serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (app *AppHttp) serve(listener net.Listener) error {
+	if app.server.TLSConfig == nil {
+		return app.server.Serve(listener)
+	}
+	// certificates come from TLSConfig, see tlsreload.Reloader
+	return app.server.ServeTLS(listener, "", "")
+}
+
+// Stop waits for in-flight requests to finish until ctx is done, then closes remaining connections
+func (app *AppHttp) Stop(ctx context.Context) {
+	const op = "httpapp.Stop"
+
+	log := app.logger.With(slog.String("op", op), slog.String("server", app.name))
+	log.Info("http server is stopping", slog.Int("port", app.port))
+
+	if err := app.server.Shutdown(ctx); err != nil {
+		log.Warn("http server did not drain in time, closing connections", slog.String("error", err.Error()))
+		_ = app.server.Close()
+	}
+}
+
+// healthz is the liveness probe, it succeeds as long as the process serves requests
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// readyz is the readiness probe, it reports the status of the gRPC health service:
+// 200 when it is SERVING, 503 while storage is not ready and after shutdown has started
+func readyz(health HealthChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := health.Check(r.Context(), &healthv1.HealthCheckRequest{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		if resp.GetStatus() != healthv1.HealthCheckResponse_SERVING {
+			http.Error(w, resp.GetStatus().String(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	}
+}
@@ -0,0 +1,131 @@
+package httpapp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// selfSignedConfig returns a server config with a certificate for 127.0.0.1 and a client config trusting it
+func selfSignedConfig(t *testing.T) (*tls.Config, *tls.Config) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		&tls.Config{RootCAs: pool}
+}
+
+// startApp serves app on a random local port and returns its address
+func startApp(t *testing.T, app *AppHttp) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = app.serve(listener) }()
+	t.Cleanup(func() { _ = app.server.Close() })
+	return listener.Addr().String()
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestRun_TLS(t *testing.T) {
+	serverConfig, clientConfig := selfSignedConfig(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("ok")) })
+
+	tests := []struct {
+		name      string
+		tlsConfig *tls.Config
+		scheme    string
+		client    *http.Client
+		wantErr   bool
+	}{
+		{name: "plaintext", scheme: "http", client: &http.Client{}},
+		{name: "tls", tlsConfig: serverConfig, scheme: "https",
+			client: &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}},
+		{name: "plaintext request to tls server", tlsConfig: serverConfig, scheme: "http", client: &http.Client{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := startApp(t, New(discardLogger(), "gateway", 0, handler, tt.tlsConfig))
+
+			resp, err := tt.client.Get(tt.scheme + "://" + address + "/")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			// the TLS server answers plaintext requests with 400 instead of serving them
+			if tt.tlsConfig != nil && tt.scheme == "http" {
+				require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+				return
+			}
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "ok", string(body))
+		})
+	}
+}
+
+func TestStop_Timeout(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+	app := New(discardLogger(), "gateway", 0, handler, nil)
+	address := startApp(t, app)
+
+	callErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + address + "/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		callErr <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	app.Stop(ctx)
+
+	require.Less(t, time.Since(begin), 2*time.Second)
+	// the connection of the in-flight request is closed
+	require.Error(t, <-callErr)
+	// new connections are not accepted
+	_, err := http.Get("http://" + address + "/")
+	require.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"usekit-auth/internal/config"
+	"usekit-auth/internal/lib/password"
+)
+
+// Passwords are the password policy, hasher and peppers of the auth service
+type Passwords struct {
+	Policy  password.Policy
+	Hasher  *password.Hasher
+	Peppers *password.Peppers
+}
+
+// NewPasswords builds Passwords from the config, tools creating users use it
+// to hash passwords the same way as the server
+func NewPasswords(logger *slog.Logger, cfg config.PasswordConfig) (Passwords, error) {
+	const op = "app.NewPasswords"
+
+	policy := password.Policy{
+		MinLength:  cfg.MinLength,
+		MaxLength:  cfg.MaxLength,
+		MinClasses: cfg.MinClasses,
+	}
+	if cfg.BreachedHashesPath != "" {
+		breached, err := password.LoadBreachedList(cfg.BreachedHashesPath)
+		if err != nil {
+			return Passwords{}, fmt.Errorf("%s: %w", op, err)
+		}
+		logger.Info("breached passwords loaded", slog.Int("count", breached.Len()))
+		policy.Breached = breached
+	}
+	passwords := Passwords{Policy: policy}
+
+	hasher, err := newPasswordHasher(cfg.Hashing)
+	if err != nil {
+		passwords.Close()
+		return Passwords{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	peppers, err := password.ParsePeppers(cfg.Peppers)
+	if err != nil {
+		passwords.Close()
+		return Passwords{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if peppers.Current() == 0 {
+		logger.Warn("password pepper is not configured, password hashes are not peppered")
+	}
+
+	passwords.Hasher, passwords.Peppers = hasher, peppers
+	return passwords, nil
+}
+
+// Close releases the breached password list
+func (p Passwords) Close() error {
+	if p.Policy.Breached == nil {
+		return nil
+	}
+	return p.Policy.Breached.Close()
+}
+
+// newPasswordHasher returns a hasher producing hashes with the configured algorithm that still verifies the other one
+func newPasswordHasher(cfg config.HashingConfig) (*password.Hasher, error) {
+	bcryptScheme, err := password.NewBcrypt(cfg.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+	argon2Scheme, err := password.NewArgon2id(password.Argon2Params{
+		Memory:      cfg.Argon2Memory,
+		Iterations:  cfg.Argon2Iterations,
+		Parallelism: cfg.Argon2Parallelism,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Algorithm {
+	case "bcrypt":
+		return password.NewHasher(bcryptScheme, argon2Scheme), nil
+	case "argon2id":
+		return password.NewHasher(argon2Scheme, bcryptScheme), nil
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm %q", cfg.Algorithm)
+	}
+}
@@ -0,0 +1,90 @@
+package readinessapp
+
+// периодическая проверка готовности хранилища, результат отдается в health check
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Probe interface {
+	Ready(ctx context.Context) error
+}
+
+// StatusSetter receives the result of every check, see grpcapp.AppGrpc.SetServing
+type StatusSetter interface {
+	SetServing(serving bool)
+}
+
+type AppReadiness struct {
+	logger   *slog.Logger
+	probe    Probe
+	status   StatusSetter
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func New(logger *slog.Logger, probe Probe, status StatusSetter, interval time.Duration) *AppReadiness {
+	return &AppReadiness{
+		logger:   logger,
+		probe:    probe,
+		status:   status,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run checks the probe right away and then every interval until Stop is called,
+// changes of readiness are logged
+func (app *AppReadiness) Run() {
+	const op = "readinessapp.Run"
+
+	log := app.logger.With(slog.String("op", op))
+	log.Info("readiness checks are running", slog.Duration("interval", app.interval))
+
+	defer close(app.done)
+
+	ticker := time.NewTicker(app.interval)
+	defer ticker.Stop()
+
+	var ready, checked bool
+	for {
+		err := app.check()
+		if !checked || ready != (err == nil) {
+			if err != nil {
+				log.Warn("service is not ready", slog.String("error", err.Error()))
+			} else {
+				log.Info("service is ready")
+			}
+		}
+		ready, checked = err == nil, true
+		app.status.SetServing(ready)
+
+		select {
+		case <-ticker.C:
+		case <-app.stop:
+			return
+		}
+	}
+}
+
+func (app *AppReadiness) check() error {
+	// a check must not outlive the next one
+	ctx, cancel := context.WithTimeout(context.Background(), app.interval)
+	defer cancel()
+
+	return app.probe.Ready(ctx)
+}
+
+// Stop waits for the current check to finish and stops the job
+func (app *AppReadiness) Stop() {
+	const op = "readinessapp.Stop"
+
+	app.logger.With(slog.String("op", op)).Info("readiness checks are stopping")
+
+	close(app.stop)
+	<-app.done
+}
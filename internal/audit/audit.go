@@ -0,0 +1,61 @@
+package audit
+
+// приемники событий аудита: база данных, файл, webhook
+
+import (
+	"context"
+	"errors"
+	"usekit-auth/internal/domain/models"
+)
+
+// Sink receives audit events. Write must not modify the event.
+type Sink interface {
+	Write(ctx context.Context, event models.AuditEvent) error
+	// Close flushes buffered events and releases resources
+	Close() error
+}
+
+// Multi writes every event to all sinks
+type Multi []Sink
+
+func (m Multi) Write(ctx context.Context, event models.AuditEvent) error {
+	var errs []error
+	for _, sink := range m {
+		if err := sink.Write(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m Multi) Close() error {
+	var errs []error
+	for _, sink := range m {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type EventSaver interface {
+	SaveAuditEvent(ctx context.Context, event models.AuditEvent) (int64, error)
+}
+
+// StorageSink persists events in the append-only audit_events table
+type StorageSink struct {
+	saver EventSaver
+}
+
+func NewStorageSink(saver EventSaver) *StorageSink {
+	return &StorageSink{saver: saver}
+}
+
+func (s *StorageSink) Write(ctx context.Context, event models.AuditEvent) error {
+	_, err := s.saver.SaveAuditEvent(ctx, event)
+	return err
+}
+
+func (s *StorageSink) Close() error {
+	return nil
+}
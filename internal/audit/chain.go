@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+	"usekit-auth/internal/domain/models"
+)
+
+// ChainHash returns the hash of event linked to the hash of the previous event.
+// Every field except Hash is covered; fields are length-prefixed so that
+// different events can never serialize to the same input.
+// CreatedAt is hashed with second precision, as it is stored.
+// When the event has a ClientDigest, the digest is covered instead of IP and UserAgent
+// so that the client can be erased without breaking the chain.
+func ChainHash(prevHash string, event models.AuditEvent) string {
+	ip, userAgent := event.IP, event.UserAgent
+	if event.ClientDigest != "" {
+		ip, userAgent = "", ""
+	}
+
+	fields := []string{
+		prevHash,
+		strconv.FormatInt(event.Id, 10),
+		string(event.Type),
+		string(event.Outcome),
+		strconv.FormatInt(event.ActorId, 10),
+		strconv.FormatInt(event.SubjectId, 10),
+		strconv.Itoa(event.AppId),
+		ip,
+		userAgent,
+		event.Reason,
+		event.CreatedAt.UTC().Truncate(time.Second).Format(time.RFC3339),
+	}
+	// appended only when set, hashes of events written before digests existed stay the same
+	if event.ClientDigest != "" {
+		fields = append(fields, event.ClientDigest)
+	}
+	return hashFields(fields...)
+}
+
+// ClientDigest commits to the client of an event. The salt is random and is erased together
+// with the client, after that the digest can not be linked to an IP address by brute force.
+func ClientDigest(salt []byte, ip, userAgent string) string {
+	return hashFields(string(salt), ip, userAgent)
+}
+
+func hashFields(fields ...string) string {
+	h := sha256.New()
+	for _, field := range fields {
+		fmt.Fprintf(h, "%d:%s;", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkpointMessage is the byte string covered by a checkpoint signature
+func checkpointMessage(checkpoint models.AuditCheckpoint) []byte {
+	return fmt.Appendf(nil, "usekit-auth audit checkpoint\n%d\n%s\n%s",
+		checkpoint.EventId,
+		checkpoint.Hash,
+		checkpoint.CreatedAt.UTC().Truncate(time.Second).Format(time.RFC3339),
+	)
+}
+
+// SignCheckpoint fills the signature of checkpoint
+func SignCheckpoint(key ed25519.PrivateKey, checkpoint models.AuditCheckpoint) models.AuditCheckpoint {
+	checkpoint.Signature = ed25519.Sign(key, checkpointMessage(checkpoint))
+	return checkpoint
+}
+
+// VerifyCheckpoint reports whether checkpoint was signed by the private key of publicKey
+func VerifyCheckpoint(publicKey ed25519.PublicKey, checkpoint models.AuditCheckpoint) bool {
+	return ed25519.Verify(publicKey, checkpointMessage(checkpoint), checkpoint.Signature)
+}
@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"usekit-auth/internal/domain/models"
+)
+
+func testEvent() models.AuditEvent {
+	return models.AuditEvent{
+		Id:        42,
+		Type:      models.AuditLogin,
+		Outcome:   models.AuditFailure,
+		ActorId:   7,
+		SubjectId: 7,
+		AppId:     1,
+		IP:        "192.0.2.1",
+		UserAgent: "grpc-go/1.67.1",
+		Reason:    "invalid credentials",
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+// stored hashes depend on the exact serialization, a change here breaks every existing chain
+func TestChainHash_Golden(t *testing.T) {
+	require.Equal(t, "b4de243e868246308990a7466aca126833ceb3d8e0655501fd1f3626d2bc2797", ChainHash("", testEvent()))
+	require.Equal(t, "0d29a1f60fe49fabab29b23d92986a2f3f46ea6c110279b446b90c40038b88af", ChainHash("00ff", testEvent()))
+}
+
+func TestChainHash_CoversEveryField(t *testing.T) {
+	base := ChainHash("prev", testEvent())
+
+	tests := []struct {
+		name   string
+		prev   string
+		modify func(*models.AuditEvent)
+	}{
+		{name: "prev hash", prev: "other"},
+		{name: "id", modify: func(e *models.AuditEvent) { e.Id++ }},
+		{name: "type", modify: func(e *models.AuditEvent) { e.Type = models.AuditRegister }},
+		{name: "outcome", modify: func(e *models.AuditEvent) { e.Outcome = models.AuditSuccess }},
+		{name: "actor", modify: func(e *models.AuditEvent) { e.ActorId = 8 }},
+		{name: "subject", modify: func(e *models.AuditEvent) { e.SubjectId = 8 }},
+		{name: "app", modify: func(e *models.AuditEvent) { e.AppId = 2 }},
+		{name: "ip", modify: func(e *models.AuditEvent) { e.IP = "192.0.2.2" }},
+		{name: "user agent", modify: func(e *models.AuditEvent) { e.UserAgent = "curl/8.0" }},
+		{name: "reason", modify: func(e *models.AuditEvent) { e.Reason = "user not found" }},
+		{name: "created at", modify: func(e *models.AuditEvent) { e.CreatedAt = e.CreatedAt.Add(time.Second) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, prev := testEvent(), "prev"
+			if tt.modify != nil {
+				tt.modify(&event)
+			}
+			if tt.prev != "" {
+				prev = tt.prev
+			}
+			require.NotEqual(t, base, ChainHash(prev, event))
+		})
+	}
+}
+
+func TestChainHash_ClientDigest(t *testing.T) {
+	event := testEvent()
+	event.ClientDigest = ClientDigest([]byte("salt"), event.IP, event.UserAgent)
+	base := ChainHash("prev", event)
+	require.NotEqual(t, ChainHash("prev", testEvent()), base)
+
+	// the client is kept apart and may be erased without breaking the chain
+	erased := event
+	erased.IP, erased.UserAgent = "", ""
+	require.Equal(t, base, ChainHash("prev", erased))
+
+	other := event
+	other.ClientDigest = ClientDigest([]byte("other salt"), event.IP, event.UserAgent)
+	require.NotEqual(t, base, ChainHash("prev", other))
+
+	require.NotEqual(t, event.ClientDigest, ClientDigest([]byte("salt"), "192.0.2.2", event.UserAgent))
+	require.NotEqual(t, event.ClientDigest, ClientDigest([]byte("salt"), event.IP, "curl/8.0"))
+}
+
+func TestChainHash_Stable(t *testing.T) {
+	event := testEvent()
+	base := ChainHash("prev", event)
+
+	// the stored hash and the id are not part of the input
+	event.Hash, event.PrevHash = "anything", "anything"
+	require.Equal(t, base, ChainHash("prev", event))
+
+	// created_at is stored with second precision in UTC
+	event.CreatedAt = event.CreatedAt.Add(999 * time.Millisecond).In(time.FixedZone("UTC+3", 3*60*60))
+	require.Equal(t, base, ChainHash("prev", event))
+}
+
+// moving a character between adjacent fields must change the hash
+func TestChainHash_FieldBoundaries(t *testing.T) {
+	a, b := testEvent(), testEvent()
+	a.IP, a.UserAgent = "192.0.2.1;", "curl"
+	b.IP, b.UserAgent = "192.0.2.1", ";curl"
+	require.NotEqual(t, ChainHash("", a), ChainHash("", b))
+
+	a.UserAgent, a.Reason = "curl", "1:x"
+	b.UserAgent, b.Reason = "curl1:", "x"
+	require.NotEqual(t, ChainHash("", a), ChainHash("", b))
+}
+
+func TestCheckpointSignature(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	checkpoint := SignCheckpoint(key, models.AuditCheckpoint{
+		EventId:   42,
+		Hash:      ChainHash("", testEvent()),
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	require.True(t, VerifyCheckpoint(pub, checkpoint))
+
+	moved := checkpoint
+	moved.EventId = 41
+	require.False(t, VerifyCheckpoint(pub, moved))
+
+	rehashed := checkpoint
+	rehashed.Hash = ChainHash("x", testEvent())
+	require.False(t, VerifyCheckpoint(pub, rehashed))
+
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	require.False(t, VerifyCheckpoint(otherPub, checkpoint))
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	_, err := ParsePrivateKey("xQBJvNRgUjsl0okz0KBHwQGkdJsbnf9WF9JvqpMjhLQ=")
+	require.NoError(t, err)
+
+	_, err = ParsePrivateKey("not base64!")
+	require.Error(t, err)
+	_, err = ParsePrivateKey("c2hvcnQ=")
+	require.Error(t, err)
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/storage"
+)
+
+type CheckpointStore interface {
+	LastAuditEvent(ctx context.Context) (models.AuditEvent, error)
+	LastAuditCheckpoint(ctx context.Context) (models.AuditCheckpoint, error)
+	SaveAuditCheckpoint(ctx context.Context, checkpoint models.AuditCheckpoint) (int64, error)
+}
+
+// Checkpointer periodically signs the head of the audit chain so that truncating
+// or rewriting the chain is detectable even by someone holding the database.
+type Checkpointer struct {
+	store CheckpointStore
+	key   ed25519.PrivateKey
+}
+
+func NewCheckpointer(store CheckpointStore, key ed25519.PrivateKey) *Checkpointer {
+	return &Checkpointer{store: store, key: key}
+}
+
+// ParsePrivateKey decodes a base64 Ed25519 seed or private key
+func ParsePrivateKey(encoded string) (ed25519.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("audit checkpoint key is not base64: %w", err)
+	}
+
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, fmt.Errorf("audit checkpoint key must be %d or %d bytes, got %d",
+			ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+	}
+}
+
+// Checkpoint signs the last event of the chain. It returns false if there is
+// nothing new since the previous checkpoint.
+func (c *Checkpointer) Checkpoint(ctx context.Context) (models.AuditCheckpoint, bool, error) {
+	const op = "audit.Checkpointer.Checkpoint"
+
+	head, err := c.store.LastAuditEvent(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrAuditEventNotFound) {
+			return models.AuditCheckpoint{}, false, nil
+		}
+		return models.AuditCheckpoint{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	last, err := c.store.LastAuditCheckpoint(ctx)
+	if err != nil && !errors.Is(err, storage.ErrCheckpointNotFound) {
+		return models.AuditCheckpoint{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+	if last.EventId == head.Id {
+		return last, false, nil
+	}
+
+	checkpoint := SignCheckpoint(c.key, models.AuditCheckpoint{
+		EventId:   head.Id,
+		Hash:      head.Hash,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	})
+
+	checkpoint.Id, err = c.store.SaveAuditCheckpoint(ctx, checkpoint)
+	if err != nil {
+		return models.AuditCheckpoint{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return checkpoint, true, nil
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+	"usekit-auth/internal/domain/models"
+)
+
+const webhookQueueSize = 1024
+
+// WebhookSink posts every event as JSON to an HTTP endpoint.
+// Events are delivered in the background so a slow endpoint does not delay requests;
+// when the queue is full new events are dropped and reported as an error.
+type WebhookSink struct {
+	logger *slog.Logger
+	url    string
+	client *http.Client
+	queue  chan models.AuditEvent
+	done   chan struct{}
+	once   sync.Once
+}
+
+func NewWebhookSink(logger *slog.Logger, url string, timeout time.Duration) *WebhookSink {
+	s := &WebhookSink{
+		logger: logger,
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+		queue:  make(chan models.AuditEvent, webhookQueueSize),
+		done:   make(chan struct{}),
+	}
+	go s.deliver()
+
+	return s
+}
+
+func (s *WebhookSink) Write(_ context.Context, event models.AuditEvent) error {
+	const op = "audit.WebhookSink.Write"
+
+	select {
+	case s.queue <- event:
+		return nil
+	default:
+		return fmt.Errorf("%s: queue is full, event dropped", op)
+	}
+}
+
+// Close delivers queued events and stops the sink
+func (s *WebhookSink) Close() error {
+	s.once.Do(func() { close(s.queue) })
+	<-s.done
+	return nil
+}
+
+func (s *WebhookSink) deliver() {
+	const op = "audit.WebhookSink.deliver"
+
+	defer close(s.done)
+
+	log := s.logger.With(slog.String("op", op))
+	for event := range s.queue {
+		if err := s.post(event); err != nil {
+			log.Error("failed to deliver audit event",
+				slog.String("type", string(event.Type)),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
+
+func (s *WebhookSink) post(event models.AuditEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -3,20 +3,163 @@ package config
 import (
 	"flag"
 	"github.com/ilyakaznacheev/cleanenv"
+	"log/slog"
 	"os"
 	"time"
 )
 
 type Config struct {
-	Env         string        `yaml:"env" env-default:"development"`
-	StoragePath string        `yaml:"storage_path" env-required:"true"`
-	TokenTTL    time.Duration `yaml:"token_ttl" env-required:"true"`
-	GRPC        GRPCConfig    `yaml:"grpc"`
+	Env         string         `yaml:"env" env-default:"development"`
+	StoragePath string         `yaml:"storage_path" env-required:"true"`
+	TokenTTL    time.Duration  `yaml:"token_ttl" env-required:"true"`
+	GRPC        GRPCConfig     `yaml:"grpc"`
+	HTTP        HTTPConfig     `yaml:"http"`
+	Gateway     GatewayConfig  `yaml:"gateway"`
+	UserErasure ErasureConfig  `yaml:"user_erasure"`
+	Audit       AuditConfig    `yaml:"audit"`
+	Password    PasswordConfig `yaml:"password"`
+	Login       LoginConfig    `yaml:"login"`
+	Tracing     TracingConfig  `yaml:"tracing"`
+	// ShutdownTimeout is how long servers drain in-flight requests on stop before connections are closed
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"30s"`
 }
 
 type GRPCConfig struct {
-	Port    int           `yaml:"port" env-required:"true"`
+	Port int `yaml:"port" env-required:"true"`
+	// Timeout is the deadline the server applies to unary calls without one, 0 disables it
 	Timeout time.Duration `yaml:"timeout" env-required:"true"`
+	// MethodTimeouts overrides Timeout for full method names such as /auth.Auth/Login,
+	// streaming methods get a deadline only when they are listed here
+	MethodTimeouts map[string]time.Duration `yaml:"method_timeouts"`
+	// ReadinessInterval is how often storage is checked for the health service
+	ReadinessInterval time.Duration `yaml:"readiness_interval" env-default:"5s"`
+	TLS               TLSConfig     `yaml:"tls"`
+	// Reflection exposes the server reflection service used by grpcurl and similar tools
+	Reflection bool `yaml:"reflection" env:"GRPC_REFLECTION" env-default:"false"`
+}
+
+// TLSConfig enables TLS for the gRPC server when CertFile and KeyFile are set,
+// the files are reloaded without restart when they change
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" env:"GRPC_TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"GRPC_TLS_KEY_FILE"`
+	// ClientCAFile enables mutual TLS, client certificates are verified against the CAs in it
+	ClientCAFile string `yaml:"client_ca_file" env:"GRPC_TLS_CLIENT_CA_FILE"`
+	// ClientAuth is require or verify_if_given, the latter lets clients without a certificate in
+	ClientAuth string `yaml:"client_auth" env-default:"require"`
+	// MinVersion is 1.2 or 1.3
+	MinVersion     string        `yaml:"min_version" env-default:"1.2"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env-default:"1m"`
+}
+
+// HTTPConfig is the listener for operational endpoints such as /metrics, it is disabled when Port is 0
+type HTTPConfig struct {
+	Port int `yaml:"port" env:"HTTP_PORT" env-default:"0"`
+}
+
+// GatewayConfig is the listener of the HTTP/JSON gateway to the gRPC API, it is disabled when Port is 0.
+// It is served with the TLS settings of the gRPC server
+type GatewayConfig struct {
+	Port int `yaml:"port" env:"GATEWAY_PORT" env-default:"0"`
+}
+
+type ErasureConfig struct {
+	// Retention is how long users pending deletion are kept before their data is erased
+	Retention time.Duration `yaml:"retention" env-default:"720h"`
+	// SessionRetention is how long expired and revoked sessions are kept before they are deleted
+	SessionRetention time.Duration `yaml:"session_retention" env-default:"720h"`
+	Interval         time.Duration `yaml:"interval" env-default:"1h"`
+}
+
+// AuditConfig configures sinks that receive audit events in addition to the database
+type AuditConfig struct {
+	// FilePath enables appending events as JSON lines to the file
+	FilePath string `yaml:"file_path" env:"AUDIT_FILE_PATH"`
+	// WebhookURL enables posting every event as JSON to the URL
+	WebhookURL     string        `yaml:"webhook_url" env:"AUDIT_WEBHOOK_URL"`
+	WebhookTimeout time.Duration `yaml:"webhook_timeout" env-default:"5s"`
+	// CheckpointKey is a base64 Ed25519 seed used to sign checkpoints of the audit hash chain,
+	// checkpoints are disabled when it is empty
+	CheckpointKey      string        `yaml:"checkpoint_key" env:"AUDIT_CHECKPOINT_KEY"`
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval" env-default:"10m"`
+}
+
+// PasswordConfig is the policy for passwords of new users
+type PasswordConfig struct {
+	MinLength int `yaml:"min_length" env-default:"8"`
+	// MaxLength is in bytes, values above the 72 bytes bcrypt accepts are lowered to 72
+	MaxLength  int `yaml:"max_length" env-default:"72"`
+	MinClasses int `yaml:"min_classes" env-default:"2"`
+	// BreachedHashesPath is a file with SHA-1 hashes of breached passwords in the
+	// Have I Been Pwned format sorted by hash, the check is disabled when it is empty.
+	// The file is searched on disk and is not loaded into memory
+	BreachedHashesPath string        `yaml:"breached_hashes_path" env:"PASSWORD_BREACHED_HASHES_PATH"`
+	Hashing            HashingConfig `yaml:"hashing"`
+	// Peppers is a comma separated list of "<version>:<base64 key>", the highest version is used
+	// for new hashes and older ones have to be kept until users log in and get rehashed
+	Peppers string `yaml:"peppers" env:"PASSWORD_PEPPERS"`
+}
+
+// HashingConfig selects the algorithm for new password hashes, hashes made with
+// another algorithm or weaker parameters are upgraded on login
+type HashingConfig struct {
+	// Algorithm is bcrypt or argon2id
+	Algorithm  string `yaml:"algorithm" env-default:"argon2id"`
+	BcryptCost int    `yaml:"bcrypt_cost" env-default:"10"`
+	// Argon2Memory is in KiB
+	Argon2Memory      uint32 `yaml:"argon2_memory" env-default:"19456"`
+	Argon2Iterations  uint32 `yaml:"argon2_iterations" env-default:"2"`
+	Argon2Parallelism uint8  `yaml:"argon2_parallelism" env-default:"1"`
+}
+
+type LoginConfig struct {
+	// ConstantTime hides whether an email is registered: unknown emails cost the same
+	// hash verification as wrong passwords and both return Unauthenticated
+	ConstantTime bool `yaml:"constant_time" env:"LOGIN_CONSTANT_TIME" env-default:"false"`
+}
+
+// TracingConfig selects where OpenTelemetry spans are exported
+type TracingConfig struct {
+	// Exporter is none, otlp (gRPC), stdout or file
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	// Endpoint is host:port of the OTLP collector
+	Endpoint string `yaml:"endpoint" env:"TRACING_ENDPOINT" env-default:"localhost:4317"`
+	Insecure bool   `yaml:"insecure" env:"TRACING_INSECURE" env-default:"false"`
+	// FilePath is the file the file exporter appends spans to
+	FilePath    string  `yaml:"file_path" env:"TRACING_FILE_PATH"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+}
+
+// LogValue is the config as it is safe to log: secrets such as peppers, the checkpoint key
+// and the webhook URL, which may carry credentials, are reported only as set or not
+func (c Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("env", c.Env),
+		slog.String("storage_path", c.StoragePath),
+		slog.Duration("token_ttl", c.TokenTTL),
+		slog.Group("grpc",
+			slog.Int("port", c.GRPC.Port),
+			slog.Duration("timeout", c.GRPC.Timeout),
+			slog.Bool("tls", c.GRPC.TLS.CertFile != ""),
+			slog.Bool("mtls", c.GRPC.TLS.ClientCAFile != ""),
+			slog.Bool("reflection", c.GRPC.Reflection),
+		),
+		slog.Int("http_port", c.HTTP.Port),
+		slog.Int("gateway_port", c.Gateway.Port),
+		slog.Group("audit",
+			slog.String("file_path", c.Audit.FilePath),
+			slog.Bool("webhook", c.Audit.WebhookURL != ""),
+			slog.Bool("checkpoint_key", c.Audit.CheckpointKey != ""),
+		),
+		slog.Group("password",
+			slog.String("algorithm", c.Password.Hashing.Algorithm),
+			slog.Bool("breached_check", c.Password.BreachedHashesPath != ""),
+			slog.Bool("peppers", c.Password.Peppers != ""),
+		),
+		slog.Bool("login_constant_time", c.Login.ConstantTime),
+		slog.String("tracing_exporter", c.Tracing.Exporter),
+		slog.Duration("shutdown_timeout", c.ShutdownTimeout),
+	)
 }
 
 func MustLoad() *Config {
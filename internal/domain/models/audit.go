@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+type AuditEventType string
+
+const (
+	AuditRegister         AuditEventType = "register"
+	AuditLogin            AuditEventType = "login"
+	AuditIsAdmin          AuditEventType = "is_admin"
+	AuditUserUpdated      AuditEventType = "user_updated"
+	AuditUsersListed      AuditEventType = "users_listed"
+	AuditUserDisabled     AuditEventType = "user_disabled"
+	AuditUserEnabled      AuditEventType = "user_enabled"
+	AuditUserDeleted      AuditEventType = "user_deleted"
+	AuditUserErased       AuditEventType = "user_erased"
+	AuditUserDataExported AuditEventType = "user_data_exported"
+	AuditEventsListed     AuditEventType = "audit_events_listed"
+	AuditSessionRevoked   AuditEventType = "session_revoked"
+	AuditAdminGranted     AuditEventType = "admin_granted"
+	AuditAdminRevoked     AuditEventType = "admin_revoked"
+	AuditUserImported     AuditEventType = "user_imported"
+)
+
+type AuditOutcome string
+
+const (
+	AuditSuccess AuditOutcome = "success"
+	AuditFailure AuditOutcome = "failure"
+)
+
+// AuditEvent is a security-relevant event. Zero ids mean the actor, subject or app is unknown.
+type AuditEvent struct {
+	Id        int64          `json:"id,omitempty"`
+	Type      AuditEventType `json:"type"`
+	Outcome   AuditOutcome   `json:"outcome"`
+	ActorId   int64          `json:"actor_id,omitempty"`
+	SubjectId int64          `json:"subject_id,omitempty"`
+	AppId     int            `json:"app_id,omitempty"`
+	IP        string         `json:"ip,omitempty"`
+	UserAgent string         `json:"user_agent,omitempty"`
+	// Reason explains a failure, it never contains credentials
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// PrevHash and Hash link persisted events into a tamper-evident chain, see audit.ChainHash
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+	// ClientDigest is set when IP and UserAgent are stored apart from the event so that they can be
+	// erased with the user, see audit.ClientDigest. ClientSalt is nil once they are erased.
+	ClientDigest string `json:"client_digest,omitempty"`
+	ClientSalt   []byte `json:"-"`
+}
+
+// AuditCheckpoint is a signed statement that the chain ended with Hash at event EventId
+type AuditCheckpoint struct {
+	Id        int64     `json:"id"`
+	EventId   int64     `json:"event_id"`
+	Hash      string    `json:"hash"`
+	Signature []byte    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter narrows down events returned by ListAuditEvents. Zero values mean no filtering.
+type AuditFilter struct {
+	Type      AuditEventType
+	Outcome   AuditOutcome
+	ActorId   int64
+	SubjectId int64
+	AppId     int
+	Since     time.Time
+	Until     time.Time
+	// UserId matches events the user either made or is the subject of
+	UserId int64
+}
+
+type AuditPage struct {
+	Events        []AuditEvent `json:"events"`
+	NextPageToken string       `json:"next_page_token,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Session is created on every successful login, tokens carry its id in the sid claim
+type Session struct {
+	Id         string    `json:"id"`
+	UserId     int64     `json:"user_id"`
+	AppId      int       `json:"app_id"`
+	Device     string    `json:"device,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// RevokedAt is zero for sessions that have not been revoked
+	RevokedAt time.Time `json:"revoked_at"`
+	// Current marks the session of the token used for the request
+	Current bool `json:"current,omitempty"`
+}
+
+func (s Session) IsActive(now time.Time) bool {
+	return s.RevokedAt.IsZero() && now.Before(s.ExpiresAt)
+}
@@ -1,7 +1,91 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type User struct {
 	Id       int64  `json:"id"`
 	Email    string `json:"email"`
-	PassHash []byte `json:"password"`
+	PassHash []byte `json:"-"`
+	// PepperVersion is the version of the pepper mixed into the password before hashing, 0 for none
+	PepperVersion int        `json:"-"`
+	IsAdmin       bool       `json:"is_admin"`
+	Status        UserStatus `json:"status"`
+	UserProfile
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type UserStatus string
+
+const (
+	UserStatusActive   UserStatus = "active"
+	UserStatusDisabled UserStatus = "disabled"
+	// UserStatusPendingDeletion users are erased after the retention period
+	UserStatusPendingDeletion UserStatus = "pending_deletion"
+	// UserStatusDeleted users have been anonymized, only the row id is kept for audit history
+	UserStatusDeleted UserStatus = "deleted"
+)
+
+func (s UserStatus) IsValid() bool {
+	switch s {
+	case UserStatusActive, UserStatusDisabled, UserStatusPendingDeletion, UserStatusDeleted:
+		return true
+	}
+	return false
+}
+
+// Roles used to filter users, derived from User.IsAdmin
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// UserFilter narrows down users returned by ListUsers. Zero values mean no filtering.
+type UserFilter struct {
+	EmailPrefix string
+	Role        string
+	Status      UserStatus
+	// Descending sorts users from the newest to the oldest instead of by creation order
+	Descending bool
+}
+
+// UserCursor points at the last user of a page, users are ordered by (CreatedAt, Id)
+type UserCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	Id        int64     `json:"id"`
+}
+
+type UserPage struct {
+	Users         []User `json:"users"`
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+// UserProfile holds the user fields that can be changed through UpdateUser
+type UserProfile struct {
+	DisplayName string          `json:"display_name"`
+	Locale      string          `json:"locale"`
+	Timezone    string          `json:"timezone"`
+	AvatarURL   string          `json:"avatar_url"`
+	Metadata    json.RawMessage `json:"metadata"`
+}
+
+// Field mask paths of UserProfile
+const (
+	ProfileDisplayName = "display_name"
+	ProfileLocale      = "locale"
+	ProfileTimezone    = "timezone"
+	ProfileAvatarURL   = "avatar_url"
+	ProfileMetadata    = "metadata"
+)
+
+// ProfilePaths lists every path accepted in a UserProfile field mask
+var ProfilePaths = []string{
+	ProfileDisplayName,
+	ProfileLocale,
+	ProfileTimezone,
+	ProfileAvatarURL,
+	ProfileMetadata,
 }
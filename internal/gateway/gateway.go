@@ -0,0 +1,379 @@
+package gateway
+
+// HTTP/JSON gateway: REST эндпоинты поверх тех же обработчиков, что у gRPC сервера
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxBodySize limits request bodies, requests of the service are small
+const maxBodySize = 1 << 20
+
+var (
+	marshalOptions   = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+	unmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// route maps an HTTP method and path onto a unary RPC, fields of the request message
+// are read from path wildcards and the query string or from the JSON body
+type route struct {
+	method     string
+	path       string
+	fullMethod string
+	summary    string
+	request    protoreflect.MessageDescriptor
+	response   protoreflect.MessageDescriptor
+	newRequest func() proto.Message
+	call       func(ctx context.Context, req proto.Message) (proto.Message, error)
+}
+
+// unary creates a route for a handler of authv1.AuthServer
+func unary[Req, Resp proto.Message](
+	method, path, fullMethod, summary string,
+	call func(context.Context, Req) (Resp, error),
+) route {
+	var req Req
+	var resp Resp
+	return route{
+		method:     method,
+		path:       path,
+		fullMethod: fullMethod,
+		summary:    summary,
+		request:    req.ProtoReflect().Descriptor(),
+		response:   resp.ProtoReflect().Descriptor(),
+		newRequest: func() proto.Message {
+			return req.ProtoReflect().Type().New().Interface()
+		},
+		call: func(ctx context.Context, req proto.Message) (proto.Message, error) {
+			return call(ctx, req.(Req))
+		},
+	}
+}
+
+// routes lists the REST endpoints, a new RPC of the service needs a line here
+func routes(server authv1.AuthServer) []route {
+	return []route{
+		unary(http.MethodPost, "/v1/auth/register", authv1.Auth_Register_FullMethodName,
+			"Register a new user", server.Register),
+		unary(http.MethodPost, "/v1/auth/login", authv1.Auth_Login_FullMethodName,
+			"Log in to an app and get a token", server.Login),
+		unary(http.MethodGet, "/v1/users/{user_id}/is-admin", authv1.Auth_IsAdmin_FullMethodName,
+			"Check whether a user is an admin, a bearer token is optional but has to be valid if passed", server.IsAdmin),
+		unary(http.MethodGet, "/v1/users/{user_id}", authv1.Auth_GetUser_FullMethodName,
+			"Get a user, requires a bearer token of the user or an admin", server.GetUser),
+		unary(http.MethodGet, "/v1/users/by-email", authv1.Auth_GetUserByEmail_FullMethodName,
+			"Find a user by email, requires a bearer token of the user or an admin", server.GetUserByEmail),
+		unary(http.MethodPatch, "/v1/users/{user_id}", authv1.Auth_UpdateUser_FullMethodName,
+			"Update profile fields listed in update_mask, requires a bearer token of the user or an admin", server.UpdateUser),
+		unary(http.MethodGet, "/v1/users/{user_id}/export", authv1.Auth_ExportUserData_FullMethodName,
+			"Export everything stored about a user as JSON, requires a bearer token of the user or an admin", server.ExportUserData),
+		unary(http.MethodGet, "/v1/sessions", authv1.Auth_ListSessions_FullMethodName,
+			"List active sessions of the caller, requires a bearer token", server.ListSessions),
+		unary(http.MethodDelete, "/v1/sessions/{session_id}", authv1.Auth_RevokeSession_FullMethodName,
+			"Revoke a session of the caller, requires a bearer token", server.RevokeSession),
+		unary(http.MethodPost, "/v1/sessions/revoke-others", authv1.Auth_RevokeAllOtherSessions_FullMethodName,
+			"Revoke every session of the caller except the current one, requires a bearer token", server.RevokeAllOtherSessions),
+		unary(http.MethodGet, "/v1/users", authv1.Auth_ListUsers_FullMethodName,
+			"List users, requires a bearer token of an admin", server.ListUsers),
+		unary(http.MethodPost, "/v1/users/{user_id}/disable", authv1.Auth_DisableUser_FullMethodName,
+			"Disable a user, requires a bearer token of an admin", server.DisableUser),
+		unary(http.MethodPost, "/v1/users/{user_id}/enable", authv1.Auth_EnableUser_FullMethodName,
+			"Enable a user or cancel a pending deletion, requires a bearer token of an admin", server.EnableUser),
+		unary(http.MethodDelete, "/v1/users/{user_id}", authv1.Auth_DeleteUser_FullMethodName,
+			"Schedule a user for erasure, requires a bearer token of an admin", server.DeleteUser),
+		unary(http.MethodGet, "/v1/audit-events", authv1.Auth_ListAuditEvents_FullMethodName,
+			"List audit events newest first, since and until are RFC 3339 timestamps, requires a bearer token of an admin",
+			server.ListAuditEvents),
+	}
+}
+
+type Gateway struct {
+	mux *http.ServeMux
+}
+
+// New returns the gateway calling server through interceptor, see grpcapp.AppGrpc.UnaryInterceptor,
+// so requests are validated, logged, traced and mapped to errors the same way as gRPC calls
+func New(server authv1.AuthServer, interceptor grpc.UnaryServerInterceptor) (*Gateway, error) {
+	const op = "gateway.New"
+
+	routes := routes(server)
+
+	document, err := openAPIDocument(routes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	mux := http.NewServeMux()
+	for _, rt := range routes {
+		mux.Handle(rt.method+" "+rt.path, handler(rt, server, interceptor))
+	}
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(document)
+	})
+
+	return &Gateway{mux: mux}, nil
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+func handler(rt route, server authv1.AuthServer, interceptor grpc.UnaryServerInterceptor) http.Handler {
+	info := &grpc.UnaryServerInfo{Server: server, FullMethod: rt.fullMethod}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := rt.newRequest()
+		if err := decodeRequest(w, r, rt, msg); err != nil {
+			writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+
+		stream := &headerStream{method: rt.fullMethod}
+		ctx := grpc.NewContextWithServerTransportStream(incomingContext(r), stream)
+
+		resp, err := interceptor(ctx, msg, info, func(ctx context.Context, req any) (any, error) {
+			return rt.call(ctx, req.(proto.Message))
+		})
+
+		for key, values := range stream.header {
+			for _, value := range values {
+				w.Header().Add(textproto.CanonicalMIMEHeaderKey(key), value)
+			}
+		}
+
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeMessage(w, http.StatusOK, resp.(proto.Message))
+	})
+}
+
+// decodeRequest fills msg from the JSON body, path wildcards and the query string,
+// path wildcards take precedence over the body
+func decodeRequest(w http.ResponseWriter, r *http.Request, rt route, msg proto.Message) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+		if len(body) > 0 {
+			if err := unmarshalOptions.Unmarshal(body, msg); err != nil {
+				return fmt.Errorf("invalid JSON body: %w", err)
+			}
+		}
+	}
+
+	for key, values := range r.URL.Query() {
+		if err := setField(msg, key, values[len(values)-1]); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range pathWildcards(rt.path) {
+		if err := setField(msg, name, r.PathValue(name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pathWildcards returns names of {wildcards} in a ServeMux pattern
+func pathWildcards(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}"))
+		}
+	}
+	return names
+}
+
+// setField sets a scalar or google.protobuf.Timestamp field found by its proto or JSON name
+// from its text form, timestamps are RFC 3339 as in the JSON mapping
+func setField(msg proto.Message, name, value string) error {
+	fields := msg.ProtoReflect().Descriptor().Fields()
+	field := fields.ByName(protoreflect.Name(name))
+	if field == nil {
+		field = fields.ByJSONName(name)
+	}
+	if field == nil || field.IsList() || field.IsMap() {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+
+	var v protoreflect.Value
+	var err error
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		v = protoreflect.ValueOfString(value)
+	case protoreflect.BoolKind:
+		var b bool
+		b, err = strconv.ParseBool(value)
+		v = protoreflect.ValueOfBool(b)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 32)
+		v = protoreflect.ValueOfInt32(int32(n))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 64)
+		v = protoreflect.ValueOfInt64(n)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 32)
+		v = protoreflect.ValueOfUint32(uint32(n))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 64)
+		v = protoreflect.ValueOfUint64(n)
+	case protoreflect.EnumKind:
+		enumValue := field.Enum().Values().ByName(protoreflect.Name(value))
+		if enumValue == nil {
+			return fmt.Errorf("invalid value of parameter %q", name)
+		}
+		v = protoreflect.ValueOfEnum(enumValue.Number())
+	case protoreflect.MessageKind:
+		if !isTimestamp(field) {
+			return fmt.Errorf("parameter %q can not be set from the URL", name)
+		}
+		var t time.Time
+		t, err = time.Parse(time.RFC3339Nano, value)
+		v = protoreflect.ValueOfMessage(timestamppb.New(t).ProtoReflect())
+	default:
+		return fmt.Errorf("parameter %q can not be set from the URL", name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value of parameter %q", name)
+	}
+
+	msg.ProtoReflect().Set(field, v)
+	return nil
+}
+
+func isTimestamp(field protoreflect.FieldDescriptor) bool {
+	return field.Kind() == protoreflect.MessageKind && field.Message().FullName() == "google.protobuf.Timestamp"
+}
+
+// incomingContext makes the request look like a gRPC call to the interceptors:
+// headers become incoming metadata and the client address becomes the peer
+func incomingContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	for key, values := range r.Header {
+		md.Append(strings.ToLower(key), values...)
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+
+	p := &peer.Peer{Addr: remoteAddr(r.RemoteAddr)}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+	}
+	return peer.NewContext(ctx, p)
+}
+
+// remoteAddr is the client address of an HTTP request as a net.Addr
+type remoteAddr string
+
+func (a remoteAddr) Network() string { return "tcp" }
+func (a remoteAddr) String() string  { return string(a) }
+
+var _ net.Addr = remoteAddr("")
+
+// headerStream collects headers set by interceptors and handlers with grpc.SetHeader
+type headerStream struct {
+	method string
+	header metadata.MD
+}
+
+var _ grpc.ServerTransportStream = (*headerStream)(nil)
+
+func (s *headerStream) Method() string {
+	return s.method
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *headerStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+// SetTrailer drops trailers, HTTP/1 responses have no place for them
+func (s *headerStream) SetTrailer(metadata.MD) error {
+	return nil
+}
+
+func writeMessage(w http.ResponseWriter, code int, msg proto.Message) {
+	body, err := marshalOptions.Marshal(msg)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
+
+// writeError writes the status of err as google.rpc.Status JSON with details,
+// errors that are not statuses are reported as internal
+func writeError(w http.ResponseWriter, err error) {
+	st, ok := status.FromError(err)
+	if !ok {
+		st = status.New(codes.Internal, "internal server error")
+	}
+	writeMessage(w, httpStatus(st.Code()), st.Proto())
+}
+
+// httpStatus maps gRPC codes to HTTP statuses the same way grpc-gateway does
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
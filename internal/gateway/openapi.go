@@ -0,0 +1,203 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// openAPIDocument describes the routes as an OpenAPI 3 document, schemas are generated
+// from the protobuf messages as they are encoded by the gateway
+func openAPIDocument(routes []route) ([]byte, error) {
+	schemas := make(map[string]any)
+	statusDescriptor := (&spb.Status{}).ProtoReflect().Descriptor()
+	addSchema(schemas, statusDescriptor)
+
+	paths := make(map[string]map[string]any)
+	for _, rt := range routes {
+		service, method, _ := strings.Cut(strings.TrimPrefix(rt.fullMethod, "/"), "/")
+
+		operation := map[string]any{
+			"operationId": method,
+			"summary":     rt.summary,
+			"tags":        []string{service},
+			"responses": map[string]any{
+				"200": jsonContent("OK", messageRef(schemas, rt.response)),
+				"default": jsonContent("Error, details are google.rpc error details such as ErrorInfo and BadRequest",
+					messageRef(schemas, statusDescriptor)),
+			},
+		}
+
+		wildcards := pathWildcards(rt.path)
+		parameters := make([]any, 0)
+		for _, name := range wildcards {
+			field := rt.request.Fields().ByName(protoreflect.Name(name))
+			parameters = append(parameters, map[string]any{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   fieldSchema(schemas, field),
+			})
+		}
+
+		if rt.method == http.MethodGet || rt.method == http.MethodDelete {
+			fields := rt.request.Fields()
+			for i := 0; i < fields.Len(); i++ {
+				field := fields.Get(i)
+				if isWildcard(wildcards, field) || (field.Kind() == protoreflect.MessageKind && !isTimestamp(field)) || field.IsList() || field.IsMap() {
+					continue
+				}
+				parameters = append(parameters, map[string]any{
+					"name":   string(field.Name()),
+					"in":     "query",
+					"schema": fieldSchema(schemas, field),
+				})
+			}
+		} else {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": messageRef(schemas, rt.request)},
+				},
+			}
+		}
+
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+
+		// ServeMux wildcards have the same syntax as OpenAPI path templates
+		if paths[rt.path] == nil {
+			paths[rt.path] = make(map[string]any)
+		}
+		paths[rt.path][strings.ToLower(rt.method)] = operation
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "usekit-auth",
+			"version": "v1",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}, "", "  ")
+}
+
+func jsonContent(description string, schema any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schema},
+		},
+	}
+}
+
+func isWildcard(wildcards []string, field protoreflect.FieldDescriptor) bool {
+	for _, name := range wildcards {
+		if string(field.Name()) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// messageRef returns a reference to the schema of message, adding it and the messages it uses to schemas
+func messageRef(schemas map[string]any, message protoreflect.MessageDescriptor) map[string]any {
+	addSchema(schemas, message)
+	return map[string]any{"$ref": "#/components/schemas/" + string(message.FullName())}
+}
+
+func addSchema(schemas map[string]any, message protoreflect.MessageDescriptor) {
+	name := string(message.FullName())
+	if _, ok := schemas[name]; ok {
+		return
+	}
+	// заглушка на случай рекурсивных сообщений
+	schemas[name] = nil
+
+	properties := make(map[string]any)
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		properties[string(field.Name())] = fieldSchema(schemas, field)
+	}
+
+	schemas[name] = map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+func fieldSchema(schemas map[string]any, field protoreflect.FieldDescriptor) map[string]any {
+	if field.IsMap() {
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": fieldSchema(schemas, field.MapValue()),
+		}
+	}
+
+	schema := kindSchema(schemas, field)
+	if field.IsList() {
+		return map[string]any{"type": "array", "items": schema}
+	}
+	return schema
+}
+
+// kindSchema follows the protobuf JSON mapping: 64-bit integers are strings, enums are names
+func kindSchema(schemas map[string]any, field protoreflect.FieldDescriptor) map[string]any {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]any{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		names := make([]string, 0, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		return map[string]any{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageSchema(schemas, field.Message())
+	default:
+		return map[string]any{}
+	}
+}
+
+// messageSchema returns the JSON form of well-known types and a reference for other messages
+func messageSchema(schemas map[string]any, message protoreflect.MessageDescriptor) map[string]any {
+	switch message.FullName() {
+	case "google.protobuf.Any":
+		return map[string]any{
+			"type":                 "object",
+			"properties":           map[string]any{"@type": map[string]any{"type": "string"}},
+			"additionalProperties": true,
+		}
+	case "google.protobuf.Timestamp":
+		return map[string]any{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration", "google.protobuf.FieldMask":
+		return map[string]any{"type": "string"}
+	case "google.protobuf.Struct":
+		return map[string]any{"type": "object", "additionalProperties": true}
+	default:
+		return messageRef(schemas, message)
+	}
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+
+	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"usekit-auth/internal/domain/models"
+)
+
+func (server *serverApi) ListUsers(ctx context.Context, req *authv1.ListUsersRequest) (*authv1.ListUsersResponse, error) {
+	if req.GetPageSize() < 0 {
+		return nil, invalidArgument("page_size must not be negative", fieldViolation("page_size", "page_size must not be negative"))
+	}
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := server.auth.ListUsers(ctx, token, models.UserFilter{
+		EmailPrefix: req.GetEmailPrefix(),
+		Role:        req.GetRole(),
+		Status:      models.UserStatus(req.GetStatus()),
+		Descending:  req.GetDescending(),
+	}, int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	users := make([]*authv1.User, 0, len(page.Users))
+	for _, user := range page.Users {
+		users = append(users, toProtoUser(user))
+	}
+
+	return &authv1.ListUsersResponse{Users: users, NextPageToken: page.NextPageToken}, nil
+}
+
+func (server *serverApi) DisableUser(ctx context.Context, req *authv1.DisableUserRequest) (*authv1.DisableUserResponse, error) {
+	if err := server.changeUser(ctx, req.GetUserId(), server.auth.DisableUser); err != nil {
+		return nil, err
+	}
+	return &authv1.DisableUserResponse{}, nil
+}
+
+func (server *serverApi) EnableUser(ctx context.Context, req *authv1.EnableUserRequest) (*authv1.EnableUserResponse, error) {
+	if err := server.changeUser(ctx, req.GetUserId(), server.auth.EnableUser); err != nil {
+		return nil, err
+	}
+	return &authv1.EnableUserResponse{}, nil
+}
+
+func (server *serverApi) DeleteUser(ctx context.Context, req *authv1.DeleteUserRequest) (*authv1.DeleteUserResponse, error) {
+	if err := server.changeUser(ctx, req.GetUserId(), server.auth.DeleteUser); err != nil {
+		return nil, err
+	}
+	return &authv1.DeleteUserResponse{}, nil
+}
+
+func (server *serverApi) ListAuditEvents(
+	ctx context.Context,
+	req *authv1.ListAuditEventsRequest,
+) (*authv1.ListAuditEventsResponse, error) {
+	if req.GetPageSize() < 0 {
+		return nil, invalidArgument("page_size must not be negative", fieldViolation("page_size", "page_size must not be negative"))
+	}
+	outcome := models.AuditOutcome(req.GetOutcome())
+	if outcome != "" && outcome != models.AuditSuccess && outcome != models.AuditFailure {
+		return nil, invalidArgument("unknown outcome", fieldViolation("outcome", "outcome must be success or failure"))
+	}
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := models.AuditFilter{
+		Type:      models.AuditEventType(req.GetType()),
+		Outcome:   outcome,
+		ActorId:   req.GetActorId(),
+		SubjectId: req.GetSubjectId(),
+		AppId:     int(req.GetAppId()),
+	}
+	if req.GetSince() != nil {
+		filter.Since = req.GetSince().AsTime()
+	}
+	if req.GetUntil() != nil {
+		filter.Until = req.GetUntil().AsTime()
+	}
+
+	page, err := server.auth.ListAuditEvents(ctx, token, filter, int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	events := make([]*authv1.AuditEvent, 0, len(page.Events))
+	for _, event := range page.Events {
+		events = append(events, toProtoAuditEvent(event))
+	}
+
+	return &authv1.ListAuditEventsResponse{Events: events, NextPageToken: page.NextPageToken}, nil
+}
+
+// changeUser validates userId and calls an admin action of the service with the caller token
+func (server *serverApi) changeUser(
+	ctx context.Context,
+	userId int64,
+	change func(ctx context.Context, token string, userId int64) error,
+) error {
+	if err := validateUserId(userId); err != nil {
+		return err
+	}
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	return toStatus(change(ctx, token, userId))
+}
+
+func toProtoAuditEvent(event models.AuditEvent) *authv1.AuditEvent {
+	return &authv1.AuditEvent{
+		Id:        event.Id,
+		Type:      string(event.Type),
+		Outcome:   string(event.Outcome),
+		ActorId:   event.ActorId,
+		SubjectId: event.SubjectId,
+		AppId:     int32(event.AppId),
+		Ip:        event.IP,
+		UserAgent: event.UserAgent,
+		Reason:    event.Reason,
+		CreatedAt: timestamppb.New(event.CreatedAt),
+		Hash:      event.Hash,
+	}
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestListUsers(t *testing.T) {
+	env := newTestEnv(t)
+	_, userToken := env.user(t, "user@example.com", false)
+	env.user(t, "other@example.com", false)
+	adminId, adminToken := env.user(t, "admin@example.com", true)
+
+	tests := []struct {
+		name   string
+		token  string
+		req    *authv1.ListUsersRequest
+		emails []string
+		code   codes.Code
+	}{
+		{name: "all", token: adminToken, req: &authv1.ListUsersRequest{},
+			emails: []string{"user@example.com", "other@example.com", "admin@example.com"}},
+		{name: "descending", token: adminToken, req: &authv1.ListUsersRequest{Descending: true},
+			emails: []string{"admin@example.com", "other@example.com", "user@example.com"}},
+		{name: "admins", token: adminToken, req: &authv1.ListUsersRequest{Role: "admin"},
+			emails: []string{"admin@example.com"}},
+		{name: "email prefix", token: adminToken, req: &authv1.ListUsersRequest{EmailPrefix: "oth"},
+			emails: []string{"other@example.com"}},
+		{name: "unknown role", token: adminToken, req: &authv1.ListUsersRequest{Role: "owner"}, code: codes.InvalidArgument},
+		{name: "unknown status", token: adminToken, req: &authv1.ListUsersRequest{Status: "gone"}, code: codes.InvalidArgument},
+		{name: "negative page size", token: adminToken, req: &authv1.ListUsersRequest{PageSize: -1}, code: codes.InvalidArgument},
+		{name: "invalid page token", token: adminToken, req: &authv1.ListUsersRequest{PageToken: "x"}, code: codes.InvalidArgument},
+		{name: "not an admin", token: userToken, req: &authv1.ListUsersRequest{}, code: codes.PermissionDenied},
+		{name: "no token", req: &authv1.ListUsersRequest{}, code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := env.server.ListUsers(withToken(tt.token), tt.req)
+			requireCode(t, err, tt.code)
+			if tt.code != codes.OK {
+				return
+			}
+
+			var emails []string
+			for _, user := range resp.GetUsers() {
+				emails = append(emails, user.GetEmail())
+			}
+			require.Equal(t, tt.emails, emails)
+			require.Empty(t, resp.GetNextPageToken())
+		})
+	}
+
+	first, err := env.server.ListUsers(withToken(adminToken), &authv1.ListUsersRequest{PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, first.GetUsers(), 2)
+	require.NotEmpty(t, first.GetNextPageToken())
+
+	second, err := env.server.ListUsers(withToken(adminToken), &authv1.ListUsersRequest{PageSize: 2, PageToken: first.GetNextPageToken()})
+	require.NoError(t, err)
+	require.Len(t, second.GetUsers(), 1)
+	require.Equal(t, adminId, second.GetUsers()[0].GetId())
+	require.Empty(t, second.GetNextPageToken())
+}
+
+func TestChangeUser(t *testing.T) {
+	env := newTestEnv(t)
+	userId, userToken := env.user(t, "user@example.com", false)
+	otherId, otherToken := env.user(t, "other@example.com", false)
+	adminId, adminToken := env.user(t, "admin@example.com", true)
+
+	disable := func(ctx context.Context, userId int64) error {
+		_, err := env.server.DisableUser(ctx, &authv1.DisableUserRequest{UserId: userId})
+		return err
+	}
+	enable := func(ctx context.Context, userId int64) error {
+		_, err := env.server.EnableUser(ctx, &authv1.EnableUserRequest{UserId: userId})
+		return err
+	}
+	deleteUser := func(ctx context.Context, userId int64) error {
+		_, err := env.server.DeleteUser(ctx, &authv1.DeleteUserRequest{UserId: userId})
+		return err
+	}
+
+	// cases run in order, the status is checked after each successful change
+	tests := []struct {
+		name       string
+		action     func(ctx context.Context, userId int64) error
+		token      string
+		userId     int64
+		code       codes.Code
+		wantStatus string
+	}{
+		{name: "user disables another user", action: disable, token: userToken, userId: otherId, code: codes.PermissionDenied},
+		{name: "user enables another user", action: enable, token: userToken, userId: otherId, code: codes.PermissionDenied},
+		{name: "user deletes another user", action: deleteUser, token: userToken, userId: otherId, code: codes.PermissionDenied},
+		{name: "user deletes themselves", action: deleteUser, token: userToken, userId: userId, code: codes.PermissionDenied},
+		{name: "no token", action: disable, userId: otherId, code: codes.Unauthenticated},
+		{name: "no user id", action: disable, token: adminToken, code: codes.InvalidArgument},
+		{name: "admin disables themselves", action: disable, token: adminToken, userId: adminId, code: codes.FailedPrecondition},
+		{name: "admin deletes themselves", action: deleteUser, token: adminToken, userId: adminId, code: codes.FailedPrecondition},
+		{name: "admin disables unknown user", action: disable, token: adminToken, userId: 1000, code: codes.NotFound},
+		{name: "admin disables a user", action: disable, token: adminToken, userId: otherId, wantStatus: "disabled"},
+		// disabling revokes the sessions of the user
+		{name: "disabled user is rejected", action: enable, token: otherToken, userId: userId, code: codes.Unauthenticated},
+		{name: "admin enables a user", action: enable, token: adminToken, userId: otherId, wantStatus: "active"},
+		{name: "admin deletes a user", action: deleteUser, token: adminToken, userId: otherId, wantStatus: "pending_deletion"},
+		{name: "admin cancels the deletion", action: enable, token: adminToken, userId: otherId, wantStatus: "active"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requireCode(t, tt.action(withToken(tt.token), tt.userId), tt.code)
+			if tt.code != codes.OK {
+				return
+			}
+
+			resp, err := env.server.GetUser(withToken(adminToken), &authv1.GetUserRequest{UserId: tt.userId})
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.GetUser().GetStatus())
+		})
+	}
+}
+
+// tokens issued before a user was disabled or deleted stay rejected once the user is enabled again
+func TestChangeUser_RevokesSessions(t *testing.T) {
+	env := newTestEnv(t)
+	_, adminToken := env.user(t, "admin@example.com", true)
+
+	tests := []struct {
+		name   string
+		action func(ctx context.Context, in *authv1.DisableUserRequest) error
+	}{
+		{name: "disable", action: func(ctx context.Context, in *authv1.DisableUserRequest) error {
+			_, err := env.server.DisableUser(ctx, in)
+			return err
+		}},
+		{name: "delete", action: func(ctx context.Context, in *authv1.DisableUserRequest) error {
+			_, err := env.server.DeleteUser(ctx, &authv1.DeleteUserRequest{UserId: in.GetUserId()})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := tt.name + "@example.com"
+			userId, token := env.user(t, email, false)
+
+			require.NoError(t, tt.action(withToken(adminToken), &authv1.DisableUserRequest{UserId: userId}))
+			_, err := env.server.EnableUser(withToken(adminToken), &authv1.EnableUserRequest{UserId: userId})
+			require.NoError(t, err)
+
+			_, err = env.server.GetUser(withToken(token), &authv1.GetUserRequest{UserId: userId})
+			requireCode(t, err, codes.Unauthenticated)
+			_, err = env.server.IsAdmin(withToken(token), &authv1.IsAdminRequest{UserId: userId})
+			requireCode(t, err, codes.Unauthenticated)
+
+			// a new login works
+			_, err = env.server.GetUser(withToken(env.login(t, email)), &authv1.GetUserRequest{UserId: userId})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestListAuditEvents(t *testing.T) {
+	env := newTestEnv(t)
+	userId, userToken := env.user(t, "user@example.com", false)
+	_, adminToken := env.user(t, "admin@example.com", true)
+
+	_, err := env.server.Login(context.Background(), &authv1.LoginRequest{Email: "user@example.com", Password: "Wrong-Horse-7", AppId: testAppId})
+	requireCode(t, err, codes.Unauthenticated)
+
+	tests := []struct {
+		name  string
+		token string
+		req   *authv1.ListAuditEventsRequest
+		types []string
+		code  codes.Code
+	}{
+		{name: "failed logins", token: adminToken,
+			req:   &authv1.ListAuditEventsRequest{Type: "login", Outcome: "failure"},
+			types: []string{"login"}},
+		{name: "subject", token: adminToken,
+			req:   &authv1.ListAuditEventsRequest{SubjectId: userId, Outcome: "success"},
+			types: []string{"login", "register"}},
+		{name: "until the first event", token: adminToken,
+			req: &authv1.ListAuditEventsRequest{Until: timestamppb.New(time.Now().Add(-time.Hour))}},
+		{name: "unknown outcome", token: adminToken, req: &authv1.ListAuditEventsRequest{Outcome: "maybe"}, code: codes.InvalidArgument},
+		{name: "negative page size", token: adminToken, req: &authv1.ListAuditEventsRequest{PageSize: -1}, code: codes.InvalidArgument},
+		{name: "invalid page token", token: adminToken, req: &authv1.ListAuditEventsRequest{PageToken: "x"}, code: codes.InvalidArgument},
+		{name: "not an admin", token: userToken, req: &authv1.ListAuditEventsRequest{}, code: codes.PermissionDenied},
+		{name: "no token", req: &authv1.ListAuditEventsRequest{}, code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := env.server.ListAuditEvents(withToken(tt.token), tt.req)
+			requireCode(t, err, tt.code)
+			if tt.code != codes.OK {
+				return
+			}
+
+			var types []string
+			for _, event := range resp.GetEvents() {
+				types = append(types, event.GetType())
+				require.NotEmpty(t, event.GetHash())
+				require.False(t, event.GetCreatedAt().AsTime().IsZero())
+			}
+			require.Equal(t, tt.types, types)
+		})
+	}
+
+	first, err := env.server.ListAuditEvents(withToken(adminToken), &authv1.ListAuditEventsRequest{PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, first.GetEvents(), 2)
+	require.NotEmpty(t, first.GetNextPageToken())
+
+	second, err := env.server.ListAuditEvents(withToken(adminToken), &authv1.ListAuditEventsRequest{PageSize: 2, PageToken: first.GetNextPageToken()})
+	require.NoError(t, err)
+	require.NotEmpty(t, second.GetEvents())
+	require.Less(t, second.GetEvents()[0].GetId(), first.GetEvents()[1].GetId())
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"usekit-auth/internal/lib/password"
+	"usekit-auth/internal/services/auth"
+	"usekit-auth/internal/storage"
+)
+
+// errorDomain is the ErrorInfo domain of errors returned by the service
+const errorDomain = "auth.usekit"
+
+// errorMapping describes how an error of the service layer is reported to clients
+type errorMapping struct {
+	err     error
+	code    codes.Code
+	reason  string
+	message string
+	// field is the request field reported in BadRequest, empty if the error is not about a field
+	field string
+}
+
+// errorMappings are checked in order with errors.Is. Storage sentinels are translated by the
+// service layer and are only listed as a safety net for errors that slipped through untranslated.
+var errorMappings = []errorMapping{
+	{err: auth.ErrInvalidCredentials, code: codes.Unauthenticated, reason: "INVALID_CREDENTIALS", message: "invalid email or password"},
+	{err: auth.ErrInvalidToken, code: codes.Unauthenticated, reason: "INVALID_TOKEN", message: "invalid token"},
+	{err: auth.ErrUserInactive, code: codes.PermissionDenied, reason: "USER_INACTIVE", message: "user is not active"},
+	{err: auth.ErrPermissionDenied, code: codes.PermissionDenied, reason: "PERMISSION_DENIED", message: "permission denied"},
+	{err: auth.ErrSelfAction, code: codes.FailedPrecondition, reason: "SELF_ACTION", message: "action is not allowed on own account"},
+	{err: auth.ErrUserNotFound, code: codes.NotFound, reason: "USER_NOT_FOUND", message: "user not found"},
+	{err: auth.ErrSessionNotFound, code: codes.NotFound, reason: "SESSION_NOT_FOUND", message: "session not found"},
+	{err: auth.ErrUserExists, code: codes.AlreadyExists, reason: "USER_EXISTS", message: "user already exists"},
+	{err: auth.ErrInvalidAppId, code: codes.InvalidArgument, reason: "INVALID_APP_ID", message: "invalid app_id", field: "app_id"},
+	{err: auth.ErrInvalidEmail, code: codes.InvalidArgument, reason: "INVALID_EMAIL", message: "invalid email", field: "email"},
+	{err: auth.ErrWeakPassword, code: codes.InvalidArgument, reason: "WEAK_PASSWORD", message: "password does not satisfy the policy", field: "password"},
+	{err: auth.ErrInvalidFieldMask, code: codes.InvalidArgument, reason: "INVALID_FIELD_MASK", message: "invalid field mask", field: "update_mask"},
+	{err: auth.ErrInvalidProfile, code: codes.InvalidArgument, reason: "INVALID_PROFILE", message: "invalid profile", field: "user"},
+	{err: auth.ErrInvalidFilter, code: codes.InvalidArgument, reason: "INVALID_FILTER", message: "invalid filter", field: "filter"},
+	{err: auth.ErrInvalidPageToken, code: codes.InvalidArgument, reason: "INVALID_PAGE_TOKEN", message: "invalid page token", field: "page_token"},
+
+	{err: storage.ErrUserNotFound, code: codes.NotFound, reason: "USER_NOT_FOUND", message: "user not found"},
+	{err: storage.ErrUserExists, code: codes.AlreadyExists, reason: "USER_EXISTS", message: "user already exists"},
+	{err: storage.ErrAppNotFound, code: codes.InvalidArgument, reason: "INVALID_APP_ID", message: "invalid app_id", field: "app_id"},
+	{err: storage.ErrSessionNotFound, code: codes.NotFound, reason: "SESSION_NOT_FOUND", message: "session not found"},
+}
+
+// toStatus translates an error returned by the service layer into a gRPC status error
+// with ErrorInfo and, for errors about a request field, BadRequest details.
+// Unknown errors become codes.Internal without exposing their text.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request canceled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	}
+
+	for _, m := range errorMappings {
+		if !errors.Is(err, m.err) {
+			continue
+		}
+
+		message := m.message
+		if m.field == "" {
+			return withDetails(status.New(m.code, message), m.reason)
+		}
+
+		description := detailOf(err, m.err)
+		var policyErr *password.PolicyError
+		if errors.As(err, &policyErr) {
+			// the policy reason is meant for the user, so it replaces the generic message
+			message, description = policyErr.Error(), policyErr.Error()
+		}
+		if description == "" {
+			description = message
+		}
+
+		return withDetails(status.New(m.code, message), m.reason, fieldViolation(m.field, description))
+	}
+
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// invalidArgument returns an InvalidArgument status error for failed request validation
+func invalidArgument(message string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	return withDetails(status.New(codes.InvalidArgument, message), "INVALID_ARGUMENT", violations...)
+}
+
+func fieldViolation(field, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{Field: field, Description: description}
+}
+
+func withDetails(st *status.Status, reason string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	details := []protoadapt.MessageV1{
+		&errdetails.ErrorInfo{Reason: reason, Domain: errorDomain},
+	}
+	if len(violations) > 0 {
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// detailOf returns the text wrapped after sentinel, e.g. "unknown role" for
+// "services/auth.ListUsers: invalid filter: unknown role", or an empty string
+func detailOf(err, sentinel error) string {
+	text, marker := err.Error(), sentinel.Error()
+	i := strings.Index(text, marker)
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimPrefix(text[i+len(marker):], ": ")
+}
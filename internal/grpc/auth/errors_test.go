@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"usekit-auth/internal/lib/password"
+	"usekit-auth/internal/services/auth"
+	"usekit-auth/internal/storage"
+)
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		code        codes.Code
+		message     string
+		reason      string
+		field       string
+		description string
+	}{
+		{
+			name:    "invalid credentials",
+			err:     fmt.Errorf("services/auth.Login: %w", auth.ErrInvalidCredentials),
+			code:    codes.Unauthenticated,
+			message: "invalid email or password",
+			reason:  "INVALID_CREDENTIALS",
+		},
+		{
+			name:        "invalid app id",
+			err:         fmt.Errorf("services/auth.Login: %w", auth.ErrInvalidAppId),
+			code:        codes.InvalidArgument,
+			message:     "invalid app_id",
+			reason:      "INVALID_APP_ID",
+			field:       "app_id",
+			description: "invalid app_id",
+		},
+		{
+			name:    "user not found",
+			err:     fmt.Errorf("services/auth.IsAdmin: %w", auth.ErrUserNotFound),
+			code:    codes.NotFound,
+			message: "user not found",
+			reason:  "USER_NOT_FOUND",
+		},
+		{
+			name:    "untranslated storage error",
+			err:     fmt.Errorf("services/auth.IsAdmin: storage.sqlite.IsAdmin: %w", storage.ErrUserNotFound),
+			code:    codes.NotFound,
+			message: "user not found",
+			reason:  "USER_NOT_FOUND",
+		},
+		{
+			name:    "user exists",
+			err:     fmt.Errorf("services/auth.RegisterNewUser: %w", auth.ErrUserExists),
+			code:    codes.AlreadyExists,
+			message: "user already exists",
+			reason:  "USER_EXISTS",
+		},
+		{
+			name:    "user inactive",
+			err:     fmt.Errorf("services/auth.Login: %w", auth.ErrUserInactive),
+			code:    codes.PermissionDenied,
+			message: "user is not active",
+			reason:  "USER_INACTIVE",
+		},
+		{
+			name: "weak password",
+			err: fmt.Errorf("services/auth.RegisterNewUser: %w: %w",
+				auth.ErrWeakPassword, &password.PolicyError{Reason: "password must be at least 8 characters long"}),
+			code:        codes.InvalidArgument,
+			message:     "password must be at least 8 characters long",
+			reason:      "WEAK_PASSWORD",
+			field:       "password",
+			description: "password must be at least 8 characters long",
+		},
+		{
+			name:        "invalid filter with detail",
+			err:         fmt.Errorf("services/auth.ListUsers: %w: unknown role %q", auth.ErrInvalidFilter, "root"),
+			code:        codes.InvalidArgument,
+			message:     "invalid filter",
+			reason:      "INVALID_FILTER",
+			field:       "filter",
+			description: `unknown role "root"`,
+		},
+		{
+			name:    "deadline exceeded",
+			err:     fmt.Errorf("storage.sqlite.User: %w", context.DeadlineExceeded),
+			code:    codes.DeadlineExceeded,
+			message: "deadline exceeded",
+		},
+		{
+			name:    "unknown error",
+			err:     errors.New("storage.sqlite.User: disk I/O error"),
+			code:    codes.Internal,
+			message: "internal server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, ok := status.FromError(toStatus(tt.err))
+			require.True(t, ok)
+			require.Equal(t, tt.code, st.Code())
+			require.Equal(t, tt.message, st.Message())
+
+			var info *errdetails.ErrorInfo
+			var badRequest *errdetails.BadRequest
+			for _, detail := range st.Details() {
+				switch d := detail.(type) {
+				case *errdetails.ErrorInfo:
+					info = d
+				case *errdetails.BadRequest:
+					badRequest = d
+				}
+			}
+
+			if tt.reason == "" {
+				require.Nil(t, info)
+			} else {
+				require.NotNil(t, info)
+				require.Equal(t, tt.reason, info.GetReason())
+				require.Equal(t, errorDomain, info.GetDomain())
+			}
+
+			if tt.field == "" {
+				require.Nil(t, badRequest)
+			} else {
+				require.NotNil(t, badRequest)
+				require.Len(t, badRequest.GetFieldViolations(), 1)
+				require.Equal(t, tt.field, badRequest.GetFieldViolations()[0].GetField())
+				require.Equal(t, tt.description, badRequest.GetFieldViolations()[0].GetDescription())
+			}
+		})
+	}
+}
+
+func TestToStatus_Nil(t *testing.T) {
+	require.NoError(t, toStatus(nil))
+}
@@ -4,13 +4,11 @@ package auth
 
 import (
 	"context"
-	"errors"
 	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
-	"usekit-auth/internal/services/auth"
-	"usekit-auth/internal/storage"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/lib/emailaddr"
 )
 
 const emptyIntValue = 0
@@ -27,7 +25,37 @@ type Auth interface {
 		email string,
 		password string,
 	) (userId int64, err error)
-	IsAdmin(ctx context.Context, userId int64) (isAdmin bool, err error)
+	IsAdmin(ctx context.Context, token string, userId int64) (isAdmin bool, err error)
+	GetUser(ctx context.Context, token string, userId int64) (models.User, error)
+	GetUserByEmail(ctx context.Context, token string, email string) (models.User, error)
+	UpdateUser(
+		ctx context.Context,
+		token string,
+		userId int64,
+		profile models.UserProfile,
+		paths []string,
+	) (models.User, error)
+	ExportUserData(ctx context.Context, token string, userId int64) (archive []byte, err error)
+	ListUsers(
+		ctx context.Context,
+		token string,
+		filter models.UserFilter,
+		pageSize int,
+		pageToken string,
+	) (models.UserPage, error)
+	DisableUser(ctx context.Context, token string, userId int64) error
+	EnableUser(ctx context.Context, token string, userId int64) error
+	DeleteUser(ctx context.Context, token string, userId int64) error
+	ListSessions(ctx context.Context, token string) ([]models.Session, error)
+	RevokeSession(ctx context.Context, token string, sessionId string) error
+	RevokeAllOtherSessions(ctx context.Context, token string) (revoked int64, err error)
+	ListAuditEvents(
+		ctx context.Context,
+		token string,
+		filter models.AuditFilter,
+		pageSize int,
+		pageToken string,
+	) (models.AuditPage, error)
 }
 
 type serverApi struct {
@@ -37,21 +65,23 @@ type serverApi struct {
 }
 
 func Register(gRPC *grpc.Server, auth Auth) {
-	authv1.RegisterAuthServer(gRPC, &serverApi{auth: auth})
+	authv1.RegisterAuthServer(gRPC, NewServer(auth))
+}
+
+// NewServer returns the handlers registered by Register, the HTTP gateway calls them directly
+func NewServer(auth Auth) authv1.AuthServer {
+	return &serverApi{auth: auth}
 }
 
 func (server *serverApi) Login(ctx context.Context, req *authv1.LoginRequest) (*authv1.LoginResponse, error) {
 	if err := validateLogin(req); err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+		return nil, err
 	}
 
 	// TODO: implement login via auth service(сервисный слой)
 	token, err := server.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()))
 	if err != nil {
-		if errors.Is(err, storage.ErrUserNotFound) {
-			return nil, status.Error(codes.NotFound, "user not found")
-		}
-		return nil, status.Error(codes.Internal, "internal server error")
+		return nil, toStatus(err)
 	}
 
 	return &authv1.LoginResponse{Token: token}, nil
@@ -59,15 +89,12 @@ func (server *serverApi) Login(ctx context.Context, req *authv1.LoginRequest) (*
 
 func (server *serverApi) Register(ctx context.Context, req *authv1.RegisterRequest) (*authv1.RegisterResponse, error) {
 	if err := validateRegister(req); err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+		return nil, err
 	}
 
 	userId, err := server.auth.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword())
 	if err != nil {
-		if errors.Is(err, storage.ErrUserExists) {
-			return nil, status.Error(codes.AlreadyExists, "user already exists")
-		}
-		return nil, status.Error(codes.Internal, "internal server error")
+		return nil, toStatus(err)
 	}
 
 	return &authv1.RegisterResponse{UserId: userId}, nil
@@ -75,15 +102,18 @@ func (server *serverApi) Register(ctx context.Context, req *authv1.RegisterReque
 
 func (server *serverApi) IsAdmin(ctx context.Context, req *authv1.IsAdminRequest) (*authv1.IsAdminResponse, error) {
 	if err := validateIsAdmin(req); err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+		return nil, err
 	}
 
-	isAdmin, err := server.auth.IsAdmin(ctx, req.GetUserId())
+	// the token is optional for callers that only pass user_id, but a passed token is checked
+	token, err := optionalBearerToken(ctx)
 	if err != nil {
-		if errors.Is(err, auth.ErrUserNotFound) {
-			return nil, status.Error(codes.NotFound, "user not found")
-		}
-		return nil, status.Error(codes.Internal, "internal server error")
+		return nil, err
+	}
+
+	isAdmin, err := server.auth.IsAdmin(ctx, token, req.GetUserId())
+	if err != nil {
+		return nil, toStatus(err)
 	}
 
 	return &authv1.IsAdminResponse{
@@ -91,26 +121,40 @@ func (server *serverApi) IsAdmin(ctx context.Context, req *authv1.IsAdminRequest
 	}, nil
 }
 
+// validateLogin returns an InvalidArgument status error listing every missing field
 func validateLogin(req *authv1.LoginRequest) error {
-	if req.GetEmail() == "" || req.GetPassword() == "" {
-		return status.Error(codes.InvalidArgument, "email and password is required")
+	violations := credentialViolations(req.GetEmail(), req.GetPassword())
+	if len(violations) > 0 {
+		return invalidArgument("email and password is required", violations...)
 	}
 	if req.GetAppId() == emptyIntValue {
-		return status.Error(codes.InvalidArgument, "app_id is required")
+		return invalidArgument("app_id is required", fieldViolation("app_id", "app_id is required"))
 	}
 	return nil
 }
 
 func validateRegister(req *authv1.RegisterRequest) error {
-	if req.GetEmail() == "" || req.GetPassword() == "" {
-		return status.Error(codes.InvalidArgument, "email and password is required")
+	violations := credentialViolations(req.GetEmail(), req.GetPassword())
+	if len(violations) > 0 {
+		return invalidArgument("email and password is required", violations...)
+	}
+	if _, err := emailaddr.Normalize(req.GetEmail()); err != nil {
+		return invalidArgument("invalid email", fieldViolation("email", "email is not a valid address"))
 	}
 	return nil
 }
 
 func validateIsAdmin(req *authv1.IsAdminRequest) error {
-	if req.GetUserId() == 0 {
-		return status.Error(codes.InvalidArgument, "user_id is required")
+	return validateUserId(req.GetUserId())
+}
+
+func credentialViolations(email, password string) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	if email == "" {
+		violations = append(violations, fieldViolation("email", "email is required"))
 	}
-	return nil
+	if password == "" {
+		violations = append(violations, fieldViolation("password", "password is required"))
+	}
+	return violations
 }
@@ -0,0 +1,357 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"usekit-auth/internal/audit"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/lib/password"
+	"usekit-auth/internal/services/auth"
+	"usekit-auth/internal/storage/sqlite"
+)
+
+const (
+	testAppId    = 1
+	testPassword = "Correct-Horse-7"
+)
+
+// testEnv is the server on the real service and a migrated database in a temporary directory
+type testEnv struct {
+	server  authv1.AuthServer
+	service *auth.Auth
+	storage *sqlite.Storage
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	return newTestEnvWith(t, false)
+}
+
+// newTestEnvWith is newTestEnv with the constantTimeLogin option of the service
+func newTestEnvWith(t *testing.T, constantTimeLogin bool) *testEnv {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "auth.db")
+	migrator, err := migrate.New("file://../../../migrations", "sqlite3://"+path)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+	sourceErr, dbErr := migrator.Close()
+	require.NoError(t, sourceErr)
+	require.NoError(t, dbErr)
+
+	st, err := sqlite.New(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = st.Close() })
+
+	argon, err := password.NewArgon2id(password.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1})
+	require.NoError(t, err)
+
+	service := auth.New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		st,
+		st,
+		st,
+		st,
+		audit.NewStorageSink(st),
+		st,
+		password.Policy{MinLength: 8},
+		password.NewHasher(argon),
+		&password.Peppers{},
+		time.Hour,
+		constantTimeLogin,
+	)
+
+	return &testEnv{server: NewServer(service), service: service, storage: st}
+}
+
+// user registers a user and returns its id and a token, admins are granted through the operator API
+func (e *testEnv) user(t *testing.T, email string, isAdmin bool) (int64, string) {
+	t.Helper()
+	ctx := context.Background()
+
+	registered, err := e.server.Register(ctx, &authv1.RegisterRequest{Email: email, Password: testPassword})
+	require.NoError(t, err)
+	if isAdmin {
+		require.NoError(t, e.service.SetAdmin(ctx, registered.GetUserId(), true))
+	}
+
+	loggedIn, err := e.server.Login(ctx, &authv1.LoginRequest{Email: email, Password: testPassword, AppId: testAppId})
+	require.NoError(t, err)
+
+	return registered.GetUserId(), loggedIn.GetToken()
+}
+
+// withToken returns a context of a call with the token in the authorization metadata
+func withToken(token string) context.Context {
+	ctx := context.Background()
+	if token == "" {
+		return ctx
+	}
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(authorizationMetadataKey, "Bearer "+token))
+}
+
+func requireCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+
+	if code == codes.OK {
+		require.NoError(t, err)
+		return
+	}
+	require.Error(t, err)
+	require.Equal(t, code, status.Code(err), err.Error())
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{name: "bearer", values: []string{"Bearer abc.def"}, want: "abc.def"},
+		{name: "scheme is case-insensitive", values: []string{"bearer abc.def"}, want: "abc.def"},
+		{name: "first bearer value", values: []string{"Basic dXNlcjpwYXNz", "Bearer abc.def"}, want: "abc.def"},
+		{name: "no metadata"},
+		{name: "other scheme", values: []string{"Basic dXNlcjpwYXNz"}},
+		{name: "empty token", values: []string{"Bearer  "}},
+		{name: "no scheme", values: []string{"abc.def"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := metadata.MD{}
+			for _, value := range tt.values {
+				md.Append(authorizationMetadataKey, value)
+			}
+
+			token, err := bearerToken(metadata.NewIncomingContext(context.Background(), md))
+			if tt.want == "" {
+				requireCode(t, err, codes.Unauthenticated)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, token)
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	env := newTestEnv(t)
+	userId, userToken := env.user(t, "user@example.com", false)
+	otherId, _ := env.user(t, "other@example.com", false)
+	_, adminToken := env.user(t, "admin@example.com", true)
+
+	tests := []struct {
+		name   string
+		token  string
+		userId int64
+		code   codes.Code
+	}{
+		{name: "self", token: userToken, userId: userId},
+		{name: "admin gets another user", token: adminToken, userId: otherId},
+		{name: "user gets another user", token: userToken, userId: otherId, code: codes.PermissionDenied},
+		{name: "admin gets unknown user", token: adminToken, userId: 1000, code: codes.NotFound},
+		{name: "no token", userId: userId, code: codes.Unauthenticated},
+		{name: "invalid token", token: "not-a-token", userId: userId, code: codes.Unauthenticated},
+		{name: "no user id", token: userToken, code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := env.server.GetUser(withToken(tt.token), &authv1.GetUserRequest{UserId: tt.userId})
+			requireCode(t, err, tt.code)
+			if tt.code == codes.OK {
+				require.Equal(t, tt.userId, resp.GetUser().GetId())
+				require.Equal(t, "active", resp.GetUser().GetStatus())
+				require.Equal(t, "{}", resp.GetUser().GetMetadata())
+				require.False(t, resp.GetUser().GetCreatedAt().AsTime().IsZero())
+			}
+		})
+	}
+}
+
+func TestGetUserByEmail(t *testing.T) {
+	env := newTestEnv(t)
+	userId, userToken := env.user(t, "user@example.com", false)
+	otherId, _ := env.user(t, "other@example.com", false)
+	_, adminToken := env.user(t, "admin@example.com", true)
+
+	tests := []struct {
+		name   string
+		token  string
+		email  string
+		wantId int64
+		code   codes.Code
+	}{
+		{name: "self", token: userToken, email: "User@Example.com", wantId: userId},
+		{name: "admin finds another user", token: adminToken, email: "other@example.com", wantId: otherId},
+		{name: "user finds another user", token: userToken, email: "other@example.com", code: codes.PermissionDenied},
+		// a user must not learn whether an email is registered
+		{name: "user finds unknown email", token: userToken, email: "unknown@example.com", code: codes.PermissionDenied},
+		{name: "admin finds unknown email", token: adminToken, email: "unknown@example.com", code: codes.NotFound},
+		{name: "admin finds invalid email", token: adminToken, email: "not an email", code: codes.NotFound},
+		{name: "no token", email: "user@example.com", code: codes.Unauthenticated},
+		{name: "no email", token: userToken, code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := env.server.GetUserByEmail(withToken(tt.token), &authv1.GetUserByEmailRequest{Email: tt.email})
+			requireCode(t, err, tt.code)
+			if tt.code == codes.OK {
+				require.Equal(t, tt.wantId, resp.GetUser().GetId())
+			}
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	env := newTestEnv(t)
+	userId, userToken := env.user(t, "user@example.com", false)
+	otherId, _ := env.user(t, "other@example.com", false)
+	_, adminToken := env.user(t, "admin@example.com", true)
+
+	tests := []struct {
+		name   string
+		token  string
+		userId int64
+		paths  []string
+		code   codes.Code
+	}{
+		{name: "self", token: userToken, userId: userId, paths: []string{"display_name"}},
+		{name: "admin updates another user", token: adminToken, userId: otherId, paths: []string{"display_name", "locale"}},
+		{name: "user updates another user", token: userToken, userId: otherId, paths: []string{"display_name"}, code: codes.PermissionDenied},
+		{name: "unknown path", token: userToken, userId: userId, paths: []string{"email"}, code: codes.InvalidArgument},
+		{name: "no token", userId: userId, code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := env.server.UpdateUser(withToken(tt.token), &authv1.UpdateUserRequest{
+				UserId:     tt.userId,
+				User:       &authv1.UserProfile{DisplayName: tt.name, Locale: "en-us"},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: tt.paths},
+			})
+			requireCode(t, err, tt.code)
+			if tt.code == codes.OK {
+				require.Equal(t, tt.name, resp.GetUser().GetDisplayName())
+			}
+		})
+	}
+
+	// only the fields in the mask are changed
+	resp, err := env.server.GetUser(withToken(adminToken), &authv1.GetUserRequest{UserId: otherId})
+	require.NoError(t, err)
+	require.Equal(t, "admin updates another user", resp.GetUser().GetDisplayName())
+	require.Equal(t, "en-US", resp.GetUser().GetLocale())
+
+	resp, err = env.server.GetUser(withToken(userToken), &authv1.GetUserRequest{UserId: userId})
+	require.NoError(t, err)
+	require.Equal(t, "self", resp.GetUser().GetDisplayName())
+	require.Empty(t, resp.GetUser().GetLocale())
+}
+
+func TestExportUserData(t *testing.T) {
+	env := newTestEnv(t)
+	userId, userToken := env.user(t, "user@example.com", false)
+	otherId, _ := env.user(t, "other@example.com", false)
+	adminId, adminToken := env.user(t, "admin@example.com", true)
+
+	tests := []struct {
+		name      string
+		token     string
+		userId    int64
+		wantEmail string
+		code      codes.Code
+	}{
+		{name: "self", token: userToken, userId: userId, wantEmail: "user@example.com"},
+		{name: "admin exports another user", token: adminToken, userId: otherId, wantEmail: "other@example.com"},
+		{name: "user exports another user", token: userToken, userId: otherId, code: codes.PermissionDenied},
+		{name: "admin exports unknown user", token: adminToken, userId: 1000, code: codes.NotFound},
+		{name: "no token", userId: userId, code: codes.Unauthenticated},
+		{name: "no user id", token: userToken, code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := env.server.ExportUserData(withToken(tt.token), &authv1.ExportUserDataRequest{UserId: tt.userId})
+			requireCode(t, err, tt.code)
+			if tt.code != codes.OK {
+				return
+			}
+
+			var export auth.UserDataExport
+			require.NoError(t, json.Unmarshal([]byte(resp.GetArchive()), &export))
+			require.Equal(t, tt.wantEmail, export.User.Email)
+			require.Empty(t, export.User.PassHash)
+			require.Len(t, export.Sessions, 1)
+			require.NotEmpty(t, export.AuditEvents)
+		})
+	}
+
+	// events the user made about other users are exported too
+	_, err := env.server.DisableUser(withToken(adminToken), &authv1.DisableUserRequest{UserId: otherId})
+	require.NoError(t, err)
+	resp, err := env.server.ExportUserData(withToken(adminToken), &authv1.ExportUserDataRequest{UserId: adminId})
+	require.NoError(t, err)
+
+	var export auth.UserDataExport
+	require.NoError(t, json.Unmarshal([]byte(resp.GetArchive()), &export))
+	var disabled []int64
+	for _, event := range export.AuditEvents {
+		if event.Type == models.AuditUserDisabled {
+			require.Equal(t, adminId, event.ActorId)
+			disabled = append(disabled, event.SubjectId)
+		}
+	}
+	require.Equal(t, []int64{otherId}, disabled)
+}
+
+// with constantTimeLogin an unknown email and a wrong password are indistinguishable for the client
+func TestLogin_ConstantTime(t *testing.T) {
+	env := newTestEnvWith(t, true)
+	env.user(t, "user@example.com", false)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{name: "unknown user", email: "nobody@example.com", password: testPassword},
+		{name: "wrong password", email: "user@example.com", password: "Wrong-Horse-7"},
+		{name: "invalid email", email: "not an email", password: testPassword},
+	}
+
+	var first *status.Status
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.server.Login(context.Background(), &authv1.LoginRequest{
+				Email: tt.email, Password: tt.password, AppId: testAppId,
+			})
+			requireCode(t, err, codes.Unauthenticated)
+
+			st := status.Convert(err)
+			if first == nil {
+				first = st
+				return
+			}
+			require.Equal(t, first.Message(), st.Message())
+			require.Equal(t, fmt.Sprint(first.Details()), fmt.Sprint(st.Details()))
+		})
+	}
+}
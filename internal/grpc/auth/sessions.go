@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+
+	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"usekit-auth/internal/domain/models"
+)
+
+func (server *serverApi) ListSessions(ctx context.Context, _ *authv1.ListSessionsRequest) (*authv1.ListSessionsResponse, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := server.auth.ListSessions(ctx, token)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &authv1.ListSessionsResponse{Sessions: make([]*authv1.Session, 0, len(sessions))}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, toProtoSession(session))
+	}
+
+	return resp, nil
+}
+
+func (server *serverApi) RevokeSession(ctx context.Context, req *authv1.RevokeSessionRequest) (*authv1.RevokeSessionResponse, error) {
+	if req.GetSessionId() == "" {
+		return nil, invalidArgument("session_id is required", fieldViolation("session_id", "session_id is required"))
+	}
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := server.auth.RevokeSession(ctx, token, req.GetSessionId()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authv1.RevokeSessionResponse{}, nil
+}
+
+func (server *serverApi) RevokeAllOtherSessions(
+	ctx context.Context,
+	_ *authv1.RevokeAllOtherSessionsRequest,
+) (*authv1.RevokeAllOtherSessionsResponse, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := server.auth.RevokeAllOtherSessions(ctx, token)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authv1.RevokeAllOtherSessionsResponse{Revoked: revoked}, nil
+}
+
+func toProtoSession(session models.Session) *authv1.Session {
+	return &authv1.Session{
+		Id:         session.Id,
+		AppId:      int32(session.AppId),
+		Device:     session.Device,
+		Ip:         session.IP,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  timestamppb.New(session.CreatedAt),
+		LastSeenAt: timestamppb.New(session.LastSeenAt),
+		ExpiresAt:  timestamppb.New(session.ExpiresAt),
+		Current:    session.Current,
+	}
+}
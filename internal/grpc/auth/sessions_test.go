@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"usekit-auth/internal/domain/models"
+)
+
+// login opens another session of the user and returns its token
+func (e *testEnv) login(t *testing.T, email string) string {
+	t.Helper()
+
+	resp, err := e.server.Login(context.Background(), &authv1.LoginRequest{Email: email, Password: testPassword, AppId: testAppId})
+	require.NoError(t, err)
+	return resp.GetToken()
+}
+
+// requireRejected checks that every token-checked method refuses token
+func requireRejected(t *testing.T, env *testEnv, token string, userId int64) {
+	t.Helper()
+	ctx := withToken(token)
+
+	_, err := env.server.IsAdmin(ctx, &authv1.IsAdminRequest{UserId: userId})
+	requireCode(t, err, codes.Unauthenticated)
+	_, err = env.server.GetUser(ctx, &authv1.GetUserRequest{UserId: userId})
+	requireCode(t, err, codes.Unauthenticated)
+	_, err = env.server.ListSessions(ctx, &authv1.ListSessionsRequest{})
+	requireCode(t, err, codes.Unauthenticated)
+	_, err = env.server.RevokeAllOtherSessions(ctx, &authv1.RevokeAllOtherSessionsRequest{})
+	requireCode(t, err, codes.Unauthenticated)
+}
+
+func TestSessions_RevokeRejectsToken(t *testing.T) {
+	env := newTestEnv(t)
+	userId, token := env.user(t, "user@example.com", false)
+	otherToken := env.login(t, "user@example.com")
+
+	_, err := env.server.IsAdmin(withToken(otherToken), &authv1.IsAdminRequest{UserId: userId})
+	require.NoError(t, err)
+
+	listed, err := env.server.ListSessions(withToken(token), &authv1.ListSessionsRequest{})
+	require.NoError(t, err)
+	require.Len(t, listed.GetSessions(), 2)
+
+	var current, other string
+	for _, session := range listed.GetSessions() {
+		if session.GetCurrent() {
+			current = session.GetId()
+		} else {
+			other = session.GetId()
+		}
+		require.Equal(t, int32(testAppId), session.GetAppId())
+		require.True(t, session.GetExpiresAt().AsTime().After(session.GetCreatedAt().AsTime()))
+	}
+	require.NotEmpty(t, current)
+	require.NotEmpty(t, other)
+
+	_, err = env.server.RevokeSession(withToken(token), &authv1.RevokeSessionRequest{SessionId: other})
+	require.NoError(t, err)
+	requireRejected(t, env, otherToken, userId)
+
+	// the token of the remaining session still works
+	listed, err = env.server.ListSessions(withToken(token), &authv1.ListSessionsRequest{})
+	require.NoError(t, err)
+	require.Len(t, listed.GetSessions(), 1)
+	require.Equal(t, current, listed.GetSessions()[0].GetId())
+
+	thirdToken := env.login(t, "user@example.com")
+	fourthToken := env.login(t, "user@example.com")
+	revoked, err := env.server.RevokeAllOtherSessions(withToken(token), &authv1.RevokeAllOtherSessionsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), revoked.GetRevoked())
+	requireRejected(t, env, thirdToken, userId)
+	requireRejected(t, env, fourthToken, userId)
+
+	_, err = env.server.GetUser(withToken(token), &authv1.GetUserRequest{UserId: userId})
+	require.NoError(t, err)
+}
+
+func TestRevokeSession(t *testing.T) {
+	env := newTestEnv(t)
+	_, token := env.user(t, "user@example.com", false)
+	_, otherUserToken := env.user(t, "other@example.com", false)
+
+	otherUserSessions, err := env.server.ListSessions(withToken(otherUserToken), &authv1.ListSessionsRequest{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		token     string
+		sessionId string
+		code      codes.Code
+	}{
+		{name: "unknown session", token: token, sessionId: "unknown", code: codes.NotFound},
+		// sessions of other users are indistinguishable from unknown ones
+		{name: "session of another user", token: token, sessionId: otherUserSessions.GetSessions()[0].GetId(), code: codes.NotFound},
+		{name: "no session id", token: token, code: codes.InvalidArgument},
+		{name: "no token", sessionId: "unknown", code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.server.RevokeSession(withToken(tt.token), &authv1.RevokeSessionRequest{SessionId: tt.sessionId})
+			requireCode(t, err, tt.code)
+		})
+	}
+
+	// the session of the other user was not revoked
+	otherUserSessions, err = env.server.ListSessions(withToken(otherUserToken), &authv1.ListSessionsRequest{})
+	require.NoError(t, err)
+	require.Len(t, otherUserSessions.GetSessions(), 1)
+}
+
+func TestIsAdmin_Token(t *testing.T) {
+	env := newTestEnv(t)
+	userId, token := env.user(t, "user@example.com", false)
+	adminId, _ := env.user(t, "admin@example.com", true)
+	// the status is changed in the storage so that the session stays active
+	disabledId, disabledToken := env.user(t, "disabled@example.com", false)
+	require.NoError(t, env.storage.SetUserStatus(context.Background(), disabledId, models.UserStatusDisabled))
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		userId int64
+		want   bool
+		code   codes.Code
+	}{
+		// callers that only pass user_id keep working
+		{name: "no token", ctx: context.Background(), userId: adminId, want: true},
+		{name: "valid token", ctx: withToken(token), userId: userId},
+		{name: "invalid token", ctx: withToken("not-a-token"), userId: userId, code: codes.Unauthenticated},
+		{name: "token of a disabled user", ctx: withToken(disabledToken), userId: userId, code: codes.PermissionDenied},
+		{name: "not a bearer token", ctx: metadata.NewIncomingContext(context.Background(),
+			metadata.Pairs(authorizationMetadataKey, "Basic dXNlcjpwYXNz")), userId: userId, code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := env.server.IsAdmin(tt.ctx, &authv1.IsAdminRequest{UserId: tt.userId})
+			requireCode(t, err, tt.code)
+			if tt.code == codes.OK {
+				require.Equal(t, tt.want, resp.GetIsAdmin())
+			}
+		})
+	}
+}
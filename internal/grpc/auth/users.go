@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"usekit-auth/internal/domain/models"
+)
+
+// authorizationMetadataKey carries the token issued by Login as "Bearer <token>",
+// the gateway passes the Authorization HTTP header under the same key
+const authorizationMetadataKey = "authorization"
+
+func (server *serverApi) GetUser(ctx context.Context, req *authv1.GetUserRequest) (*authv1.GetUserResponse, error) {
+	if err := validateUserId(req.GetUserId()); err != nil {
+		return nil, err
+	}
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := server.auth.GetUser(ctx, token, req.GetUserId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authv1.GetUserResponse{User: toProtoUser(user)}, nil
+}
+
+func (server *serverApi) GetUserByEmail(
+	ctx context.Context,
+	req *authv1.GetUserByEmailRequest,
+) (*authv1.GetUserByEmailResponse, error) {
+	if req.GetEmail() == "" {
+		return nil, invalidArgument("email is required", fieldViolation("email", "email is required"))
+	}
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := server.auth.GetUserByEmail(ctx, token, req.GetEmail())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authv1.GetUserByEmailResponse{User: toProtoUser(user)}, nil
+}
+
+func (server *serverApi) UpdateUser(ctx context.Context, req *authv1.UpdateUserRequest) (*authv1.UpdateUserResponse, error) {
+	if err := validateUserId(req.GetUserId()); err != nil {
+		return nil, err
+	}
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := req.GetUser()
+	user, err := server.auth.UpdateUser(ctx, token, req.GetUserId(), models.UserProfile{
+		DisplayName: profile.GetDisplayName(),
+		Locale:      profile.GetLocale(),
+		Timezone:    profile.GetTimezone(),
+		AvatarURL:   profile.GetAvatarUrl(),
+		Metadata:    []byte(profile.GetMetadata()),
+	}, req.GetUpdateMask().GetPaths())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authv1.UpdateUserResponse{User: toProtoUser(user)}, nil
+}
+
+func (server *serverApi) ExportUserData(
+	ctx context.Context,
+	req *authv1.ExportUserDataRequest,
+) (*authv1.ExportUserDataResponse, error) {
+	if err := validateUserId(req.GetUserId()); err != nil {
+		return nil, err
+	}
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := server.auth.ExportUserData(ctx, token, req.GetUserId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authv1.ExportUserDataResponse{Archive: string(archive)}, nil
+}
+
+// bearerToken returns the token from the authorization metadata,
+// an Unauthenticated status error if there is none
+func bearerToken(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(authorizationMetadataKey) {
+		scheme, token, ok := strings.Cut(value, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") && strings.TrimSpace(token) != "" {
+			return strings.TrimSpace(token), nil
+		}
+	}
+	return "", withDetails(status.New(codes.Unauthenticated, `authorization metadata "Bearer <token>" is required`), "MISSING_TOKEN")
+}
+
+// optionalBearerToken returns an empty token if there is no authorization metadata
+// and the same errors as bearerToken if there is one without a bearer token
+func optionalBearerToken(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if len(md.Get(authorizationMetadataKey)) == 0 {
+		return "", nil
+	}
+	return bearerToken(ctx)
+}
+
+func validateUserId(userId int64) error {
+	if userId == 0 {
+		return invalidArgument("user_id is required", fieldViolation("user_id", "user_id is required"))
+	}
+	return nil
+}
+
+func toProtoUser(user models.User) *authv1.User {
+	metadata := string(user.Metadata)
+	if metadata == "" {
+		metadata = "{}"
+	}
+	return &authv1.User{
+		Id:          user.Id,
+		Email:       user.Email,
+		IsAdmin:     user.IsAdmin,
+		Status:      string(user.Status),
+		DisplayName: user.DisplayName,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		AvatarUrl:   user.AvatarURL,
+		Metadata:    metadata,
+		CreatedAt:   timestamppb.New(user.CreatedAt),
+		UpdatedAt:   timestamppb.New(user.UpdatedAt),
+	}
+}
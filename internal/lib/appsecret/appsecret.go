@@ -0,0 +1,18 @@
+package appsecret
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// size is the number of random bytes, tokens are signed with HMAC-SHA256 whose key should be at least 32 bytes
+const size = 32
+
+// New returns a random secret for signing tokens of an app
+func New() (string, error) {
+	secret := make([]byte, size)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
@@ -0,0 +1,38 @@
+package clientinfo
+
+import "context"
+
+// Info describes the client that sent the current request
+type Info struct {
+	IP        string
+	UserAgent string
+	// Device is a client-provided name of the device, e.g. "Pixel 8"
+	Device string
+	// Certificate is the verified client certificate of a mutual TLS connection, nil without one
+	Certificate *Certificate
+}
+
+// Certificate identifies the client by its TLS certificate
+type Certificate struct {
+	Subject      string
+	CommonName   string
+	SerialNumber string
+	// DNSNames, URIs and Emails are subject alternative names, e.g. a SPIFFE id in URIs
+	DNSNames []string
+	URIs     []string
+	Emails   []string
+	// Issuer is the subject of the CA that signed the certificate
+	Issuer string
+}
+
+type ctxKey struct{}
+
+func With(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+// From returns client info stored in ctx, zero Info if there is none
+func From(ctx context.Context) Info {
+	info, _ := ctx.Value(ctxKey{}).(Info)
+	return info
+}
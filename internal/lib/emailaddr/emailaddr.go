@@ -0,0 +1,50 @@
+package emailaddr
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	maxLength      = 254
+	maxLocalLength = 64
+)
+
+var ErrInvalid = errors.New("invalid email address")
+
+// Normalize validates a bare address ("local@domain", RFC 5322 addr-spec without display name
+// or quoted local part) and returns its canonical form used to store and look up users:
+// the local part is NFC-normalized and lower-cased, the domain is converted to
+// lower-case ASCII (punycode for internationalized domains).
+func Normalize(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if address == "" || len(address) > maxLength {
+		return "", ErrInvalid
+	}
+
+	parsed, err := mail.ParseAddress(address)
+	if err != nil || parsed.Name != "" || parsed.Address != address {
+		return "", ErrInvalid
+	}
+
+	at := strings.LastIndexByte(address, '@')
+	local, domain := address[:at], address[at+1:]
+	if local == "" || len(local) > maxLocalLength {
+		return "", ErrInvalid
+	}
+
+	domain, err = idna.Lookup.ToASCII(domain)
+	if err != nil || domain == "" {
+		return "", ErrInvalid
+	}
+
+	canonical := strings.ToLower(norm.NFC.String(local)) + "@" + strings.ToLower(domain)
+	if len(canonical) > maxLength {
+		return "", ErrInvalid
+	}
+	return canonical, nil
+}
@@ -1,12 +1,26 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt"
 	"time"
 	"usekit-auth/internal/domain/models"
 )
 
-func NewToken(user models.User, app models.App, duration time.Duration) (string, error) {
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the verified claims of a token issued by NewToken
+type Claims struct {
+	UserId    int64
+	Email     string
+	AppId     int
+	SessionId string
+	ExpiresAt time.Time
+}
+
+// NewToken issues a token for user in app bound to the session with sessionId
+func NewToken(user models.User, app models.App, sessionId string, duration time.Duration) (string, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 
 	claims := token.Claims.(jwt.MapClaims)
@@ -14,6 +28,7 @@ func NewToken(user models.User, app models.App, duration time.Duration) (string,
 	claims["email"] = user.Email
 	claims["exp"] = time.Now().Add(duration).Unix()
 	claims["app_id"] = app.Id
+	claims["sid"] = sessionId
 
 	tokenString, err := token.SignedString([]byte(app.Secret))
 	if err != nil {
@@ -22,3 +37,50 @@ func NewToken(user models.User, app models.App, duration time.Duration) (string,
 
 	return tokenString, nil
 }
+
+// ParseToken verifies the token signature and expiration.
+// The signing secret is looked up by the app_id claim of the token.
+func ParseToken(tokenString string, appSecret func(appId int) (string, error)) (Claims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+
+		mapClaims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, errors.New("unexpected claims type")
+		}
+		appId, ok := mapClaims["app_id"].(float64)
+		if !ok {
+			return nil, errors.New("app_id claim is missing")
+		}
+
+		secret, err := appSecret(int(appId))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	mapClaims := token.Claims.(jwt.MapClaims)
+	id, idOk := mapClaims["id"].(float64)
+	appId, appIdOk := mapClaims["app_id"].(float64)
+	exp, expOk := mapClaims["exp"].(float64)
+	if !idOk || !appIdOk || !expOk {
+		return Claims{}, fmt.Errorf("%w: required claims are missing", ErrInvalidToken)
+	}
+
+	email, _ := mapClaims["email"].(string)
+	sessionId, _ := mapClaims["sid"].(string)
+
+	return Claims{
+		UserId:    int64(id),
+		Email:     email,
+		AppId:     int(appId),
+		SessionId: sessionId,
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
@@ -0,0 +1,155 @@
+package password
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+// maxArgon2Params bound the cost of verifying a hash, so that a hash imported from another system
+// can not make every login attempt allocate gigabytes. Configured parameters above them raise the bounds
+var maxArgon2Params = Argon2Params{Memory: 256 * 1024, Iterations: 16, Parallelism: 16, SaltLength: 64, KeyLength: 64}
+
+// Argon2Params are the cost parameters of Argon2id
+type Argon2Params struct {
+	// Memory in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Argon2id hashes passwords with Argon2id, hashes are PHC strings
+// "$argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>"
+type Argon2id struct {
+	params Argon2Params
+	// limits are the highest parameters of hashes that are accepted
+	limits Argon2Params
+}
+
+func NewArgon2id(params Argon2Params) (*Argon2id, error) {
+	if params.SaltLength == 0 {
+		params.SaltLength = 16
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = 32
+	}
+	if params.Memory < 8*uint32(params.Parallelism) || params.Iterations < 1 || params.Parallelism < 1 {
+		return nil, errors.New("argon2id requires iterations >= 1, parallelism >= 1 and memory >= 8*parallelism KiB")
+	}
+
+	limits := Argon2Params{
+		Memory:      max(params.Memory, maxArgon2Params.Memory),
+		Iterations:  max(params.Iterations, maxArgon2Params.Iterations),
+		Parallelism: max(params.Parallelism, maxArgon2Params.Parallelism),
+		SaltLength:  max(params.SaltLength, maxArgon2Params.SaltLength),
+		KeyLength:   max(params.KeyLength, maxArgon2Params.KeyLength),
+	}
+	return &Argon2id{params: params, limits: limits}, nil
+}
+
+func (a *Argon2id) Hash(password string) ([]byte, error) {
+	salt := make([]byte, a.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.params.Iterations, a.params.Memory, a.params.Parallelism, a.params.KeyLength)
+
+	return []byte(fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version,
+		a.params.Memory, a.params.Iterations, a.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+func (a *Argon2id) Verify(hash []byte, password string) error {
+	params, salt, key, err := decodeArgon2id(hash, a.limits)
+	if err != nil {
+		return err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (a *Argon2id) Identifies(hash []byte) bool {
+	return bytes.HasPrefix(hash, []byte(argon2idPrefix))
+}
+
+func (a *Argon2id) Validate(hash []byte) error {
+	_, _, _, err := decodeArgon2id(hash, a.limits)
+	return err
+}
+
+func (a *Argon2id) NeedsRehash(hash []byte) bool {
+	params, _, _, err := decodeArgon2id(hash, a.limits)
+	if err != nil {
+		return true
+	}
+	return params.Memory < a.params.Memory ||
+		params.Iterations < a.params.Iterations ||
+		params.Parallelism < a.params.Parallelism ||
+		params.SaltLength < a.params.SaltLength ||
+		params.KeyLength < a.params.KeyLength
+}
+
+// decodeArgon2id parses a PHC string, hashes with parameters above limits are rejected
+func decodeArgon2id(hash []byte, limits Argon2Params) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: unsupported argon2 version", ErrUnknownHash)
+	}
+
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: %w", ErrUnknownHash, err)
+	}
+	// Sscanf ignores anything after the last number
+	if parts[3] != fmt.Sprintf("m=%d,t=%d,p=%d", params.Memory, params.Iterations, params.Parallelism) {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: malformed argon2 parameters", ErrUnknownHash)
+	}
+	// argon2.IDKey panics on these
+	if params.Memory < 8*uint32(params.Parallelism) || params.Iterations < 1 || params.Parallelism < 1 {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: invalid argon2 parameters", ErrUnknownHash)
+	}
+	if params.Memory > limits.Memory || params.Iterations > limits.Iterations || params.Parallelism > limits.Parallelism {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: argon2 parameters above m=%d,t=%d,p=%d",
+			ErrUnknownHash, limits.Memory, limits.Iterations, limits.Parallelism)
+	}
+
+	salt, err := base64.RawStdEncoding.Strict().DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: malformed argon2 salt", ErrUnknownHash)
+	}
+	key, err := base64.RawStdEncoding.Strict().DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: malformed argon2 key", ErrUnknownHash)
+	}
+	params.SaltLength, params.KeyLength = uint32(len(salt)), uint32(len(key))
+	if params.SaltLength > limits.SaltLength || params.KeyLength > limits.KeyLength {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: argon2 salt or key is too long", ErrUnknownHash)
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,246 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// PrefixLength is the number of hex characters of a SHA-1 hash used to look up a range,
+// the same as in the Have I Been Pwned range API
+const PrefixLength = 5
+
+// hashLength is the number of hex characters of a SHA-1 hash
+const hashLength = 2 * sha1.Size
+
+// maxLineLength bounds a line with the hash and ":count", longer lines are rejected on load
+const maxLineLength = 256
+
+var ErrBreachedListUnsorted = errors.New("breached password hashes are not sorted")
+
+// BreachedList looks up SHA-1 hashes of breached passwords in a file sorted by hash.
+//
+// The file is not read into memory: a range is found by binary search over the file,
+// so the full Have I Been Pwned dump of tens of gigabytes costs a few reads per lookup.
+//
+// Lookups follow the k-anonymity model: a password is checked by requesting all
+// suffixes of its hash prefix and comparing them locally, so the list could be
+// replaced by a remote range API without changing callers.
+type BreachedList struct {
+	file  *os.File
+	size  int64
+	count int
+}
+
+// LoadBreachedList opens a file in the format of the Have I Been Pwned SHA-1 dump "ordered by hash",
+// one upper or lower case hex hash per line optionally followed by ":count".
+// The file is read once to check the format and the order, ErrBreachedListUnsorted is returned
+// if the hashes are not in ascending order.
+func LoadBreachedList(path string) (*BreachedList, error) {
+	const op = "password.LoadBreachedList"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	list := &BreachedList{file: f}
+	if err := list.check(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return list, nil
+}
+
+// check validates every line and counts hashes. Empty lines are allowed only at the end of the file,
+// anywhere else they would break the binary search.
+func (l *BreachedList) check() error {
+	info, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	l.size = info.Size()
+
+	reader := bufio.NewReader(io.NewSectionReader(l.file, 0, l.size))
+	var prev string
+	var blank int
+	for line := 1; ; line++ {
+		text, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if text == "" && errors.Is(err, io.EOF) {
+			return nil
+		}
+		if len(text) > maxLineLength {
+			return fmt.Errorf("line %d: longer than %d bytes", line, maxLineLength)
+		}
+
+		text = strings.TrimRight(text, "\r\n")
+		if text == "" {
+			blank = line
+		} else {
+			if blank != 0 {
+				return fmt.Errorf("line %d: empty line in the middle of the file", blank)
+			}
+			hash, err := parseBreachedLine(text)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if hash < prev {
+				return fmt.Errorf("line %d: %w, sort the file by hash", line, ErrBreachedListUnsorted)
+			}
+			prev = hash
+			l.count++
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+// parseBreachedLine returns the upper case hash of a "<hash>[:count]" line
+func parseBreachedLine(text string) (string, error) {
+	hash, _, _ := strings.Cut(text, ":")
+	if len(hash) != hashLength {
+		return "", errors.New("invalid SHA-1 hash")
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hash), nil
+}
+
+// Close closes the file
+func (l *BreachedList) Close() error {
+	return l.file.Close()
+}
+
+// Len returns the number of hashes in the list
+func (l *BreachedList) Len() int {
+	return l.count
+}
+
+// Range returns upper case hex suffixes of all hashes starting with the hex prefix
+func (l *BreachedList) Range(prefix string) ([]string, error) {
+	suffixes, err := l.lookup(strings.ToUpper(prefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read breached hashes: %w", err)
+	}
+	return suffixes, nil
+}
+
+// Contains reports whether password is in the list. An error means the file could not be read
+// and nothing is known about the password.
+func (l *BreachedList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := l.Range(hash[:PrefixLength])
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(suffixes, hash[PrefixLength:]), nil
+}
+
+func (l *BreachedList) lookup(prefix string) ([]string, error) {
+	if len(prefix) > hashLength {
+		return nil, nil
+	}
+
+	// the smallest offset whose next line has a hash not less than prefix, the order of
+	// lines makes the predicate monotonic in the offset
+	var searchErr error
+	offset := sort.Search(int(l.size)+1, func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		start, err := l.lineStart(int64(i))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		hash, err := l.hashAt(start)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return hash == "" || hash >= prefix
+	})
+	if searchErr != nil {
+		return nil, searchErr
+	}
+
+	start, err := l.lineStart(int64(offset))
+	if err != nil {
+		return nil, err
+	}
+
+	var suffixes []string
+	reader := bufio.NewReader(io.NewSectionReader(l.file, start, l.size-start))
+	for {
+		text, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		hash, _, _ := strings.Cut(strings.TrimRight(text, "\r\n"), ":")
+		hash = strings.ToUpper(hash)
+		if !strings.HasPrefix(hash, prefix) {
+			return suffixes, nil
+		}
+		suffixes = append(suffixes, hash[PrefixLength:])
+		if errors.Is(err, io.EOF) {
+			return suffixes, nil
+		}
+	}
+}
+
+// lineStart returns the offset of the first line starting at or after offset, the file size if there is none
+func (l *BreachedList) lineStart(offset int64) (int64, error) {
+	if offset == 0 {
+		return 0, nil
+	}
+
+	buf := make([]byte, maxLineLength+1)
+	for pos := offset - 1; pos < l.size; pos += int64(len(buf)) {
+		n, err := l.file.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return 0, err
+		}
+	}
+	return l.size, nil
+}
+
+// hashAt returns the upper case hash of the line at offset, empty at the end of the file or on an empty line
+func (l *BreachedList) hashAt(offset int64) (string, error) {
+	if offset >= l.size {
+		return "", nil
+	}
+
+	buf := make([]byte, hashLength)
+	n, err := l.file.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	// only empty trailing lines can be shorter than a hash, see check
+	if n < hashLength || bytes.ContainsAny(buf, "\r\n") {
+		return "", nil
+	}
+	return strings.ToUpper(string(buf)), nil
+}
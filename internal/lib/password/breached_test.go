@@ -0,0 +1,210 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func writeBreachedFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func loadBreached(t *testing.T, content string) *BreachedList {
+	t.Helper()
+	list, err := LoadBreachedList(writeBreachedFile(t, content))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = list.Close() })
+	return list
+}
+
+func contains(t *testing.T, list *BreachedList, password string) bool {
+	t.Helper()
+	ok, err := list.Contains(password)
+	require.NoError(t, err)
+	return ok
+}
+
+func rangeOf(t *testing.T, list *BreachedList, prefix string) []string {
+	t.Helper()
+	suffixes, err := list.Range(prefix)
+	require.NoError(t, err)
+	return suffixes
+}
+
+func TestBreachedList_Contains(t *testing.T) {
+	hashes := []string{sha1Hex("password"), sha1Hex("123456"), sha1Hex("qwerty")}
+	slices.Sort(hashes)
+
+	// the Have I Been Pwned dump uses CRLF line endings and ":count" suffixes
+	var content strings.Builder
+	for i, hash := range hashes {
+		fmt.Fprintf(&content, "%s:%d\r\n", hash, i+1)
+	}
+	list := loadBreached(t, content.String())
+
+	require.Equal(t, 3, list.Len())
+	require.True(t, contains(t, list, "password"))
+	require.True(t, contains(t, list, "123456"))
+	require.True(t, contains(t, list, "qwerty"))
+	require.False(t, contains(t, list, "Password"))
+	require.False(t, contains(t, list, "correct horse battery staple"))
+}
+
+func TestBreachedList_Formats(t *testing.T) {
+	hash := sha1Hex("password")
+
+	tests := []struct {
+		name    string
+		content string
+		count   int
+	}{
+		{name: "empty file", content: "", count: 0},
+		{name: "only newlines", content: "\n\n", count: 0},
+		{name: "no trailing newline", content: hash, count: 1},
+		{name: "lower case", content: strings.ToLower(hash) + "\n", count: 1},
+		{name: "trailing empty lines", content: hash + "\n" + strings.Repeat("\r\n", 50), count: 1},
+		{name: "duplicates", content: hash + "\n" + hash + ":2\n", count: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := loadBreached(t, tt.content)
+			require.Equal(t, tt.count, list.Len())
+			require.Equal(t, tt.count > 0, contains(t, list, "password"))
+			require.False(t, contains(t, list, "not breached"))
+		})
+	}
+}
+
+func TestLoadBreachedList_Invalid(t *testing.T) {
+	a, b := sha1Hex("123456"), sha1Hex("password")
+	if a > b {
+		a, b = b, a
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr error
+		line    string
+	}{
+		{name: "unsorted", content: b + "\n" + a + "\n", wantErr: ErrBreachedListUnsorted, line: "line 2"},
+		{name: "unsorted ignoring case", content: strings.ToLower(a) + "\n" + strings.ToLower(b) + "\n" + a + "\n", wantErr: ErrBreachedListUnsorted, line: "line 3"},
+		{name: "short hash", content: a + "\n" + b[:39] + "\n", line: "line 2"},
+		{name: "not hex", content: "Z" + a[1:] + "\n", line: "line 1"},
+		{name: "leading space", content: " " + a + "\n", line: "line 1"},
+		{name: "empty line in the middle", content: a + "\n\n" + b + "\n", line: "line 2"},
+		{name: "line too long", content: a + ":" + strings.Repeat("1", maxLineLength) + "\n", line: "line 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadBreachedList(writeBreachedFile(t, tt.content))
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			}
+			require.Contains(t, err.Error(), tt.line+":")
+		})
+	}
+
+	_, err := LoadBreachedList(filepath.Join(t.TempDir(), "missing.txt"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Range over the file has to return the same suffixes as a scan of all hashes
+func TestBreachedList_RangeMatchesScan(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	// few distinct prefixes so that ranges have several entries and many prefixes are absent
+	var hashes []string
+	for range 3000 {
+		raw := make([]byte, sha1.Size)
+		for i := range raw {
+			raw[i] = byte(rng.UintN(256))
+		}
+		raw[0], raw[1] = byte(rng.UintN(4)), byte(rng.UintN(4))
+		hashes = append(hashes, strings.ToUpper(hex.EncodeToString(raw)))
+	}
+	slices.Sort(hashes)
+
+	var content strings.Builder
+	for _, hash := range hashes {
+		// counts of different length make lines of different length
+		fmt.Fprintf(&content, "%s:%d\n", hash, rng.UintN(1_000_000))
+	}
+	list := loadBreached(t, content.String())
+	require.Equal(t, len(hashes), list.Len())
+
+	scan := func(prefix string) []string {
+		var suffixes []string
+		for _, hash := range hashes {
+			if strings.HasPrefix(hash, prefix) {
+				suffixes = append(suffixes, hash[PrefixLength:])
+			}
+		}
+		return suffixes
+	}
+
+	prefixes := []string{"00000", "FFFFF", hashes[0][:PrefixLength], hashes[len(hashes)-1][:PrefixLength]}
+	for range 300 {
+		prefixes = append(prefixes, hashes[rng.IntN(len(hashes))][:PrefixLength])
+		prefixes = append(prefixes, fmt.Sprintf("%05X", rng.UintN(0x40000)))
+	}
+
+	for _, prefix := range prefixes {
+		require.Equal(t, scan(prefix), rangeOf(t, list, prefix), "prefix %s", prefix)
+		require.Equal(t, scan(prefix), rangeOf(t, list, strings.ToLower(prefix)), "prefix %s", prefix)
+	}
+
+	for _, i := range []int{0, 1, len(hashes) / 2, len(hashes) - 1} {
+		hash := hashes[i]
+		require.Contains(t, rangeOf(t, list, hash[:PrefixLength]), hash[PrefixLength:])
+	}
+}
+
+func TestPolicy_Breached(t *testing.T) {
+	list := loadBreached(t, sha1Hex("Password1")+"\n")
+	policy := Policy{MinLength: 8, MinClasses: 2, Breached: list}
+
+	err := policy.Validate("Password1", "user@example.com")
+	var policyErr *PolicyError
+	require.ErrorAs(t, err, &policyErr)
+	require.Contains(t, policyErr.Reason, "data breach")
+
+	require.NoError(t, policy.Validate("Password2", "user@example.com"))
+}
+
+// a list that can not be read must not let passwords through
+func TestBreachedList_ReadError(t *testing.T) {
+	list, err := LoadBreachedList(writeBreachedFile(t, sha1Hex("Password1")+"\n"))
+	require.NoError(t, err)
+	require.NoError(t, list.Close())
+
+	_, err = list.Contains("Password2")
+	require.ErrorIs(t, err, os.ErrClosed)
+
+	policy := Policy{MinLength: 8, Breached: list}
+	err = policy.Validate("Password2", "user@example.com")
+	require.ErrorIs(t, err, os.ErrClosed)
+	var policyErr *PolicyError
+	require.False(t, errors.As(err, &policyErr))
+}
@@ -0,0 +1,70 @@
+package password
+
+import "errors"
+
+var (
+	ErrMismatch    = errors.New("password does not match the hash")
+	ErrUnknownHash = errors.New("unknown password hash format")
+)
+
+// Scheme is a single password hashing algorithm with fixed parameters.
+// Hashes are self-describing strings, so a scheme recognizes its own hashes
+// and can tell whether they were produced with weaker parameters.
+type Scheme interface {
+	Hash(password string) ([]byte, error)
+	// Verify returns ErrMismatch if password does not match hash
+	Verify(hash []byte, password string) error
+	// Identifies reports whether hash was produced by this algorithm
+	Identifies(hash []byte) bool
+	// Validate returns an error if hash of this algorithm is malformed
+	Validate(hash []byte) error
+	// NeedsRehash reports whether hash was produced with weaker parameters than the scheme has
+	NeedsRehash(hash []byte) bool
+}
+
+// Hasher hashes new passwords with the preferred scheme and verifies hashes of any known scheme
+type Hasher struct {
+	preferred Scheme
+	schemes   []Scheme
+}
+
+// NewHasher returns a Hasher that produces hashes with preferred and also accepts hashes of legacy schemes
+func NewHasher(preferred Scheme, legacy ...Scheme) *Hasher {
+	return &Hasher{
+		preferred: preferred,
+		schemes:   append([]Scheme{preferred}, legacy...),
+	}
+}
+
+func (h *Hasher) Hash(password string) ([]byte, error) {
+	return h.preferred.Hash(password)
+}
+
+// Verify returns ErrMismatch if password does not match hash and ErrUnknownHash if no scheme recognizes hash
+func (h *Hasher) Verify(hash []byte, password string) error {
+	for _, scheme := range h.schemes {
+		if scheme.Identifies(hash) {
+			return scheme.Verify(hash, password)
+		}
+	}
+	return ErrUnknownHash
+}
+
+// Validate returns ErrUnknownHash if no scheme recognizes hash and an error if hash is malformed,
+// it checks hashes produced elsewhere, e.g. imported from another system
+func (h *Hasher) Validate(hash []byte) error {
+	for _, scheme := range h.schemes {
+		if scheme.Identifies(hash) {
+			return scheme.Validate(hash)
+		}
+	}
+	return ErrUnknownHash
+}
+
+// NeedsRehash reports whether hash should be replaced by a hash of the preferred scheme
+func (h *Hasher) NeedsRehash(hash []byte) bool {
+	if !h.preferred.Identifies(hash) {
+		return true
+	}
+	return h.preferred.NeedsRehash(hash)
+}
@@ -0,0 +1,287 @@
+package password
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "correct horse battery staple"
+
+// cheap parameters keep the tests fast
+var testArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func newTestArgon2id(t *testing.T, params Argon2Params) *Argon2id {
+	t.Helper()
+	a, err := NewArgon2id(params)
+	require.NoError(t, err)
+	return a
+}
+
+func newTestBcrypt(t *testing.T, cost int) *Bcrypt {
+	t.Helper()
+	b, err := NewBcrypt(cost)
+	require.NoError(t, err)
+	return b
+}
+
+func TestDecodeArgon2id_Malformed(t *testing.T) {
+	const (
+		salt = "c2FsdHNhbHRzYWx0c2FsdA"
+		key  = "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	)
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "empty", hash: ""},
+		{name: "not phc", hash: "plaintext"},
+		{name: "missing key", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt},
+		{name: "extra part", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + key + "$x"},
+		{name: "argon2i", hash: "$argon2i$v=19$m=64,t=1,p=1$" + salt + "$" + key},
+		{name: "old version", hash: "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key},
+		{name: "no version", hash: "$argon2id$m=64,t=1,p=1$" + salt + "$" + key + "$"},
+		{name: "params not numbers", hash: "$argon2id$v=19$m=a,t=1,p=1$" + salt + "$" + key},
+		{name: "params reordered", hash: "$argon2id$v=19$t=1,m=64,p=1$" + salt + "$" + key},
+		{name: "params trailing garbage", hash: "$argon2id$v=19$m=64,t=1,p=1,k=2$" + salt + "$" + key},
+		{name: "zero parallelism", hash: "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key},
+		{name: "zero iterations", hash: "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key},
+		{name: "memory below 8*parallelism", hash: "$argon2id$v=19$m=8,t=1,p=2$" + salt + "$" + key},
+		{name: "salt not base64", hash: "$argon2id$v=19$m=64,t=1,p=1$!!!$" + key},
+		{name: "salt padded", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "==$" + key},
+		{name: "empty salt", hash: "$argon2id$v=19$m=64,t=1,p=1$$" + key},
+		{name: "key not base64", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$!!!"},
+		{name: "empty key", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$"},
+	}
+
+	a := newTestArgon2id(t, testArgon2Params)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := decodeArgon2id([]byte(tt.hash), a.limits)
+			require.ErrorIs(t, err, ErrUnknownHash)
+
+			require.ErrorIs(t, a.Validate([]byte(tt.hash)), ErrUnknownHash)
+			require.ErrorIs(t, a.Verify([]byte(tt.hash), testPassword), ErrUnknownHash)
+			require.True(t, a.NeedsRehash([]byte(tt.hash)))
+		})
+	}
+}
+
+func TestArgon2id_HashVerify(t *testing.T) {
+	a := newTestArgon2id(t, testArgon2Params)
+
+	hash, err := a.Hash(testPassword)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(hash), "$argon2id$v=19$m=64,t=1,p=1$"))
+
+	params, salt, key, err := decodeArgon2id(hash, a.limits)
+	require.NoError(t, err)
+	require.Len(t, salt, 16)
+	require.Len(t, key, 32)
+	require.Equal(t, Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}, params)
+
+	require.NoError(t, a.Verify(hash, testPassword))
+	require.ErrorIs(t, a.Verify(hash, testPassword+"!"), ErrMismatch)
+
+	other, err := a.Hash(testPassword)
+	require.NoError(t, err)
+	require.NotEqual(t, hash, other, "salt must be random")
+}
+
+func TestArgon2id_NeedsRehash(t *testing.T) {
+	hashWith := func(t *testing.T, params Argon2Params) []byte {
+		hash, err := newTestArgon2id(t, params).Hash(testPassword)
+		require.NoError(t, err)
+		return hash
+	}
+
+	current := Argon2Params{Memory: 128, Iterations: 2, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+
+	tests := []struct {
+		name   string
+		params Argon2Params
+		want   bool
+	}{
+		{name: "same parameters", params: current, want: false},
+		{name: "stronger parameters", params: Argon2Params{Memory: 256, Iterations: 3, Parallelism: 4, SaltLength: 32, KeyLength: 64}, want: false},
+		{name: "less memory", params: Argon2Params{Memory: 64, Iterations: 2, Parallelism: 2}, want: true},
+		{name: "fewer iterations", params: Argon2Params{Memory: 128, Iterations: 1, Parallelism: 2}, want: true},
+		{name: "lower parallelism", params: Argon2Params{Memory: 128, Iterations: 2, Parallelism: 1}, want: true},
+		{name: "shorter salt", params: Argon2Params{Memory: 128, Iterations: 2, Parallelism: 2, SaltLength: 8}, want: true},
+		{name: "shorter key", params: Argon2Params{Memory: 128, Iterations: 2, Parallelism: 2, KeyLength: 16}, want: true},
+	}
+
+	a := newTestArgon2id(t, current)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, a.NeedsRehash(hashWith(t, tt.params)))
+		})
+	}
+}
+
+func TestBcrypt_NeedsRehash(t *testing.T) {
+	b := newTestBcrypt(t, bcrypt.MinCost+1)
+
+	weaker, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	require.NoError(t, err)
+	same, err := b.Hash(testPassword)
+	require.NoError(t, err)
+	stronger, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost+2)
+	require.NoError(t, err)
+
+	require.True(t, b.NeedsRehash(weaker))
+	require.False(t, b.NeedsRehash(same))
+	require.False(t, b.NeedsRehash(stronger))
+	require.True(t, b.NeedsRehash([]byte("$2a$xx$")))
+}
+
+func TestHasher_CrossAlgorithm(t *testing.T) {
+	argon := newTestArgon2id(t, testArgon2Params)
+	bcr := newTestBcrypt(t, bcrypt.MinCost)
+
+	argonHash, err := argon.Hash(testPassword)
+	require.NoError(t, err)
+	bcryptHash, err := bcr.Hash(testPassword)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		hasher      *Hasher
+		hash        []byte
+		password    string
+		wantErr     error
+		needsRehash bool
+	}{
+		{name: "argon2id hash with argon2id", hasher: NewHasher(argon, bcr), hash: argonHash, password: testPassword},
+		{name: "bcrypt hash with legacy bcrypt", hasher: NewHasher(argon, bcr), hash: bcryptHash, password: testPassword, needsRehash: true},
+		{name: "argon2id hash with legacy argon2id", hasher: NewHasher(bcr, argon), hash: argonHash, password: testPassword, needsRehash: true},
+		{name: "wrong password with legacy scheme", hasher: NewHasher(argon, bcr), hash: bcryptHash, password: "wrong", wantErr: ErrMismatch, needsRehash: true},
+		{name: "bcrypt hash without bcrypt", hasher: NewHasher(argon), hash: bcryptHash, password: testPassword, wantErr: ErrUnknownHash, needsRehash: true},
+		{name: "argon2id hash without argon2id", hasher: NewHasher(bcr), hash: argonHash, password: testPassword, wantErr: ErrUnknownHash, needsRehash: true},
+		{name: "unknown scheme", hasher: NewHasher(argon, bcr), hash: []byte("$1$salt$md5crypt"), password: testPassword, wantErr: ErrUnknownHash, needsRehash: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hasher.Verify(tt.hash, tt.password)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.needsRehash, tt.hasher.NeedsRehash(tt.hash))
+		})
+	}
+}
+
+func TestHasher_Validate(t *testing.T) {
+	argon := newTestArgon2id(t, testArgon2Params)
+	bcr := newTestBcrypt(t, bcrypt.MinCost)
+	hasher := NewHasher(argon, bcr)
+
+	argonHash, err := argon.Hash(testPassword)
+	require.NoError(t, err)
+	bcryptHash, err := bcr.Hash(testPassword)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr error
+		anyErr  bool
+	}{
+		{name: "argon2id", hash: string(argonHash)},
+		{name: "bcrypt", hash: string(bcryptHash)},
+		{name: "bcrypt 2b", hash: "$2b$" + string(bcryptHash[4:])},
+		{name: "argon2id truncated", hash: string(argonHash[:strings.LastIndex(string(argonHash), "$")]), wantErr: ErrUnknownHash},
+		{name: "argon2id bad params", hash: strings.Replace(string(argonHash), "t=1", "t=x", 1), wantErr: ErrUnknownHash},
+		{name: "bcrypt truncated", hash: string(bcryptHash[:20]), anyErr: true},
+		{name: "bcrypt bad cost", hash: "$2a$99$" + string(bcryptHash[7:]), anyErr: true},
+		{name: "bcrypt cost not a number", hash: "$2a$xx$" + string(bcryptHash[7:]), anyErr: true},
+		{name: "bcrypt cost at the limit", hash: "$2a$14$" + string(bcryptHash[7:])},
+		{name: "bcrypt cost above the limit", hash: "$2a$15$" + string(bcryptHash[7:]), anyErr: true},
+		{name: "bcrypt max cost", hash: "$2a$31$" + string(bcryptHash[7:]), anyErr: true},
+		{name: "md5crypt", hash: "$1$salt$hash", wantErr: ErrUnknownHash},
+		{name: "plaintext", hash: testPassword, wantErr: ErrUnknownHash},
+		{name: "empty", hash: "", wantErr: ErrUnknownHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := hasher.Validate([]byte(tt.hash))
+			switch {
+			case tt.wantErr != nil:
+				require.ErrorIs(t, err, tt.wantErr)
+			case tt.anyErr:
+				require.Error(t, err)
+			default:
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+// hashes produced elsewhere can not make verification arbitrarily expensive
+func TestArgon2id_Limits(t *testing.T) {
+	phc := func(m, t, p int, saltLength, keyLength int) []byte {
+		salt := base64.RawStdEncoding.EncodeToString(make([]byte, saltLength))
+		key := base64.RawStdEncoding.EncodeToString(make([]byte, keyLength))
+		return []byte(fmt.Sprintf("$argon2id$v=19$m=%d,t=%d,p=%d$%s$%s", m, t, p, salt, key))
+	}
+	maxMemory := int(maxArgon2Params.Memory)
+
+	tests := []struct {
+		name    string
+		params  Argon2Params
+		hash    []byte
+		wantErr bool
+	}{
+		{name: "at the limits", params: testArgon2Params, hash: phc(maxMemory, 16, 16, 64, 64)},
+		{name: "memory above the limit", params: testArgon2Params, hash: phc(maxMemory+1, 1, 1, 16, 32), wantErr: true},
+		{name: "huge memory", params: testArgon2Params, hash: phc(1<<32-1, 1, 1, 16, 32), wantErr: true},
+		{name: "iterations above the limit", params: testArgon2Params, hash: phc(64, 17, 1, 16, 32), wantErr: true},
+		{name: "parallelism above the limit", params: testArgon2Params, hash: phc(256, 1, 17, 16, 32), wantErr: true},
+		{name: "parallelism out of range", params: testArgon2Params, hash: phc(4096, 1, 256, 16, 32), wantErr: true},
+		{name: "salt too long", params: testArgon2Params, hash: phc(64, 1, 1, 65, 32), wantErr: true},
+		{name: "key too long", params: testArgon2Params, hash: phc(64, 1, 1, 16, 1<<20), wantErr: true},
+		{
+			name:   "configured parameters raise the limits",
+			params: Argon2Params{Memory: maxArgon2Params.Memory * 2, Iterations: 20, Parallelism: 1},
+			hash:   phc(maxMemory*2, 20, 1, 16, 32),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestArgon2id(t, tt.params)
+
+			err := NewHasher(a).Validate(tt.hash)
+			if !tt.wantErr {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrUnknownHash)
+			// rejected before the key is derived
+			require.ErrorIs(t, a.Verify(tt.hash, testPassword), ErrUnknownHash)
+		})
+	}
+}
+
+func TestNewSchemes_InvalidParameters(t *testing.T) {
+	_, err := NewBcrypt(bcrypt.MinCost - 1)
+	require.Error(t, err)
+	_, err = NewBcrypt(bcrypt.MaxCost + 1)
+	require.Error(t, err)
+
+	_, err = NewArgon2id(Argon2Params{Memory: 64, Iterations: 0, Parallelism: 1})
+	require.Error(t, err)
+	_, err = NewArgon2id(Argon2Params{Memory: 64, Iterations: 1, Parallelism: 0})
+	require.Error(t, err)
+	_, err = NewArgon2id(Argon2Params{Memory: 8, Iterations: 1, Parallelism: 2})
+	require.Error(t, err)
+}
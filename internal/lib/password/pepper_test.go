@@ -0,0 +1,114 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// 16 bytes each
+	testPepper1 = "MDEyMzQ1Njc4OWFiY2RlZg=="
+	testPepper2 = "ZmVkY2JhOTg3NjU0MzIxMA=="
+)
+
+func TestParsePeppers(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		current int
+	}{
+		{name: "empty", value: "", current: 0},
+		{name: "only separators", value: " , ,", current: 0},
+		{name: "single", value: "1:" + testPepper1, current: 1},
+		{name: "highest version is current", value: "3:" + testPepper1 + ",2:" + testPepper2, current: 3},
+		{name: "spaces around pairs", value: " 1:" + testPepper1 + " , 2:" + testPepper2 + " ", current: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peppers, err := ParsePeppers(tt.value)
+			require.NoError(t, err)
+			require.Equal(t, tt.current, peppers.Current())
+		})
+	}
+}
+
+func TestParsePeppers_Malformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "no version", value: testPepper1},
+		{name: "empty version", value: ":" + testPepper1},
+		{name: "version not a number", value: "v1:" + testPepper1},
+		{name: "zero version", value: "0:" + testPepper1},
+		{name: "negative version", value: "-1:" + testPepper1},
+		{name: "duplicate version", value: "1:" + testPepper1 + ",1:" + testPepper2},
+		{name: "key not base64", value: "1:not base64!"},
+		{name: "key url base64", value: "1:MDEyMzQ1Njc4OWFiY2RlZg"},
+		{name: "empty key", value: "1:"},
+		{name: "key too short", value: "1:c2hvcnQ="},
+		{name: "one bad pair among good", value: "1:" + testPepper1 + ",2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peppers, err := ParsePeppers(tt.value)
+			require.Error(t, err)
+			require.Nil(t, peppers)
+			// the key must never end up in the error, it is logged on startup
+			require.NotContains(t, err.Error(), testPepper2)
+		})
+	}
+}
+
+func TestPeppers_Apply(t *testing.T) {
+	peppers, err := ParsePeppers("1:" + testPepper1 + ",2:" + testPepper2)
+	require.NoError(t, err)
+
+	unpeppered, err := peppers.Apply(0, testPassword)
+	require.NoError(t, err)
+	require.Equal(t, testPassword, unpeppered)
+
+	v1, err := peppers.Apply(1, testPassword)
+	require.NoError(t, err)
+	v1Again, err := peppers.Apply(1, testPassword)
+	require.NoError(t, err)
+	v2, err := peppers.Apply(2, testPassword)
+	require.NoError(t, err)
+
+	require.Equal(t, v1, v1Again)
+	require.NotEqual(t, v1, v2)
+	require.NotEqual(t, testPassword, v1)
+	require.LessOrEqual(t, len(v1), MaxBcryptLength)
+
+	_, err = peppers.Apply(3, testPassword)
+	require.ErrorIs(t, err, ErrUnknownPepper)
+}
+
+// a rotated config verifies hashes of the old version and hashes new passwords with the newest one
+func TestPeppers_Rotation(t *testing.T) {
+	a, err := NewArgon2id(testArgon2Params)
+	require.NoError(t, err)
+	hasher := NewHasher(a)
+
+	old, err := ParsePeppers("1:" + testPepper1)
+	require.NoError(t, err)
+	peppered, err := old.Apply(old.Current(), testPassword)
+	require.NoError(t, err)
+	hash, err := hasher.Hash(peppered)
+	require.NoError(t, err)
+
+	rotated, err := ParsePeppers("1:" + testPepper1 + ",2:" + testPepper2)
+	require.NoError(t, err)
+	require.Equal(t, 2, rotated.Current())
+
+	peppered, err = rotated.Apply(1, testPassword)
+	require.NoError(t, err)
+	require.NoError(t, hasher.Verify(hash, peppered))
+
+	peppered, err = rotated.Apply(rotated.Current(), testPassword)
+	require.NoError(t, err)
+	require.ErrorIs(t, hasher.Verify(hash, peppered), ErrMismatch)
+}
@@ -0,0 +1,99 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxBcryptLength is the number of bytes bcrypt takes into account, longer passwords are rejected by it
+const MaxBcryptLength = 72
+
+// PolicyError describes why a password does not satisfy the policy, its text is safe to show to the user
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Reason
+}
+
+// Policy describes requirements for new passwords
+type Policy struct {
+	// MinLength is the minimal number of characters
+	MinLength int
+	// MaxLength is the maximal number of bytes, it is capped by MaxBcryptLength
+	MaxLength int
+	// MinClasses is the number of character classes (lowercase, uppercase, digits, symbols)
+	// the password has to contain
+	MinClasses int
+	// Breached is checked for every password when set
+	Breached *BreachedList
+}
+
+// Validate returns *PolicyError if password can not be used by the user with given email
+// and another error if the breached list could not be read; the password must not be accepted then
+func (p Policy) Validate(password, email string) error {
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		return &PolicyError{Reason: fmt.Sprintf("password must be at least %d characters long", p.MinLength)}
+	}
+
+	maxLength := MaxBcryptLength
+	if p.MaxLength > 0 && p.MaxLength < maxLength {
+		maxLength = p.MaxLength
+	}
+	if len(password) > maxLength {
+		return &PolicyError{Reason: fmt.Sprintf("password must be at most %d bytes long", maxLength)}
+	}
+
+	if classes := characterClasses(password); classes < p.MinClasses {
+		return &PolicyError{Reason: fmt.Sprintf(
+			"password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols",
+			p.MinClasses,
+		)}
+	}
+
+	if email != "" {
+		local, _, _ := strings.Cut(email, "@")
+		if strings.EqualFold(password, email) || strings.EqualFold(password, local) {
+			return &PolicyError{Reason: "password must not be the same as email"}
+		}
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			return &PolicyError{Reason: "password has appeared in a data breach, choose another one"}
+		}
+	}
+
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	return classes
+}
@@ -0,0 +1,57 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	policy := Policy{MinLength: 8, MaxLength: 32, MinClasses: 3}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		email    string
+		reason   string
+	}{
+		{name: "valid", password: "Correct-Horse-7", email: "user@example.com"},
+		{name: "too short", password: "Ab1-", reason: "at least 8 characters"},
+		{name: "length in characters", password: "Пароль1!", email: "user@example.com"},
+		{name: "too long", password: "Aa1-" + strings.Repeat("x", 29), reason: "at most 32 bytes"},
+		{name: "long with multibyte", password: "Aa1-" + strings.Repeat("ж", 15), reason: "at most 32 bytes"},
+		{
+			name:     "max length is capped by bcrypt",
+			policy:   Policy{MaxLength: 100},
+			password: strings.Repeat("a", MaxBcryptLength+1),
+			reason:   "at most 72 bytes",
+		},
+		{name: "two classes", password: "correcthorse7", reason: "at least 3 of"},
+		{name: "three classes", password: "correct-horse7"},
+		{name: "unicode letters", password: "Пароль-пароль"},
+		{name: "same as email", password: "User@Example.com1", email: "user@example.com1", reason: "same as email"},
+		{name: "same as local part", password: "Alice.Smith-1", email: "alice.smith-1@example.com", reason: "same as email"},
+		{name: "contains local part", password: "Alice.Smith-1!", email: "alice.smith-1@example.com"},
+		{name: "no email", password: "Alice.Smith-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := policy
+			if tt.policy != (Policy{}) {
+				p = tt.policy
+			}
+
+			err := p.Validate(tt.password, tt.email)
+			if tt.reason == "" {
+				require.NoError(t, err)
+				return
+			}
+			var policyErr *PolicyError
+			require.ErrorAs(t, err, &policyErr)
+			require.Contains(t, policyErr.Reason, tt.reason)
+		})
+	}
+}
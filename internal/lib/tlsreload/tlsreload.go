@@ -0,0 +1,179 @@
+package tlsreload
+
+// TLS конфигурация сервера, сертификаты которой перечитываются с диска без перезапуска
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrNoCertificates = errors.New("no certificates found")
+
+// Reloader serves the certificate, key and client CAs read from files and reloads them
+// when the files change. Files are checked at most once per interval on new connections,
+// a failed reload is logged and the previous certificates stay in use.
+type Reloader struct {
+	logger       *slog.Logger
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	interval     time.Duration
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	modTimes  map[string]time.Time
+
+	current atomic.Pointer[certificates]
+}
+
+type certificates struct {
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// New loads the files, clientCAFile may be empty when client certificates are not verified
+func New(logger *slog.Logger, certFile, keyFile, clientCAFile string, interval time.Duration) (*Reloader, error) {
+	const op = "tlsreload.New"
+
+	r := &Reloader{
+		logger:       logger,
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		interval:     interval,
+	}
+
+	modTimes, err := r.stat()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := r.load(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	r.modTimes = modTimes
+	r.checkedAt = time.Now()
+
+	return r, nil
+}
+
+// ServerConfig returns a TLS config for a server using the current certificates.
+// clientAuth is tls.NoClientCert unless a client CA file is given.
+func (r *Reloader) ServerConfig(minVersion uint16, clientAuth tls.ClientAuthType) *tls.Config {
+	return &tls.Config{
+		MinVersion: minVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.reloadIfChanged()
+			current := r.current.Load()
+
+			return &tls.Config{
+				MinVersion:   minVersion,
+				Certificates: []tls.Certificate{*current.cert},
+				ClientAuth:   clientAuth,
+				ClientCAs:    current.clientCAs,
+				// gRPC clients require HTTP/2 to be negotiated with ALPN
+				NextProtos: []string{"h2"},
+			}, nil
+		},
+	}
+}
+
+// reloadIfChanged reloads the files if the interval has passed and any of them was modified
+func (r *Reloader) reloadIfChanged() {
+	const op = "tlsreload.reloadIfChanged"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) < r.interval {
+		return
+	}
+	r.checkedAt = time.Now()
+
+	log := r.logger.With(slog.String("op", op))
+
+	modTimes, err := r.stat()
+	if err != nil {
+		log.Error("failed to check certificate files", slog.String("error", err.Error()))
+		return
+	}
+	if sameModTimes(modTimes, r.modTimes) {
+		return
+	}
+
+	if err := r.load(); err != nil {
+		// файлы могут быть записаны не полностью, попробуем при следующей проверке
+		log.Error("failed to reload certificates", slog.String("error", err.Error()))
+		return
+	}
+	r.modTimes = modTimes
+
+	log.Info("certificates reloaded")
+}
+
+func (r *Reloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s: %w", r.clientCAFile, ErrNoCertificates)
+		}
+	}
+
+	r.current.Store(&certificates{cert: &cert, clientCAs: clientCAs})
+	return nil
+}
+
+func (r *Reloader) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	for _, path := range []string{r.certFile, r.keyFile, r.clientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[path] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func sameModTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, modTime := range a {
+		if !b[path].Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseVersion parses a TLS version such as "1.2", empty string is TLS 1.2
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q", version)
+	}
+}
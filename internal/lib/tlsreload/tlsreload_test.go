@@ -0,0 +1,175 @@
+package tlsreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const interval = 100 * time.Millisecond
+
+func TestReloader_ServesNewCertificateAfterInterval(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir(), 1, time.Now())
+
+	r, err := New(discardLogger(), certFile, keyFile, "", interval)
+	require.NoError(t, err)
+	cfg := r.ServerConfig(tls.VersionTLS12, tls.NoClientCert)
+	require.EqualValues(t, 1, servedSerial(t, cfg))
+
+	writeCertificateTo(t, certFile, keyFile, 2, time.Now().Add(time.Second))
+
+	// files are not checked again before the interval has passed
+	require.EqualValues(t, 1, servedSerial(t, cfg))
+
+	time.Sleep(interval + 20*time.Millisecond)
+	require.EqualValues(t, 2, servedSerial(t, cfg))
+}
+
+func TestReloader_KeepsCertificateOnBadInput(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir(), 1, time.Now())
+
+	r, err := New(discardLogger(), certFile, keyFile, "", interval)
+	require.NoError(t, err)
+	cfg := r.ServerConfig(tls.VersionTLS12, tls.NoClientCert)
+
+	// a half-written certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("-----BEGIN CERTIFICATE-----\nMIIB"), 0o600))
+	touch(t, certFile, time.Now().Add(time.Second))
+
+	time.Sleep(interval + 20*time.Millisecond)
+	require.EqualValues(t, 1, servedSerial(t, cfg))
+
+	// a removed key file
+	require.NoError(t, os.Remove(keyFile))
+
+	time.Sleep(interval + 20*time.Millisecond)
+	require.EqualValues(t, 1, servedSerial(t, cfg))
+
+	// the next complete write is picked up
+	writeCertificateTo(t, certFile, keyFile, 3, time.Now().Add(2*time.Second))
+
+	time.Sleep(interval + 20*time.Millisecond)
+	require.EqualValues(t, 3, servedSerial(t, cfg))
+}
+
+func TestNew_Errors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir, 1, time.Now())
+
+	emptyCA := filepath.Join(dir, "empty-ca.pem")
+	require.NoError(t, os.WriteFile(emptyCA, []byte("not a certificate"), 0o600))
+
+	_, err := New(discardLogger(), certFile, keyFile, emptyCA, interval)
+	require.ErrorIs(t, err, ErrNoCertificates)
+
+	_, err = New(discardLogger(), certFile, filepath.Join(dir, "missing.key"), "", interval)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = New(discardLogger(), keyFile, certFile, "", interval)
+	require.Error(t, err)
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    uint16
+		wantErr bool
+	}{
+		{version: "", want: tls.VersionTLS12},
+		{version: "1.2", want: tls.VersionTLS12},
+		{version: "1.3", want: tls.VersionTLS13},
+		{version: "1.1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := ParseVersion(tt.version)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// servedSerial makes a TLS handshake with cfg and returns the serial number of the server certificate
+func servedSerial(t *testing.T, cfg *tls.Config) int64 {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- tls.Server(serverConn, cfg).Handshake()
+	}()
+
+	client := tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+	require.NoError(t, client.Handshake())
+	require.NoError(t, <-errs)
+
+	return client.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+}
+
+func writeCertificate(t *testing.T, dir string, serial int64, modTime time.Time) (string, string) {
+	t.Helper()
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertificateTo(t, certFile, keyFile, serial, modTime)
+	return certFile, keyFile
+}
+
+// writeCertificateTo writes a self-signed certificate with serial, modTime is set explicitly
+// so that changes are detected regardless of the file system timestamp resolution
+func writeCertificateTo(t *testing.T, certFile, keyFile string, serial int64, modTime time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	touch(t, certFile, modTime)
+	touch(t, keyFile, modTime)
+}
+
+func touch(t *testing.T, path string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
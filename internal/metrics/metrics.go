@@ -0,0 +1,177 @@
+package metrics
+
+// метрики Prometheus: gRPC сервер, события сервиса auth, хеширование паролей, запросы к хранилищу
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+	"usekit-auth/internal/domain/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/codes"
+)
+
+const namespace = "usekit_auth"
+
+// Metrics holds collectors of the service registered in its own registry
+type Metrics struct {
+	registry *prometheus.Registry
+
+	rpcHandled  *prometheus.CounterVec
+	rpcDuration *prometheus.HistogramVec
+
+	logins        *prometheus.CounterVec
+	registrations *prometheus.CounterVec
+	hashDuration  *prometheus.HistogramVec
+
+	queryDuration *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		rpcHandled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "grpc_server_handled_total",
+			Help:      "Number of gRPC calls completed on the server by method and status code.",
+		}, []string{"method", "code"}),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "grpc_server_handling_seconds",
+			Help:      "Duration of gRPC calls handled by the server by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Login attempts by outcome, failure reason and app, app_id is unknown for failed attempts.",
+		}, []string{"outcome", "reason", "app_id"}),
+		registrations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "registrations_total",
+			Help:      "Registration attempts by outcome and failure reason.",
+		}, []string{"outcome", "reason"}),
+		hashDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "password_hash_duration_seconds",
+			Help:      "Duration of password hashing and verification by algorithm.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"algorithm", "operation"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_query_duration_seconds",
+			Help:      "Duration of storage calls by storage method.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"method"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.rpcHandled,
+		m.rpcDuration,
+		m.logins,
+		m.registrations,
+		m.hashDuration,
+		m.queryDuration,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRPC records a completed gRPC call
+func (m *Metrics) ObserveRPC(method string, code codes.Code, duration time.Duration) {
+	m.rpcHandled.WithLabelValues(method, code.String()).Inc()
+	m.rpcDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+// ObserveQuery records a storage call, method is the storage operation name
+func (m *Metrics) ObserveQuery(method string, duration time.Duration) {
+	m.queryDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+// AuditSink counts logins and registrations from audit events, so the service
+// reports them in one place together with the audit log
+type AuditSink struct {
+	metrics *Metrics
+}
+
+func (m *Metrics) AuditSink() *AuditSink {
+	return &AuditSink{metrics: m}
+}
+
+func (s *AuditSink) Write(_ context.Context, event models.AuditEvent) error {
+	switch event.Type {
+	case models.AuditLogin:
+		s.metrics.logins.WithLabelValues(string(event.Outcome), event.Reason, appLabel(event)).Inc()
+	case models.AuditRegister:
+		s.metrics.registrations.WithLabelValues(string(event.Outcome), event.Reason).Inc()
+	}
+	return nil
+}
+
+// appLabel is the app id of successful logins only: failed ones carry whatever id the
+// client sent, which is not checked before credentials, and would make series unbounded
+func appLabel(event models.AuditEvent) string {
+	if event.Outcome != models.AuditSuccess {
+		return "unknown"
+	}
+	return strconv.Itoa(event.AppId)
+}
+
+func (s *AuditSink) Close() error {
+	return nil
+}
+
+// PasswordHasher matches the hasher used by services/auth
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	Verify(hash []byte, password string) error
+	NeedsRehash(hash []byte) bool
+	Validate(hash []byte) error
+}
+
+// InstrumentHasher returns hasher that records the duration of Hash and Verify
+func (m *Metrics) InstrumentHasher(hasher PasswordHasher) PasswordHasher {
+	return &instrumentedHasher{PasswordHasher: hasher, metrics: m}
+}
+
+type instrumentedHasher struct {
+	PasswordHasher
+	metrics *Metrics
+}
+
+func (h *instrumentedHasher) Hash(password string) ([]byte, error) {
+	start := time.Now()
+	hash, err := h.PasswordHasher.Hash(password)
+	h.metrics.hashDuration.WithLabelValues(algorithmOf(hash), "hash").Observe(time.Since(start).Seconds())
+	return hash, err
+}
+
+func (h *instrumentedHasher) Verify(hash []byte, password string) error {
+	start := time.Now()
+	err := h.PasswordHasher.Verify(hash, password)
+	h.metrics.hashDuration.WithLabelValues(algorithmOf(hash), "verify").Observe(time.Since(start).Seconds())
+	return err
+}
+
+func algorithmOf(hash []byte) string {
+	switch {
+	case bytes.HasPrefix(hash, []byte("$argon2id$")):
+		return "argon2id"
+	case bytes.HasPrefix(hash, []byte("$2")):
+		return "bcrypt"
+	default:
+		return "unknown"
+	}
+}
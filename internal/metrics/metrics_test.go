@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"usekit-auth/internal/domain/models"
+)
+
+func TestAuditSink_LoginAppLabel(t *testing.T) {
+	m := New()
+	sink := m.AuditSink()
+	ctx := context.Background()
+
+	require.NoError(t, sink.Write(ctx, models.AuditEvent{Type: models.AuditLogin, Outcome: models.AuditSuccess, AppId: 2}))
+	for appId := 1000; appId < 1010; appId++ {
+		require.NoError(t, sink.Write(ctx, models.AuditEvent{
+			Type:    models.AuditLogin,
+			Outcome: models.AuditFailure,
+			Reason:  "invalid credentials",
+			AppId:   appId,
+		}))
+	}
+
+	require.Equal(t, 2, testutil.CollectAndCount(m.logins))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.logins.WithLabelValues("success", "", "2")))
+	require.Equal(t, float64(10), testutil.ToFloat64(m.logins.WithLabelValues("failure", "invalid credentials", "unknown")))
+}
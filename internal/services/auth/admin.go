@@ -0,0 +1,312 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/lib/jwt"
+	"usekit-auth/internal/storage"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Authenticate verifies token issued by Login and returns its claims.
+// Tokens of revoked or expired sessions are rejected.
+func (a *Auth) Authenticate(ctx context.Context, token string) (jwt.Claims, error) {
+	const op = "services/auth.Authenticate"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op))
+
+	claims, err := jwt.ParseToken(token, func(appId int) (string, error) {
+		app, err := a.appProvider.App(ctx, appId)
+		if err != nil {
+			return "", err
+		}
+		return app.Secret, nil
+	})
+	if err != nil {
+		logger.Info("invalid token", slog.String("error", err.Error()))
+		return jwt.Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if err := a.checkSession(ctx, claims.UserId, claims.SessionId); err != nil {
+		logger.Info("invalid session", slog.String("error", err.Error()))
+		return jwt.Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// authorizeAdmin checks that token belongs to an active admin and returns the admin's user id.
+func (a *Auth) authorizeAdmin(ctx context.Context, token string) (int64, error) {
+	caller, _, err := a.caller(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	if !caller.IsAdmin {
+		return 0, ErrPermissionDenied
+	}
+
+	return caller.Id, nil
+}
+
+// authorizeSelfOrAdmin checks that token belongs to an active user who is either the user
+// with given userId or an admin. The caller is returned with ErrPermissionDenied as well,
+// so the denial can be attributed in the audit log.
+func (a *Auth) authorizeSelfOrAdmin(ctx context.Context, token string, userId int64) (models.User, error) {
+	caller, _, err := a.caller(ctx, token)
+	if err != nil {
+		return models.User{}, err
+	}
+	if caller.Id != userId && !caller.IsAdmin {
+		return caller, ErrPermissionDenied
+	}
+
+	return caller, nil
+}
+
+// caller returns the active user the token was issued to and the token claims.
+func (a *Auth) caller(ctx context.Context, token string) (models.User, jwt.Claims, error) {
+	claims, err := a.Authenticate(ctx, token)
+	if err != nil {
+		return models.User{}, jwt.Claims{}, err
+	}
+
+	user, err := a.usrProvider.UserById(ctx, claims.UserId)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.User{}, jwt.Claims{}, ErrInvalidToken
+		}
+		return models.User{}, jwt.Claims{}, err
+	}
+	if user.Status != models.UserStatusActive {
+		return models.User{}, jwt.Claims{}, ErrUserInactive
+	}
+
+	return user, claims, nil
+}
+
+// ListUsers returns a page of users matching filter. Only admins are allowed to list users.
+//
+// pageToken is the NextPageToken of the previous page, empty for the first page.
+func (a *Auth) ListUsers(
+	ctx context.Context,
+	token string,
+	filter models.UserFilter,
+	pageSize int,
+	pageToken string,
+) (models.UserPage, error) {
+	const op = "services/auth.ListUsers"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op))
+
+	event := models.AuditEvent{Type: models.AuditUsersListed}
+
+	adminId, err := a.authorizeAdmin(ctx, token)
+	if err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		logger.Warn("admin authorization failed", slog.String("error", err.Error()))
+		return models.UserPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+	logger = logger.With(slog.Int64("admin_id", adminId))
+	event.ActorId = adminId
+
+	if filter.Role != "" && filter.Role != models.RoleAdmin && filter.Role != models.RoleUser {
+		return models.UserPage{}, fmt.Errorf("%s: %w: unknown role %q", op, ErrInvalidFilter, filter.Role)
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return models.UserPage{}, fmt.Errorf("%s: %w: unknown status %q", op, ErrInvalidFilter, filter.Status)
+	}
+
+	switch {
+	case pageSize <= 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	cursor, err := decodePageToken(pageToken)
+	if err != nil {
+		return models.UserPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// one extra user tells whether there is a next page
+	users, err := a.usrProvider.ListUsers(ctx, filter, cursor, pageSize+1)
+	if err != nil {
+		logger.Error("failed to list users", slog.String("error", err.Error()))
+		return models.UserPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := models.UserPage{Users: users}
+	if len(users) > pageSize {
+		page.Users = users[:pageSize]
+		last := page.Users[pageSize-1]
+		page.NextPageToken = encodePageToken(models.UserCursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	for i := range page.Users {
+		page.Users[i] = withoutSecrets(page.Users[i])
+	}
+
+	a.recordSuccess(ctx, event)
+
+	logger.Info("listed users", slog.Int("count", len(page.Users)))
+	return page, nil
+}
+
+// DisableUser sets status of user with given userId to disabled and revokes all of their sessions.
+// Only admins are allowed to disable users.
+func (a *Auth) DisableUser(ctx context.Context, token string, userId int64) error {
+	const op = "services/auth.DisableUser"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	return a.changeUser(ctx, op, models.AuditUserDisabled, token, userId, func() error {
+		if err := a.usrSaver.SetUserStatus(ctx, userId, models.UserStatusDisabled); err != nil {
+			return err
+		}
+		return a.revokeAllSessions(ctx, userId)
+	})
+}
+
+// EnableUser sets status of user with given userId back to active. Only admins are allowed to enable users.
+func (a *Auth) EnableUser(ctx context.Context, token string, userId int64) error {
+	const op = "services/auth.EnableUser"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	return a.changeUser(ctx, op, models.AuditUserEnabled, token, userId, func() error {
+		return a.usrSaver.SetUserStatus(ctx, userId, models.UserStatusActive)
+	})
+}
+
+// DeleteUser marks user with given userId as pending deletion and revokes all of their sessions,
+// the user can no longer log in and is erased by EraseUsers once the retention period has passed.
+// EnableUser cancels the deletion. Only admins are allowed to delete users.
+func (a *Auth) DeleteUser(ctx context.Context, token string, userId int64) error {
+	const op = "services/auth.DeleteUser"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	return a.changeUser(ctx, op, models.AuditUserDeleted, token, userId, func() error {
+		if err := a.usrSaver.RequestUserDeletion(ctx, userId); err != nil {
+			return err
+		}
+		return a.revokeAllSessions(ctx, userId)
+	})
+}
+
+// revokeAllSessions revokes every session of user with given userId,
+// tokens issued before the user was disabled or deleted stay rejected after EnableUser
+func (a *Auth) revokeAllSessions(ctx context.Context, userId int64) error {
+	_, err := a.sessions.RevokeOtherSessions(ctx, userId, "", time.Now())
+	return err
+}
+
+// SetAdmin grants or revokes admin rights of user with given userId. The caller is not authorized,
+// it is meant for operator tools with direct access to the storage such as cmd/authadmin.
+func (a *Auth) SetAdmin(ctx context.Context, userId int64, isAdmin bool) error {
+	const op = "services/auth.SetAdmin"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op), slog.Int64("user_id", userId))
+
+	event := models.AuditEvent{Type: models.AuditAdminRevoked, SubjectId: userId}
+	if isAdmin {
+		event.Type = models.AuditAdminGranted
+	}
+
+	if err := a.usrSaver.SetUserAdmin(ctx, userId, isAdmin); err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		if errors.Is(err, storage.ErrUserNotFound) {
+			logger.Warn("user not found")
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		logger.Error("failed to change admin rights", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.recordSuccess(ctx, event)
+
+	logger.Info("admin rights changed", slog.Bool("is_admin", isAdmin))
+	return nil
+}
+
+// changeUser authorizes an admin and applies change to another user's account.
+func (a *Auth) changeUser(
+	ctx context.Context,
+	op string,
+	eventType models.AuditEventType,
+	token string,
+	userId int64,
+	change func() error,
+) error {
+	logger := a.log(ctx).With(slog.String("operation", op), slog.Int64("user_id", userId))
+
+	event := models.AuditEvent{Type: eventType, SubjectId: userId}
+
+	adminId, err := a.authorizeAdmin(ctx, token)
+	if err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		logger.Warn("admin authorization failed", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	logger = logger.With(slog.Int64("admin_id", adminId))
+	event.ActorId = adminId
+
+	// an admin locking themselves out would leave nobody to undo it
+	if adminId == userId {
+		a.recordFailure(ctx, event, failureReason(ErrSelfAction))
+		return fmt.Errorf("%s: %w", op, ErrSelfAction)
+	}
+
+	if err := change(); err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		if errors.Is(err, storage.ErrUserNotFound) {
+			logger.Warn("user not found")
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		logger.Error("failed to change user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.recordSuccess(ctx, event)
+
+	logger.Info("user changed")
+	return nil
+}
+
+func encodePageToken(cursor models.UserCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodePageToken(pageToken string) (*models.UserCursor, error) {
+	if pageToken == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	var cursor models.UserCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.Id == 0 {
+		return nil, ErrInvalidPageToken
+	}
+
+	return &cursor, nil
+}
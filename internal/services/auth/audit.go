@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/lib/clientinfo"
+	"usekit-auth/internal/storage"
+)
+
+func (a *Auth) recordSuccess(ctx context.Context, event models.AuditEvent) {
+	event.Outcome = models.AuditSuccess
+	a.record(ctx, event)
+}
+
+func (a *Auth) recordFailure(ctx context.Context, event models.AuditEvent, reason string) {
+	event.Outcome = models.AuditFailure
+	event.Reason = reason
+	a.record(ctx, event)
+}
+
+// record writes event to the audit sink. A failing sink is logged but never fails the request.
+func (a *Auth) record(ctx context.Context, event models.AuditEvent) {
+	info := clientinfo.From(ctx)
+	event.IP = info.IP
+	event.UserAgent = info.UserAgent
+	event.CreatedAt = time.Now()
+
+	// the event has to be written even if the client has already gone away
+	if err := a.auditSink.Write(context.WithoutCancel(ctx), event); err != nil {
+		a.log(ctx).Error("failed to write audit event",
+			slog.String("type", string(event.Type)),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// failureReason describes err for the audit log without leaking internal details
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrUserNotFound), errors.Is(err, ErrUserNotFound):
+		return "user not found"
+	case errors.Is(err, storage.ErrUserExists), errors.Is(err, ErrUserExists):
+		return "user already exists"
+	case errors.Is(err, storage.ErrAppNotFound):
+		return "app not found"
+	case errors.Is(err, ErrInvalidToken):
+		return "invalid token"
+	case errors.Is(err, ErrPermissionDenied):
+		return "permission denied"
+	case errors.Is(err, ErrUserInactive):
+		return "user is not active"
+	case errors.Is(err, ErrSelfAction):
+		return "action on own account"
+	case errors.Is(err, storage.ErrSessionNotFound), errors.Is(err, ErrSessionNotFound):
+		return "session not found"
+	default:
+		return "internal error"
+	}
+}
+
+// ListAuditEvents returns a page of audit events matching filter from the newest to the oldest.
+// Only admins are allowed to list audit events.
+//
+// pageToken is the NextPageToken of the previous page, empty for the first page.
+func (a *Auth) ListAuditEvents(
+	ctx context.Context,
+	token string,
+	filter models.AuditFilter,
+	pageSize int,
+	pageToken string,
+) (models.AuditPage, error) {
+	const op = "services/auth.ListAuditEvents"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op))
+
+	event := models.AuditEvent{Type: models.AuditEventsListed}
+
+	adminId, err := a.authorizeAdmin(ctx, token)
+	if err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		logger.Warn("admin authorization failed", slog.String("error", err.Error()))
+		return models.AuditPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+	event.ActorId = adminId
+
+	switch {
+	case pageSize <= 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	var beforeId int64
+	if pageToken != "" {
+		beforeId, err = strconv.ParseInt(pageToken, 10, 64)
+		if err != nil || beforeId <= 0 {
+			return models.AuditPage{}, fmt.Errorf("%s: %w", op, ErrInvalidPageToken)
+		}
+	}
+
+	events, err := a.auditProvider.AuditEvents(ctx, filter, beforeId, pageSize+1)
+	if err != nil {
+		logger.Error("failed to list audit events", slog.String("error", err.Error()))
+		return models.AuditPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := models.AuditPage{Events: events}
+	if len(events) > pageSize {
+		page.Events = events[:pageSize]
+		page.NextPageToken = strconv.FormatInt(page.Events[pageSize-1].Id, 10)
+	}
+
+	a.recordSuccess(ctx, event)
+	return page, nil
+}
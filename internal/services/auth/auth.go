@@ -4,27 +4,56 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"golang.org/x/crypto/bcrypt"
 	"log/slog"
+	"sync"
 	"time"
 	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/lib/emailaddr"
 	"usekit-auth/internal/lib/jwt"
+	"usekit-auth/internal/lib/logctx"
+	"usekit-auth/internal/lib/password"
 	"usekit-auth/internal/storage"
+
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("usekit-auth/internal/services/auth")
+
 var (
 	ErrInvalidAppId       = errors.New("invalid app_id")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserExists         = errors.New("user already exists")
 	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidFieldMask   = errors.New("invalid field mask")
+	ErrInvalidProfile     = errors.New("invalid profile")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrInvalidFilter      = errors.New("invalid filter")
+	ErrInvalidPageToken   = errors.New("invalid page token")
+	ErrSelfAction         = errors.New("action is not allowed on own account")
+	ErrUserInactive       = errors.New("user is not active")
+	ErrWeakPassword       = errors.New("password does not satisfy the policy")
+	ErrInvalidEmail       = errors.New("invalid email")
+	ErrInvalidPassHash    = errors.New("invalid password hash")
 )
 
 type Auth struct {
-	logger      *slog.Logger
-	usrSaver    UserSaver
-	usrProvider UserProvider
-	appProvider AppProvider
-	tokenTTL    time.Duration
+	logger        *slog.Logger
+	usrSaver      UserSaver
+	usrProvider   UserProvider
+	appProvider   AppProvider
+	sessions      SessionStore
+	auditSink     AuditSink
+	auditProvider AuditProvider
+	passwords     PasswordPolicy
+	hasher        PasswordHasher
+	pepper        PasswordPepper
+	tokenTTL      time.Duration
+	// constantTimeLogin makes Login spend the same time and return the same error
+	// for unknown emails as for wrong passwords
+	constantTimeLogin bool
+	dummyHashOnce     sync.Once
+	dummyHash         []byte
 }
 
 type UserSaver interface {
@@ -32,39 +61,130 @@ type UserSaver interface {
 		ctx context.Context,
 		email string,
 		passHash []byte,
+		pepperVersion int,
 	) (id int64, err error)
+	UpdateUser(
+		ctx context.Context,
+		userId int64,
+		profile models.UserProfile,
+		paths []string,
+	) (models.User, error)
+	UpdatePassHash(ctx context.Context, userId int64, passHash []byte, pepperVersion int) error
+	SetUserStatus(ctx context.Context, userId int64, status models.UserStatus) error
+	SetUserAdmin(ctx context.Context, userId int64, isAdmin bool) error
+	RequestUserDeletion(ctx context.Context, userId int64) error
+	EraseUser(ctx context.Context, userId int64) error
 }
 
 type UserProvider interface {
 	User(ctx context.Context, email string) (models.User, error)
+	UserById(ctx context.Context, userId int64) (models.User, error)
 	IsAdmin(ctx context.Context, userId int64) (bool, error)
+	ListUsers(
+		ctx context.Context,
+		filter models.UserFilter,
+		cursor *models.UserCursor,
+		limit int,
+	) ([]models.User, error)
+	UsersPendingErasure(ctx context.Context, requestedBefore time.Time, limit int) ([]int64, error)
 }
 
 type AppProvider interface {
 	App(ctx context.Context, appId int) (models.App, error)
 }
 
+type SessionStore interface {
+	SaveSession(ctx context.Context, session models.Session) error
+	Session(ctx context.Context, sessionId string) (models.Session, error)
+	UserSessions(ctx context.Context, userId int64, now time.Time, includeInactive bool) ([]models.Session, error)
+	TouchSession(ctx context.Context, sessionId string, seenAt time.Time) error
+	RevokeSession(ctx context.Context, userId int64, sessionId string, revokedAt time.Time) error
+	RevokeOtherSessions(ctx context.Context, userId int64, keepSessionId string, revokedAt time.Time) (int64, error)
+	PruneSessions(ctx context.Context, endedBefore time.Time) (int64, error)
+}
+
+// AuditSink receives security-relevant events, see package audit for implementations
+type AuditSink interface {
+	Write(ctx context.Context, event models.AuditEvent) error
+}
+
+type AuditProvider interface {
+	AuditEvents(
+		ctx context.Context,
+		filter models.AuditFilter,
+		beforeId int64,
+		limit int,
+	) ([]models.AuditEvent, error)
+}
+
+// PasswordPolicy checks passwords chosen by users, see password.Policy
+type PasswordPolicy interface {
+	// Validate returns *password.PolicyError if password is rejected by the policy
+	// and another error if it could not be checked
+	Validate(password, email string) error
+}
+
+// PasswordHasher produces self-describing password hashes, see password.Hasher
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	// Verify returns password.ErrMismatch if password does not match hash
+	Verify(hash []byte, password string) error
+	// NeedsRehash reports whether hash uses another algorithm or weaker parameters than configured
+	NeedsRehash(hash []byte) bool
+	// Validate returns an error if hash is malformed or of an unknown algorithm
+	Validate(hash []byte) error
+}
+
+// PasswordPepper mixes a versioned server-side secret into passwords before hashing, see password.Peppers
+type PasswordPepper interface {
+	// Current returns the version used for new hashes
+	Current() int
+	Apply(version int, password string) (string, error)
+}
+
 // New возвращает новый инстанс сервиса Auth
 func New(
 	logger *slog.Logger,
 	userSaver UserSaver,
 	UserProvider UserProvider,
 	AppProvider AppProvider,
+	sessions SessionStore,
+	auditSink AuditSink,
+	auditProvider AuditProvider,
+	passwords PasswordPolicy,
+	hasher PasswordHasher,
+	pepper PasswordPepper,
 	tokenTTL time.Duration,
+	constantTimeLogin bool,
 ) *Auth {
 	return &Auth{
-		logger:      logger,
-		usrSaver:    userSaver,
-		usrProvider: UserProvider,
-		appProvider: AppProvider,
-		tokenTTL:    tokenTTL,
+		logger:        logger,
+		usrSaver:      userSaver,
+		usrProvider:   UserProvider,
+		appProvider:   AppProvider,
+		sessions:      sessions,
+		auditSink:     auditSink,
+		auditProvider: auditProvider,
+		passwords:     passwords,
+		hasher:        hasher,
+		pepper:        pepper,
+		tokenTTL:      tokenTTL,
+
+		constantTimeLogin: constantTimeLogin,
 	}
 }
 
+// log returns the request-scoped logger from ctx, see logctx
+func (a *Auth) log(ctx context.Context) *slog.Logger {
+	return logctx.From(ctx, a.logger)
+}
+
 // Login checks if user with given credentials exists in the system.
 //
-// If user exists, but password is incorrect, returns error.
-// If users doesn't exist, returns error.
+// If user exists, but password is incorrect, returns ErrInvalidCredentials.
+// If users doesn't exist, returns error; in constant-time mode a dummy hash is
+// verified instead and ErrInvalidCredentials is returned as for a wrong password.
+// If user is disabled or deleted, returns ErrUserInactive.
 func (a *Auth) Login(
 	ctx context.Context,
 	email string,
@@ -72,88 +192,258 @@ func (a *Auth) Login(
 	appId int,
 ) (string, error) {
 	const op = "services/auth.Login"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
 
-	logger := a.logger.With(slog.String("operation", op))
+	logger := a.log(ctx).With(slog.String("operation", op))
 	logger.Info("attempting to login")
 
+	event := models.AuditEvent{Type: models.AuditLogin, AppId: appId}
+
+	email, err := emailaddr.Normalize(email)
+	if err != nil {
+		a.recordFailure(ctx, event, "invalid credentials")
+		logger.Info("invalid email")
+		if a.constantTimeLogin {
+			a.verifyDummyHash(password)
+		}
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
 	user, err := a.usrProvider.User(ctx, email)
 	if err != nil {
-		if errors.Is(err, ErrUserNotFound) {
-			a.logger.Warn("user not found", err)
+		a.recordFailure(ctx, event, failureReason(err))
+		if errors.Is(err, storage.ErrUserNotFound) {
+			if a.constantTimeLogin {
+				a.verifyDummyHash(password)
+				logger.Info("user not found")
+				return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+			}
+			logger.Warn("user not found")
 			return "", fmt.Errorf("%s: %w", op, ErrUserNotFound)
 		}
 
-		a.logger.Warn("failed to login", err)
+		logger.Error("failed to get user", slog.String("error", err.Error()))
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
+	event.ActorId, event.SubjectId = user.Id, user.Id
 
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
-		a.logger.Info("invalid credential", err)
+	peppered, err := a.pepper.Apply(user.PepperVersion, password)
+	if err != nil {
+		a.recordFailure(ctx, event, "unknown pepper version")
+		logger.Error("failed to apply pepper", slog.String("error", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.hasher.Verify(user.PassHash, peppered); err != nil {
+		a.recordFailure(ctx, event, "invalid credentials")
+		logger.Info("invalid credentials", slog.String("error", err.Error()))
 		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 	}
 
+	// checked after the password so the status is not revealed to someone who does not know it
+	if user.Status != models.UserStatusActive {
+		a.recordFailure(ctx, event, "user is "+string(user.Status))
+		logger.Warn("user is not active", slog.String("status", string(user.Status)))
+		return "", fmt.Errorf("%s: %w", op, ErrUserInactive)
+	}
+
 	app, err := a.appProvider.App(ctx, appId)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op)
+		if errors.Is(err, storage.ErrAppNotFound) {
+			a.recordFailure(ctx, event, "app not found")
+			logger.Warn("app not found", slog.Int("app_id", appId))
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidAppId)
+		}
+		a.recordFailure(ctx, event, failureReason(err))
+		logger.Error("failed to get app", slog.String("error", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.hasher.NeedsRehash(user.PassHash) || user.PepperVersion != a.pepper.Current() {
+		a.rehash(ctx, user.Id, password)
+	}
+
+	session, err := a.newSession(ctx, user.Id, app.Id)
+	if err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		logger.Error("failed to create session", slog.String("error", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	logger.Info("successfully logged in")
 
-	token, err := jwt.NewToken(user, app, a.tokenTTL)
+	token, err := jwt.NewToken(user, app, session.Id, a.tokenTTL)
 	if err != nil {
-		a.logger.Error("failed to generate token", err)
+		a.recordFailure(ctx, event, "token generation failed")
+		logger.Error("failed to generate token", slog.String("error", err.Error()))
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
+	a.recordSuccess(ctx, event)
 	return token, nil
 }
 
 // RegisterNewUser registers new user in the system and returns user id.
+// Email is stored in the canonical form returned by emailaddr.Normalize.
 // If user with given username exists, returns error.
+// If password does not satisfy the policy, returns ErrWeakPassword wrapping the reason.
 func (a *Auth) RegisterNewUser(
 	ctx context.Context,
 	email,
 	password string,
 ) (int64, error) {
 	const op = "services/auth.RegisterNewUser"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
 
-	logger := a.logger.With(slog.String("operation", op))
+	logger := a.log(ctx).With(slog.String("operation", op))
 	logger.Info("start register new user")
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	event := models.AuditEvent{Type: models.AuditRegister}
+
+	email, err := emailaddr.Normalize(email)
 	if err != nil {
-		logger.Error("failed to generate password hash", err)
+		a.recordFailure(ctx, event, "invalid email")
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidEmail)
+	}
+
+	if err := a.passwords.Validate(password, email); err != nil {
+		if !isPolicyError(err) {
+			// fail closed: a password that could not be checked is not accepted
+			a.recordFailure(ctx, event, failureReason(err))
+			logger.Error("failed to check password", slog.String("error", err.Error()))
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		a.recordFailure(ctx, event, "weak password")
+		logger.Info("password rejected by policy", slog.String("reason", err.Error()))
+		return 0, fmt.Errorf("%s: %w: %w", op, ErrWeakPassword, err)
+	}
+
+	passHash, pepperVersion, err := a.hashPassword(password)
+	if err != nil {
+		logger.Error("failed to generate password hash", slog.String("error", err.Error()))
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	id, err := a.usrSaver.SaveUser(ctx, email, passHash)
+	id, err := a.usrSaver.SaveUser(ctx, email, passHash, pepperVersion)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
-			return 0, fmt.Errorf("%s: %w", op, err)
+			a.recordFailure(ctx, event, "user already exists")
+			logger.Warn("user already exists")
+			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
 		}
-		logger.Error("failed to save new user", err)
+		a.recordFailure(ctx, event, failureReason(err))
+		logger.Error("failed to save new user", slog.String("error", err.Error()))
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	event.ActorId, event.SubjectId = id, id
+	a.recordSuccess(ctx, event)
+
 	logger.Info("user registered successfully")
 	return id, nil
 }
 
 // IsAdmin returns true if user with given userId is admin.
-func (a *Auth) IsAdmin(ctx context.Context, userId int64) (bool, error) {
+// The token is optional; when it is passed, it has to be valid, its session active and its owner active.
+func (a *Auth) IsAdmin(ctx context.Context, token string, userId int64) (bool, error) {
 	const op = "services/auth.IsAdmin"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op))
 
-	logger := a.logger.With(slog.String("operation", op))
+	event := models.AuditEvent{Type: models.AuditIsAdmin, SubjectId: userId}
+
+	if token != "" {
+		caller, _, err := a.caller(ctx, token)
+		if err != nil {
+			a.recordFailure(ctx, event, failureReason(err))
+			logger.Warn("authorization failed", slog.String("error", err.Error()))
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		event.ActorId = caller.Id
+	}
 
 	isAdmin, err := a.usrProvider.IsAdmin(ctx, userId)
 	if err != nil {
-		if errors.Is(err, storage.ErrAppNotFound) {
-			logger.Warn("app not found", err)
-			return false, fmt.Errorf("%s: %w", op, ErrInvalidAppId)
+		a.recordFailure(ctx, event, failureReason(err))
+		if errors.Is(err, storage.ErrUserNotFound) {
+			logger.Warn("user not found")
+			return false, fmt.Errorf("%s: %w", op, ErrUserNotFound)
 		}
-		logger.Error("failed to check if user is admin", err)
+		logger.Error("failed to check if user is admin", slog.String("error", err.Error()))
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
+	a.recordSuccess(ctx, event)
+
 	logger.Info("checked if user is admin", slog.Bool("is_admin", isAdmin))
 	return isAdmin, nil
 }
+
+// isPolicyError reports whether err rejects the password, other errors of PasswordPolicy mean it was not checked
+func isPolicyError(err error) bool {
+	var policyErr *password.PolicyError
+	return errors.As(err, &policyErr)
+}
+
+// hashPassword peppers password with the current pepper and hashes it, returns the hash and the pepper version
+func (a *Auth) hashPassword(password string) ([]byte, int, error) {
+	version := a.pepper.Current()
+
+	peppered, err := a.pepper.Apply(version, password)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	passHash, err := a.hasher.Hash(peppered)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return passHash, version, nil
+}
+
+// verifyDummyHash spends as much time as verifying the password of an existing user
+// whose hash uses the configured algorithm and pepper
+func (a *Auth) verifyDummyHash(password string) {
+	a.dummyHashOnce.Do(func() {
+		hash, _, err := a.hashPassword("dummy password for unknown users")
+		if err != nil {
+			a.logger.Error("failed to generate dummy password hash", slog.String("error", err.Error()))
+			return
+		}
+		a.dummyHash = hash
+	})
+
+	peppered, err := a.pepper.Apply(a.pepper.Current(), password)
+	if err != nil {
+		return
+	}
+	_ = a.hasher.Verify(a.dummyHash, peppered)
+}
+
+// rehash replaces the hash of user's password with a hash using the configured algorithm, parameters and pepper.
+// Errors are only logged since the password has already been verified and login can proceed.
+func (a *Auth) rehash(ctx context.Context, userId int64, password string) {
+	const op = "services/auth.rehash"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op), slog.Int64("user_id", userId))
+
+	passHash, pepperVersion, err := a.hashPassword(password)
+	if err != nil {
+		logger.Error("failed to generate password hash", slog.String("error", err.Error()))
+		return
+	}
+
+	if err := a.usrSaver.UpdatePassHash(ctx, userId, passHash, pepperVersion); err != nil {
+		logger.Error("failed to update password hash", slog.String("error", err.Error()))
+		return
+	}
+
+	logger.Info("password hash upgraded")
+}
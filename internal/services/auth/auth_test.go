@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/stretchr/testify/require"
+	"usekit-auth/internal/audit"
+	"usekit-auth/internal/lib/password"
+	"usekit-auth/internal/storage"
+	"usekit-auth/internal/storage/sqlite"
+)
+
+const (
+	testAppId    = 1
+	testPassword = "Correct-Horse-7"
+	testTokenTTL = time.Hour
+)
+
+// newTestStorage returns a migrated database in a temporary directory
+func newTestStorage(t *testing.T) *sqlite.Storage {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "auth.db")
+	migrator, err := migrate.New("file://../../../migrations", "sqlite3://"+path)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+	sourceErr, dbErr := migrator.Close()
+	require.NoError(t, sourceErr)
+	require.NoError(t, dbErr)
+
+	st, err := sqlite.New(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = st.Close() })
+
+	return st
+}
+
+// newTestAuth returns the service on st with cheap argon2id parameters and the given PASSWORD_PEPPERS
+func newTestAuth(t *testing.T, st *sqlite.Storage, peppers string) *Auth {
+	t.Helper()
+
+	argon, err := password.NewArgon2id(password.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1})
+	require.NoError(t, err)
+	parsed, err := password.ParsePeppers(peppers)
+	require.NoError(t, err)
+
+	return New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		st,
+		st,
+		st,
+		st,
+		audit.NewStorageSink(st),
+		st,
+		password.Policy{MinLength: 8},
+		password.NewHasher(argon),
+		parsed,
+		testTokenTTL,
+		false,
+	)
+}
+
+func TestLogin_PepperRotation(t *testing.T) {
+	const (
+		key1  = "MDEyMzQ1Njc4OWFiY2RlZg=="
+		key2  = "ZmVkY2JhOTg3NjU0MzIxMA=="
+		email = "rotation@example.com"
+	)
+	ctx := context.Background()
+	st := newTestStorage(t)
+
+	id, err := newTestAuth(t, st, "1:"+key1).RegisterNewUser(ctx, email, testPassword)
+	require.NoError(t, err)
+
+	before, err := st.UserById(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 1, before.PepperVersion)
+
+	rotated := newTestAuth(t, st, "1:"+key1+",2:"+key2)
+
+	// a wrong password must not trigger the rehash
+	_, err = rotated.Login(ctx, email, testPassword+"x", testAppId)
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	unchanged, err := st.UserById(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 1, unchanged.PepperVersion)
+	require.Equal(t, before.PassHash, unchanged.PassHash)
+
+	// the hash of the old version still verifies and is replaced with one of the newest version
+	_, err = rotated.Login(ctx, email, testPassword, testAppId)
+	require.NoError(t, err)
+
+	after, err := st.UserById(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 2, after.PepperVersion)
+	require.NotEqual(t, before.PassHash, after.PassHash)
+
+	// once rehashed, the old version can be removed from the config
+	_, err = newTestAuth(t, st, "2:"+key2).Login(ctx, email, testPassword, testAppId)
+	require.NoError(t, err)
+
+	// and a server that does not know the version can not verify the hash
+	_, err = newTestAuth(t, st, "1:"+key1).Login(ctx, email, testPassword, testAppId)
+	require.ErrorIs(t, err, password.ErrUnknownPepper)
+}
+
+func TestLogin_PepperAddedToUnpepperedHash(t *testing.T) {
+	const (
+		key   = "MDEyMzQ1Njc4OWFiY2RlZg=="
+		email = "unpeppered@example.com"
+	)
+	ctx := context.Background()
+	st := newTestStorage(t)
+
+	id, err := newTestAuth(t, st, "").RegisterNewUser(ctx, email, testPassword)
+	require.NoError(t, err)
+
+	_, err = newTestAuth(t, st, "1:"+key).Login(ctx, email, testPassword, testAppId)
+	require.NoError(t, err)
+
+	user, err := st.UserById(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 1, user.PepperVersion)
+
+	// the hash depends on the pepper now
+	_, err = newTestAuth(t, st, "").Login(ctx, email, testPassword, testAppId)
+	require.ErrorIs(t, err, password.ErrUnknownPepper)
+}
+
+// a password that could not be checked against the breached list is not accepted
+func TestRegisterNewUser_BreachedListUnreadable(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStorage(t)
+	a := newTestAuth(t, st, "")
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(path, []byte("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\n"), 0o600))
+	list, err := password.LoadBreachedList(path)
+	require.NoError(t, err)
+	require.NoError(t, list.Close())
+	a.passwords = password.Policy{MinLength: 8, Breached: list}
+
+	_, err = a.RegisterNewUser(ctx, "user@example.com", testPassword)
+	require.ErrorIs(t, err, os.ErrClosed)
+	require.NotErrorIs(t, err, ErrWeakPassword)
+
+	_, err = st.User(ctx, "user@example.com")
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+// countingHasher counts calls of Verify
+type countingHasher struct {
+	PasswordHasher
+	verified int
+}
+
+func (h *countingHasher) Verify(hash []byte, password string) error {
+	h.verified++
+	return h.PasswordHasher.Verify(hash, password)
+}
+
+func TestLogin_ConstantTime(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStorage(t)
+	a := newTestAuth(t, st, "")
+	a.constantTimeLogin = true
+
+	_, err := a.RegisterNewUser(ctx, "user@example.com", testPassword)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{name: "unknown user", email: "nobody@example.com", password: testPassword},
+		{name: "wrong password", email: "user@example.com", password: "Wrong-Horse-7"},
+		{name: "invalid email", email: "not an email", password: testPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher := &countingHasher{PasswordHasher: a.hasher}
+			a.hasher = hasher
+			t.Cleanup(func() { a.hasher = hasher.PasswordHasher })
+
+			_, err := a.Login(ctx, tt.email, tt.password, testAppId)
+			require.ErrorIs(t, err, ErrInvalidCredentials)
+			require.NotErrorIs(t, err, ErrUserNotFound)
+			require.EqualError(t, err, "services/auth.Login: invalid credentials")
+			// a hash is verified whether the user exists or not
+			require.Equal(t, 1, hasher.verified)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/storage"
+)
+
+const (
+	erasureBatchSize    = 100
+	exportAuditPageSize = 500
+)
+
+// UserDataExport is the archive returned by ExportUserData
+type UserDataExport struct {
+	ExportedAt  time.Time           `json:"exported_at"`
+	User        models.User         `json:"user"`
+	Sessions    []models.Session    `json:"sessions"`
+	AuditEvents []models.AuditEvent `json:"audit_events"`
+}
+
+// EraseUsers anonymizes users whose deletion was requested before requestedBefore
+// and returns the number of erased users.
+func (a *Auth) EraseUsers(ctx context.Context, requestedBefore time.Time) (int, error) {
+	const op = "services/auth.EraseUsers"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op))
+
+	erased := 0
+	for {
+		ids, err := a.usrProvider.UsersPendingErasure(ctx, requestedBefore, erasureBatchSize)
+		if err != nil {
+			logger.Error("failed to find users pending erasure", slog.String("error", err.Error()))
+			return erased, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, id := range ids {
+			if err := a.usrSaver.EraseUser(ctx, id); err != nil {
+				// the deletion was cancelled in the meantime
+				if errors.Is(err, storage.ErrUserNotFound) {
+					continue
+				}
+				logger.Error("failed to erase user", slog.Int64("user_id", id), slog.String("error", err.Error()))
+				return erased, fmt.Errorf("%s: %w", op, err)
+			}
+			erased++
+			a.recordSuccess(ctx, models.AuditEvent{Type: models.AuditUserErased, SubjectId: id})
+			logger.Info("user erased", slog.Int64("user_id", id))
+		}
+
+		if len(ids) < erasureBatchSize {
+			return erased, nil
+		}
+	}
+}
+
+// ExportUserData returns a JSON archive of everything stored about user with given userId.
+// Users may export their own data, admins may export data of any user.
+func (a *Auth) ExportUserData(ctx context.Context, token string, userId int64) ([]byte, error) {
+	const op = "services/auth.ExportUserData"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op), slog.Int64("user_id", userId))
+
+	event := models.AuditEvent{Type: models.AuditUserDataExported, SubjectId: userId}
+
+	caller, err := a.authorizeSelfOrAdmin(ctx, token, userId)
+	if err != nil {
+		event.ActorId = caller.Id
+		a.recordFailure(ctx, event, failureReason(err))
+		logger.Warn("authorization failed", slog.Int64("caller_id", caller.Id), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	event.ActorId = caller.Id
+
+	user, err := a.usrProvider.UserById(ctx, userId)
+	if err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		logger.Error("failed to get user", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessions, err := a.sessions.UserSessions(ctx, userId, time.Now(), true)
+	if err != nil {
+		logger.Error("failed to get sessions", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	export := UserDataExport{
+		ExportedAt:  time.Now().UTC(),
+		User:        withoutSecrets(user),
+		Sessions:    append([]models.Session{}, sessions...),
+		AuditEvents: []models.AuditEvent{},
+	}
+
+	var beforeId int64
+	for {
+		events, err := a.auditProvider.AuditEvents(ctx, models.AuditFilter{UserId: userId}, beforeId, exportAuditPageSize)
+		if err != nil {
+			logger.Error("failed to get audit events", slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		export.AuditEvents = append(export.AuditEvents, events...)
+		if len(events) < exportAuditPageSize {
+			break
+		}
+		beforeId = events[len(events)-1].Id
+	}
+
+	archive, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.recordSuccess(ctx, event)
+
+	logger.Info("user data exported", slog.Int64("caller_id", caller.Id))
+	return archive, nil
+}
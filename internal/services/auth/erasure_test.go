@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"usekit-auth/internal/audit"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/lib/clientinfo"
+)
+
+func TestEraseUsers_ScrubsClients(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStorage(t)
+	a := newTestAuth(t, st, "")
+
+	userCtx := clientinfo.With(ctx, clientinfo.Info{IP: "192.0.2.1", UserAgent: "user-agent"})
+	adminCtx := clientinfo.With(ctx, clientinfo.Info{IP: "198.51.100.1", UserAgent: "admin-agent"})
+
+	userId, err := a.RegisterNewUser(userCtx, "user@example.com", testPassword)
+	require.NoError(t, err)
+	_, err = a.Login(userCtx, "user@example.com", testPassword, testAppId)
+	require.NoError(t, err)
+	_, err = a.Login(userCtx, "user@example.com", "Wrong-Horse-7", testAppId)
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	adminId, err := a.RegisterNewUser(adminCtx, "admin@example.com", testPassword)
+	require.NoError(t, err)
+	require.NoError(t, a.SetAdmin(ctx, adminId, true))
+	adminToken, err := a.Login(adminCtx, "admin@example.com", testPassword, testAppId)
+	require.NoError(t, err)
+	require.NoError(t, a.DeleteUser(adminCtx, adminToken, userId))
+
+	erased, err := a.EraseUsers(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, erased)
+
+	events, err := st.AuditEventsAfter(ctx, 0, 100)
+	require.NoError(t, err)
+
+	var scrubbed, kept int
+	for _, event := range events {
+		// the chain still verifies without the erased clients
+		require.Equal(t, event.Hash, audit.ChainHash(event.PrevHash, event), "event %d", event.Id)
+
+		switch event.ActorId {
+		case userId:
+			require.NotEmpty(t, event.ClientDigest, "event %d", event.Id)
+			require.Empty(t, event.IP, "event %d", event.Id)
+			require.Empty(t, event.UserAgent, "event %d", event.Id)
+			require.Nil(t, event.ClientSalt, "event %d", event.Id)
+			scrubbed++
+		case adminId:
+			// the admin deleting the user is not erased with them
+			require.Equal(t, "198.51.100.1", event.IP, "event %d", event.Id)
+			require.Equal(t, event.ClientDigest, audit.ClientDigest(event.ClientSalt, event.IP, event.UserAgent))
+			kept++
+		}
+	}
+	require.Equal(t, 3, scrubbed)
+	require.Positive(t, kept)
+
+	sessions, err := st.UserSessions(ctx, userId, time.Now(), true)
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+}
+
+func TestPruneSessions(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStorage(t)
+	a := newTestAuth(t, st, "")
+
+	now := time.Now()
+	session := func(id string, expiresAt time.Time) models.Session {
+		return models.Session{Id: id, UserId: 1, AppId: testAppId, CreatedAt: now.Add(-48 * time.Hour),
+			LastSeenAt: now.Add(-48 * time.Hour), ExpiresAt: expiresAt}
+	}
+	require.NoError(t, st.SaveSession(ctx, session("expired-long-ago", now.Add(-25*time.Hour))))
+	require.NoError(t, st.SaveSession(ctx, session("expired-recently", now.Add(-time.Hour))))
+	require.NoError(t, st.SaveSession(ctx, session("revoked-long-ago", now.Add(time.Hour))))
+	require.NoError(t, st.SaveSession(ctx, session("revoked-recently", now.Add(time.Hour))))
+	require.NoError(t, st.SaveSession(ctx, session("active", now.Add(time.Hour))))
+	require.NoError(t, st.RevokeSession(ctx, 1, "revoked-long-ago", now.Add(-25*time.Hour)))
+	require.NoError(t, st.RevokeSession(ctx, 1, "revoked-recently", now.Add(-time.Hour)))
+
+	pruned, err := a.PruneSessions(ctx, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), pruned)
+
+	sessions, err := st.UserSessions(ctx, 1, now, true)
+	require.NoError(t, err)
+	var ids []string
+	for _, session := range sessions {
+		ids = append(ids, session.Id)
+	}
+	require.ElementsMatch(t, []string{"expired-recently", "revoked-recently", "active"}, ids)
+}
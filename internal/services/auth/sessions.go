@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/lib/clientinfo"
+	"usekit-auth/internal/storage"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// lastSeenResolution limits how often validation writes last_seen_at of a session
+const lastSeenResolution = time.Minute
+
+// newSession stores a session for a login of user into app, it expires together with the token
+func (a *Auth) newSession(ctx context.Context, userId int64, appId int) (models.Session, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return models.Session{}, err
+	}
+
+	now := time.Now()
+	info := clientinfo.From(ctx)
+	session := models.Session{
+		Id:         base64.RawURLEncoding.EncodeToString(id),
+		UserId:     userId,
+		AppId:      appId,
+		Device:     info.Device,
+		IP:         info.IP,
+		UserAgent:  info.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(a.tokenTTL),
+	}
+
+	if err := a.sessions.SaveSession(ctx, session); err != nil {
+		return models.Session{}, err
+	}
+	return session, nil
+}
+
+// checkSession makes sure the session of a token is still active and records that it was used.
+func (a *Auth) checkSession(ctx context.Context, userId int64, sessionId string) error {
+	if sessionId == "" {
+		return errors.New("token is not bound to a session")
+	}
+
+	session, err := a.sessions.Session(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if session.UserId != userId {
+		return errors.New("session belongs to another user")
+	}
+	if !session.IsActive(now) {
+		return errors.New("session is revoked or expired")
+	}
+
+	if now.Sub(session.LastSeenAt) >= lastSeenResolution {
+		if err := a.sessions.TouchSession(ctx, sessionId, now); err != nil {
+			a.log(ctx).Warn("failed to update session last seen time", slog.String("error", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+// ListSessions returns active sessions of the token owner, the session of the token is marked as current.
+func (a *Auth) ListSessions(ctx context.Context, token string) ([]models.Session, error) {
+	const op = "services/auth.ListSessions"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op))
+
+	user, claims, err := a.caller(ctx, token)
+	if err != nil {
+		logger.Warn("authorization failed", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessions, err := a.sessions.UserSessions(ctx, user.Id, time.Now(), false)
+	if err != nil {
+		logger.Error("failed to list sessions", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == claims.SessionId
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revokes a session of the token owner. Tokens of the session stop being accepted immediately.
+func (a *Auth) RevokeSession(ctx context.Context, token string, sessionId string) error {
+	const op = "services/auth.RevokeSession"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op))
+
+	event := models.AuditEvent{Type: models.AuditSessionRevoked}
+
+	user, _, err := a.caller(ctx, token)
+	if err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		logger.Warn("authorization failed", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	event.ActorId, event.SubjectId = user.Id, user.Id
+
+	if err := a.sessions.RevokeSession(ctx, user.Id, sessionId, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			a.recordFailure(ctx, event, "session not found")
+			return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+		}
+		a.recordFailure(ctx, event, failureReason(err))
+		logger.Error("failed to revoke session", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.recordSuccess(ctx, event)
+	return nil
+}
+
+// RevokeAllOtherSessions revokes every session of the token owner except the one of the token
+// and returns the number of revoked sessions.
+func (a *Auth) RevokeAllOtherSessions(ctx context.Context, token string) (int64, error) {
+	const op = "services/auth.RevokeAllOtherSessions"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op))
+
+	event := models.AuditEvent{Type: models.AuditSessionRevoked}
+
+	user, claims, err := a.caller(ctx, token)
+	if err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		logger.Warn("authorization failed", slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	event.ActorId, event.SubjectId = user.Id, user.Id
+
+	revoked, err := a.sessions.RevokeOtherSessions(ctx, user.Id, claims.SessionId, time.Now())
+	if err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		logger.Error("failed to revoke sessions", slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.recordSuccess(ctx, event)
+	logger.Info("sessions revoked", slog.Int64("count", revoked))
+	return revoked, nil
+}
+
+// PruneSessions deletes sessions that expired or were revoked before endedBefore,
+// they hold addresses and user agents and are of no use once ended.
+func (a *Auth) PruneSessions(ctx context.Context, endedBefore time.Time) (int64, error) {
+	const op = "services/auth.PruneSessions"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	pruned, err := a.sessions.PruneSessions(ctx, endedBefore)
+	if err != nil {
+		a.log(ctx).Error("failed to prune sessions", slog.String("operation", op), slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pruned, nil
+}
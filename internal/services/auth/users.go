@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/text/language"
+	"log/slog"
+	"net/url"
+	"slices"
+	"time"
+	"unicode"
+	"unicode/utf8"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/lib/emailaddr"
+	"usekit-auth/internal/storage"
+)
+
+const (
+	maxDisplayNameLen = 100
+	maxAvatarURLLen   = 2048
+	maxMetadataSize   = 16 << 10
+)
+
+// GetUser returns user with given userId. Users may get themselves, admins may get any user.
+// PassHash is never filled in the returned user.
+func (a *Auth) GetUser(ctx context.Context, token string, userId int64) (models.User, error) {
+	const op = "services/auth.GetUser"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op), slog.Int64("user_id", userId))
+
+	caller, err := a.authorizeSelfOrAdmin(ctx, token, userId)
+	if err != nil {
+		logger.Warn("authorization failed", slog.Int64("caller_id", caller.Id), slog.String("error", err.Error()))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.usrProvider.UserById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			logger.Warn("user not found")
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		logger.Error("failed to get user", slog.String("error", err.Error()))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return withoutSecrets(user), nil
+}
+
+// GetUserByEmail returns user with given email. Users may get themselves, admins may get any user.
+// PassHash is never filled in the returned user.
+func (a *Auth) GetUserByEmail(ctx context.Context, token string, email string) (models.User, error) {
+	const op = "services/auth.GetUserByEmail"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op))
+
+	caller, _, err := a.caller(ctx, token)
+	if err != nil {
+		logger.Warn("authorization failed", slog.String("error", err.Error()))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	email, err = emailaddr.Normalize(email)
+	// the email is compared before the lookup so that users can not probe which emails are registered
+	if !caller.IsAdmin && (err != nil || email != caller.Email) {
+		logger.Warn("lookup of another user denied", slog.Int64("caller_id", caller.Id))
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+
+	user, err := a.usrProvider.User(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			logger.Warn("user not found")
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		logger.Error("failed to get user", slog.String("error", err.Error()))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return withoutSecrets(user), nil
+}
+
+// UpdateUser updates profile fields of user with given userId listed in paths.
+// Empty paths means every profile field is replaced. Users may update themselves, admins may update any user.
+func (a *Auth) UpdateUser(
+	ctx context.Context,
+	token string,
+	userId int64,
+	profile models.UserProfile,
+	paths []string,
+) (models.User, error) {
+	const op = "services/auth.UpdateUser"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op), slog.Int64("user_id", userId))
+
+	event := models.AuditEvent{Type: models.AuditUserUpdated, SubjectId: userId}
+
+	caller, err := a.authorizeSelfOrAdmin(ctx, token, userId)
+	event.ActorId = caller.Id
+	if err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		logger.Warn("authorization failed", slog.Int64("caller_id", caller.Id), slog.String("error", err.Error()))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	paths, err = normalizeProfilePaths(paths)
+	if err != nil {
+		logger.Info("invalid field mask", slog.String("error", err.Error()))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	profile, err = normalizeProfile(profile, paths)
+	if err != nil {
+		logger.Info("invalid profile", slog.String("error", err.Error()))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.usrSaver.UpdateUser(ctx, userId, profile, paths)
+	if err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		if errors.Is(err, storage.ErrUserNotFound) {
+			logger.Warn("user not found")
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		logger.Error("failed to update user", slog.String("error", err.Error()))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.recordSuccess(ctx, event)
+
+	logger.Info("user updated", slog.Any("paths", paths))
+	return withoutSecrets(user), nil
+}
+
+// ImportUser saves a user migrated from another system with the password hash produced there.
+// The hash is stored as is without a pepper, it has to be a bcrypt or argon2id hash,
+// and is replaced by a hash of the configured algorithm on the first successful login.
+// The password policy is not applied since the password is unknown.
+func (a *Auth) ImportUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+	const op = "services/auth.ImportUser"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	logger := a.log(ctx).With(slog.String("operation", op))
+
+	event := models.AuditEvent{Type: models.AuditUserImported}
+
+	email, err := emailaddr.Normalize(email)
+	if err != nil {
+		a.recordFailure(ctx, event, "invalid email")
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidEmail)
+	}
+
+	if err := a.hasher.Validate(passHash); err != nil {
+		a.recordFailure(ctx, event, "invalid password hash")
+		return 0, fmt.Errorf("%s: %w: %w", op, ErrInvalidPassHash, err)
+	}
+
+	id, err := a.usrSaver.SaveUser(ctx, email, passHash, 0)
+	if err != nil {
+		a.recordFailure(ctx, event, failureReason(err))
+		if errors.Is(err, storage.ErrUserExists) {
+			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
+		}
+		logger.Error("failed to save imported user", slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.SubjectId = id
+	a.recordSuccess(ctx, event)
+
+	return id, nil
+}
+
+func withoutSecrets(user models.User) models.User {
+	user.PassHash = nil
+	return user
+}
+
+// normalizeProfilePaths validates a field mask and removes duplicates.
+func normalizeProfilePaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return slices.Clone(models.ProfilePaths), nil
+	}
+
+	normalized := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !slices.Contains(models.ProfilePaths, path) {
+			return nil, fmt.Errorf("%w: unknown path %q", ErrInvalidFieldMask, path)
+		}
+		if !slices.Contains(normalized, path) {
+			normalized = append(normalized, path)
+		}
+	}
+
+	return normalized, nil
+}
+
+// normalizeProfile validates the fields listed in paths and brings them to canonical form.
+func normalizeProfile(profile models.UserProfile, paths []string) (models.UserProfile, error) {
+	for _, path := range paths {
+		switch path {
+		case models.ProfileDisplayName:
+			if utf8.RuneCountInString(profile.DisplayName) > maxDisplayNameLen {
+				return profile, fmt.Errorf("%w: display_name is longer than %d characters", ErrInvalidProfile, maxDisplayNameLen)
+			}
+			if !utf8.ValidString(profile.DisplayName) || containsControl(profile.DisplayName) {
+				return profile, fmt.Errorf("%w: display_name contains invalid characters", ErrInvalidProfile)
+			}
+		case models.ProfileLocale:
+			if profile.Locale == "" {
+				continue
+			}
+			tag, err := language.Parse(profile.Locale)
+			if err != nil {
+				return profile, fmt.Errorf("%w: locale %q is not a BCP 47 tag", ErrInvalidProfile, profile.Locale)
+			}
+			profile.Locale = tag.String()
+		case models.ProfileTimezone:
+			if profile.Timezone == "" {
+				continue
+			}
+			if _, err := time.LoadLocation(profile.Timezone); err != nil || profile.Timezone == "Local" {
+				return profile, fmt.Errorf("%w: timezone %q is not an IANA time zone", ErrInvalidProfile, profile.Timezone)
+			}
+		case models.ProfileAvatarURL:
+			if profile.AvatarURL == "" {
+				continue
+			}
+			u, err := url.Parse(profile.AvatarURL)
+			if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || len(profile.AvatarURL) > maxAvatarURLLen {
+				return profile, fmt.Errorf("%w: avatar_url must be an absolute http(s) URL", ErrInvalidProfile)
+			}
+		case models.ProfileMetadata:
+			if len(profile.Metadata) == 0 {
+				profile.Metadata = json.RawMessage(`{}`)
+				continue
+			}
+			if len(profile.Metadata) > maxMetadataSize {
+				return profile, fmt.Errorf("%w: metadata is larger than %d bytes", ErrInvalidProfile, maxMetadataSize)
+			}
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(profile.Metadata, &object); err != nil || object == nil {
+				return profile, fmt.Errorf("%w: metadata must be a JSON object", ErrInvalidProfile)
+			}
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, profile.Metadata); err != nil {
+				return profile, fmt.Errorf("%w: metadata must be a JSON object", ErrInvalidProfile)
+			}
+			profile.Metadata = compact.Bytes()
+		}
+	}
+
+	return profile, nil
+}
+
+func containsControl(s string) bool {
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}
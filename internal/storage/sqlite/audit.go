@@ -0,0 +1,350 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"usekit-auth/internal/audit"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/storage"
+)
+
+// auditEventColumns are selected from auditEventsTable, the client of events written since
+// migration 14 is kept in audit_event_clients and is missing once the user is erased
+const auditEventColumns = `e.id, e.type, e.outcome, e.actor_id, e.subject_id, e.app_id,
+	COALESCE(c.ip, e.ip), COALESCE(c.user_agent, e.user_agent), e.reason, e.created_at,
+	e.prev_hash, e.hash, e.client_digest, c.salt`
+
+const auditEventsTable = `audit_events e LEFT JOIN audit_event_clients c ON c.event_id = e.id`
+
+// clientSaltSize is the size of the random salt of audit.ClientDigest
+const clientSaltSize = 16
+
+// SaveAuditEvent appends event to the audit log and returns its id.
+// The event is linked to the previous one with audit.ChainHash; the write
+// transaction is taken up front so concurrent writers cannot fork the chain.
+// IP and UserAgent are stored in audit_event_clients so that EraseUser can delete them.
+func (s *Storage) SaveAuditEvent(ctx context.Context, event models.AuditEvent) (int64, error) {
+	const op = "storage.sqlite.SaveAuditEvent"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_, _ = conn.ExecContext(context.WithoutCancel(ctx), `ROLLBACK`)
+		}
+	}()
+
+	var prevId int64
+	var prevHash string
+	err = conn.QueryRowContext(ctx, `SELECT id, hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&prevId, &prevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Id = prevId + 1
+	event.CreatedAt = event.CreatedAt.UTC().Truncate(time.Second)
+	event.PrevHash = prevHash
+	if event.IP != "" || event.UserAgent != "" {
+		event.ClientSalt = make([]byte, clientSaltSize)
+		if _, err := rand.Read(event.ClientSalt); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		event.ClientDigest = audit.ClientDigest(event.ClientSalt, event.IP, event.UserAgent)
+	}
+	event.Hash = audit.ChainHash(prevHash, event)
+
+	_, err = conn.ExecContext(ctx, `INSERT INTO audit_events
+		(id, type, outcome, actor_id, subject_id, app_id, reason, created_at, prev_hash, hash, client_digest)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.Id,
+		event.Type,
+		event.Outcome,
+		event.ActorId,
+		event.SubjectId,
+		event.AppId,
+		event.Reason,
+		event.CreatedAt.Format(timestampLayout),
+		event.PrevHash,
+		event.Hash,
+		event.ClientDigest,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if event.ClientDigest != "" {
+		_, err = conn.ExecContext(ctx, `INSERT INTO audit_event_clients
+			(event_id, actor_id, subject_id, ip, user_agent, salt) VALUES (?, ?, ?, ?, ?, ?)`,
+			event.Id,
+			event.ActorId,
+			event.SubjectId,
+			event.IP,
+			event.UserAgent,
+			event.ClientSalt,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	committed = true
+
+	return event.Id, nil
+}
+
+// AuditEvents returns up to limit events matching filter from the newest to the oldest.
+// If beforeId is not zero, only events with smaller ids are returned.
+func (s *Storage) AuditEvents(
+	ctx context.Context,
+	filter models.AuditFilter,
+	beforeId int64,
+	limit int,
+) ([]models.AuditEvent, error) {
+	const op = "storage.sqlite.AuditEvents"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	var where []string
+	var args []any
+	if filter.Type != "" {
+		where = append(where, "e.type = ?")
+		args = append(args, filter.Type)
+	}
+	if filter.Outcome != "" {
+		where = append(where, "e.outcome = ?")
+		args = append(args, filter.Outcome)
+	}
+	if filter.ActorId != 0 {
+		where = append(where, "e.actor_id = ?")
+		args = append(args, filter.ActorId)
+	}
+	if filter.SubjectId != 0 {
+		where = append(where, "e.subject_id = ?")
+		args = append(args, filter.SubjectId)
+	}
+	if filter.UserId != 0 {
+		where = append(where, "(e.actor_id = ? OR e.subject_id = ?)")
+		args = append(args, filter.UserId, filter.UserId)
+	}
+	if filter.AppId != 0 {
+		where = append(where, "e.app_id = ?")
+		args = append(args, filter.AppId)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "e.created_at >= ?")
+		args = append(args, filter.Since.UTC().Format(timestampLayout))
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "e.created_at < ?")
+		args = append(args, filter.Until.UTC().Format(timestampLayout))
+	}
+	if beforeId != 0 {
+		where = append(where, "e.id < ?")
+		args = append(args, beforeId)
+	}
+
+	query := `SELECT ` + auditEventColumns + ` FROM ` + auditEventsTable
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY e.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	events, err := scanAuditEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// AuditEventsAfter returns up to limit events with ids greater than afterId in chain order
+func (s *Storage) AuditEventsAfter(ctx context.Context, afterId int64, limit int) ([]models.AuditEvent, error) {
+	const op = "storage.sqlite.AuditEventsAfter"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+auditEventColumns+` FROM `+auditEventsTable+` WHERE e.id > ? ORDER BY e.id LIMIT ?`,
+		afterId, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	events, err := scanAuditEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// LastAuditEvent returns the head of the hash chain
+func (s *Storage) LastAuditEvent(ctx context.Context) (models.AuditEvent, error) {
+	const op = "storage.sqlite.LastAuditEvent"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+auditEventColumns+` FROM `+auditEventsTable+` WHERE e.hash != '' ORDER BY e.id DESC LIMIT 1`,
+	)
+	if err != nil {
+		return models.AuditEvent{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	events, err := scanAuditEvents(rows)
+	if err != nil {
+		return models.AuditEvent{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(events) == 0 {
+		return models.AuditEvent{}, fmt.Errorf("%s: %w", op, storage.ErrAuditEventNotFound)
+	}
+
+	return events[0], nil
+}
+
+// SaveAuditCheckpoint appends a signed checkpoint and returns its id
+func (s *Storage) SaveAuditCheckpoint(ctx context.Context, checkpoint models.AuditCheckpoint) (int64, error) {
+	const op = "storage.sqlite.SaveAuditCheckpoint"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO audit_checkpoints (event_id, hash, signature, created_at) VALUES (?, ?, ?, ?)`,
+		checkpoint.EventId,
+		checkpoint.Hash,
+		checkpoint.Signature,
+		checkpoint.CreatedAt.UTC().Format(timestampLayout),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+// LastAuditCheckpoint returns the most recent checkpoint
+func (s *Storage) LastAuditCheckpoint(ctx context.Context) (models.AuditCheckpoint, error) {
+	const op = "storage.sqlite.LastAuditCheckpoint"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	checkpoints, err := s.auditCheckpoints(ctx, `ORDER BY id DESC LIMIT 1`)
+	if err != nil {
+		return models.AuditCheckpoint{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(checkpoints) == 0 {
+		return models.AuditCheckpoint{}, fmt.Errorf("%s: %w", op, storage.ErrCheckpointNotFound)
+	}
+
+	return checkpoints[0], nil
+}
+
+// AuditCheckpoints returns all checkpoints in the order they were made
+func (s *Storage) AuditCheckpoints(ctx context.Context) ([]models.AuditCheckpoint, error) {
+	const op = "storage.sqlite.AuditCheckpoints"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	checkpoints, err := s.auditCheckpoints(ctx, `ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return checkpoints, nil
+}
+
+func (s *Storage) auditCheckpoints(ctx context.Context, orderAndLimit string) ([]models.AuditCheckpoint, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, event_id, hash, signature, created_at FROM audit_checkpoints `+orderAndLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []models.AuditCheckpoint
+	for rows.Next() {
+		var checkpoint models.AuditCheckpoint
+		err := rows.Scan(
+			&checkpoint.Id,
+			&checkpoint.EventId,
+			&checkpoint.Hash,
+			&checkpoint.Signature,
+			&checkpoint.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	return checkpoints, rows.Err()
+}
+
+func scanAuditEvents(rows *sql.Rows) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		err := rows.Scan(
+			&event.Id,
+			&event.Type,
+			&event.Outcome,
+			&event.ActorId,
+			&event.SubjectId,
+			&event.AppId,
+			&event.IP,
+			&event.UserAgent,
+			&event.Reason,
+			&event.CreatedAt,
+			&event.PrevHash,
+			&event.Hash,
+			&event.ClientDigest,
+			&event.ClientSalt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
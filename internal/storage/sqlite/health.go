@@ -0,0 +1,47 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"usekit-auth/internal/storage"
+)
+
+// SchemaVersion is the version of the last migration in ./migrations the code relies on,
+// it has to be bumped together with every new migration
+const SchemaVersion = 13
+
+// migrationsTable is the table golang-migrate keeps the schema version in, see cmd/migrator
+const migrationsTable = "migrations"
+
+// Ready checks that the database is reachable and migrated at least to SchemaVersion,
+// returns storage.ErrNotMigrated if it is not
+func (s *Storage) Ready(ctx context.Context) error {
+	const op = "storage.sqlite.Ready"
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var version int64
+	var dirty bool
+	err := s.db.QueryRowContext(ctx, `SELECT version, dirty FROM `+migrationsTable+` LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		// the table is created by the first migration run
+		if errors.Is(err, sql.ErrNoRows) || strings.Contains(err.Error(), "no such table") {
+			return fmt.Errorf("%s: %w: no migrations applied", op, storage.ErrNotMigrated)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if dirty {
+		return fmt.Errorf("%s: %w: version %d is dirty", op, storage.ErrNotMigrated, version)
+	}
+	if version < SchemaVersion {
+		return fmt.Errorf("%s: %w: version %d, expected %d", op, storage.ErrNotMigrated, version, SchemaVersion)
+	}
+
+	return nil
+}
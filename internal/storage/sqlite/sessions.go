@@ -0,0 +1,209 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"usekit-auth/internal/domain/models"
+	"usekit-auth/internal/storage"
+)
+
+const sessionColumns = `id, user_id, app_id, device, ip, user_agent, created_at, last_seen_at, expires_at, revoked_at`
+
+// SaveSession stores a new session
+func (s *Storage) SaveSession(ctx context.Context, session models.Session) error {
+	const op = "storage.sqlite.SaveSession"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO sessions
+		(id, user_id, app_id, device, ip, user_agent, created_at, last_seen_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.Id,
+		session.UserId,
+		session.AppId,
+		session.Device,
+		session.IP,
+		session.UserAgent,
+		session.CreatedAt.UTC().Format(timestampLayout),
+		session.LastSeenAt.UTC().Format(timestampLayout),
+		session.ExpiresAt.UTC().Format(timestampLayout),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Session returns session by id
+func (s *Storage) Session(ctx context.Context, id string) (models.Session, error) {
+	const op = "storage.sqlite.Session"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	sessions, err := scanSessions(rows)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(sessions) == 0 {
+		return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+
+	return sessions[0], nil
+}
+
+// UserSessions returns sessions of user with given id, most recently used first.
+// Unless includeInactive is set, revoked sessions and sessions expired at now are skipped.
+func (s *Storage) UserSessions(ctx context.Context, userId int64, now time.Time, includeInactive bool) ([]models.Session, error) {
+	const op = "storage.sqlite.UserSessions"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE user_id = ?`
+	args := []any{userId}
+	if !includeInactive {
+		query += ` AND revoked_at IS NULL AND expires_at > ?`
+		args = append(args, now.UTC().Format(timestampLayout))
+	}
+	query += ` ORDER BY last_seen_at DESC, created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	sessions, err := scanSessions(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// TouchSession moves last_seen_at of the session forward to seenAt
+func (s *Storage) TouchSession(ctx context.Context, id string, seenAt time.Time) error {
+	const op = "storage.sqlite.TouchSession"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	seen := seenAt.UTC().Format(timestampLayout)
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE sessions SET last_seen_at = ? WHERE id = ? AND last_seen_at < ?`,
+		seen, id, seen,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RevokeSession revokes session with given id owned by user with given id
+func (s *Storage) RevokeSession(ctx context.Context, userId int64, id string, revokedAt time.Time) error {
+	const op = "storage.sqlite.RevokeSession"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`,
+		revokedAt.UTC().Format(timestampLayout), id, userId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+	return nil
+}
+
+// RevokeOtherSessions revokes every session of user with given id except keepId
+// and returns the number of revoked sessions
+func (s *Storage) RevokeOtherSessions(ctx context.Context, userId int64, keepId string, revokedAt time.Time) (int64, error) {
+	const op = "storage.sqlite.RevokeOtherSessions"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND id != ? AND revoked_at IS NULL`,
+		revokedAt.UTC().Format(timestampLayout), userId, keepId,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected, nil
+}
+
+// PruneSessions deletes sessions that expired or were revoked before endedBefore
+// and returns the number of deleted sessions
+func (s *Storage) PruneSessions(ctx context.Context, endedBefore time.Time) (int64, error) {
+	const op = "storage.sqlite.PruneSessions"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	ended := endedBefore.UTC().Format(timestampLayout)
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM sessions WHERE expires_at < ? OR revoked_at < ?`,
+		ended, ended,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected, nil
+}
+
+func scanSessions(rows *sql.Rows) ([]models.Session, error) {
+	var sessions []models.Session
+	for rows.Next() {
+		var session models.Session
+		var revokedAt sql.NullTime
+		err := rows.Scan(
+			&session.Id,
+			&session.UserId,
+			&session.AppId,
+			&session.Device,
+			&session.IP,
+			&session.UserAgent,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+			&session.ExpiresAt,
+			&revokedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		session.RevokedAt = revokedAt.Time
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
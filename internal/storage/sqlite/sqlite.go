@@ -9,12 +9,37 @@ import (
 	"fmt"
 	"github.com/mattn/go-sqlite3"
 	_ "github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"slices"
+	"strings"
+	"time"
 	"usekit-auth/internal/domain/models"
 	"usekit-auth/internal/storage"
 )
 
 type Storage struct {
-	db *sql.DB
+	db       *sql.DB
+	observer QueryObserver
+}
+
+var tracer = otel.Tracer("usekit-auth/internal/storage/sqlite")
+
+// QueryObserver receives the duration of every storage call, see metrics.Metrics
+type QueryObserver interface {
+	ObserveQuery(method string, duration time.Duration)
+}
+
+// userColumns is the column list matching scanUser
+const userColumns = `id, email, pass_hash, pepper_version, is_admin, status, display_name, locale, timezone, avatar_url, metadata, created_at, updated_at`
+
+// timestampLayout matches CURRENT_TIMESTAMP so timestamps passed as arguments compare correctly with stored ones
+const timestampLayout = "2006-01-02 15:04:05"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
 }
 
 // New creates a new instance of SQLite storage
@@ -30,17 +55,51 @@ func New(storagePath string) (*Storage, error) {
 	return &Storage{db: db}, nil
 }
 
-func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+// Close closes the database, it waits for queries in progress to finish
+func (s *Storage) Close() error {
+	const op = "storage.sqlite.Close"
+
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SetQueryObserver makes the storage report durations of its calls to observer
+func (s *Storage) SetQueryObserver(observer QueryObserver) {
+	s.observer = observer
+}
+
+// startSpan starts a child span of the storage call, the span is named after op
+func startSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemSqlite),
+	)
+}
+
+func (s *Storage) observe(op string, start time.Time) {
+	if s.observer != nil {
+		s.observer.ObserveQuery(strings.TrimPrefix(op, "storage.sqlite."), time.Since(start))
+	}
+}
+
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte, pepperVersion int) (int64, error) {
 	const op = "storage.sqlite.SaveUser"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
 	//uuid := uuidV4.New().String()
 	//slog.Info("uuid", slog.String("uuid", uuid))
 
-	stmt, err := s.db.Prepare(`INSERT INTO users(email, pass_hash) VALUES(?, ?)`)
+	stmt, err := s.db.PrepareContext(ctx, `INSERT INTO users(email, pass_hash, pepper_version, created_at, updated_at)
+		VALUES(?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
+	defer stmt.Close()
 
-	res, err := stmt.ExecContext(ctx, email, passHash)
+	res, err := stmt.ExecContext(ctx, email, passHash, pepperVersion)
 	if err != nil {
 		var sqliteErr sqlite3.Error
 		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
@@ -56,18 +115,22 @@ func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (
 	return id, nil
 }
 
-// User returns user by email
+// User returns user by email. Emails are compared exactly, callers pass the canonical
+// form returned by emailaddr.Normalize in which all emails are stored
 func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	const op = "storage.sqlite.User"
-	stmt, err := s.db.Prepare(`SELECT id, email, pass_hash FROM users WHERE email = ?`)
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+	stmt, err := s.db.PrepareContext(ctx, `SELECT `+userColumns+` FROM users WHERE email = ?`)
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
+	defer stmt.Close()
 
 	row := stmt.QueryRowContext(ctx, email)
-	var user models.User
 	// получаем результат методом Scan и записываем значения из колонок найденной строки в поля объекта user
-	err = row.Scan(&user.Id, &user.Email, &user.PassHash)
+	user, err := scanUser(row)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -79,13 +142,359 @@ func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	return user, nil
 }
 
+// UserById returns user by id
+func (s *Storage) UserById(ctx context.Context, id int64) (models.User, error) {
+	const op = "storage.sqlite.UserById"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+	stmt, err := s.db.PrepareContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	user, err := scanUser(stmt.QueryRowContext(ctx, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// UpdateUser writes the profile fields listed in paths and returns the updated user.
+// Paths must be taken from models.ProfilePaths, they are used as column names.
+func (s *Storage) UpdateUser(ctx context.Context, id int64, profile models.UserProfile, paths []string) (models.User, error) {
+	const op = "storage.sqlite.UpdateUser"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	values := map[string]any{
+		models.ProfileDisplayName: profile.DisplayName,
+		models.ProfileLocale:      profile.Locale,
+		models.ProfileTimezone:    profile.Timezone,
+		models.ProfileAvatarURL:   profile.AvatarURL,
+		models.ProfileMetadata:    string(profile.Metadata),
+	}
+
+	set := make([]string, 0, len(paths)+1)
+	args := make([]any, 0, len(paths)+1)
+	for _, path := range paths {
+		if !slices.Contains(models.ProfilePaths, path) {
+			return models.User{}, fmt.Errorf("%s: unknown profile field %q", op, path)
+		}
+		set = append(set, path+" = ?")
+		args = append(args, values[path])
+	}
+	set = append(set, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, id)
+
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE users SET `+strings.Join(set, ", ")+` WHERE id = ? RETURNING `+userColumns)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	user, err := scanUser(stmt.QueryRowContext(ctx, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// ListUsers returns up to limit users matching filter, ordered by creation time and id.
+// If cursor is not nil, only users after it in the requested order are returned.
+func (s *Storage) ListUsers(
+	ctx context.Context,
+	filter models.UserFilter,
+	cursor *models.UserCursor,
+	limit int,
+) ([]models.User, error) {
+	const op = "storage.sqlite.ListUsers"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	var where []string
+	var args []any
+	if filter.EmailPrefix != "" {
+		where = append(where, `email LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(filter.EmailPrefix)+"%")
+	}
+	switch filter.Role {
+	case models.RoleAdmin:
+		where = append(where, "is_admin = TRUE")
+	case models.RoleUser:
+		where = append(where, "is_admin = FALSE")
+	}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+
+	order, compare := "ASC", ">"
+	if filter.Descending {
+		order, compare = "DESC", "<"
+	}
+	if cursor != nil {
+		where = append(where, "(created_at, id) "+compare+" (?, ?)")
+		args = append(args, cursor.CreatedAt.UTC().Format(timestampLayout), cursor.Id)
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY created_at ` + order + `, id ` + order + ` LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// SetUserStatus changes status of user with given id.
+// Users that have already been erased are reported as not found.
+func (s *Storage) SetUserStatus(ctx context.Context, id int64, status models.UserStatus) error {
+	const op = "storage.sqlite.SetUserStatus"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE users
+		SET status = ?, deletion_requested_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status != 'deleted'`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, status, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res)
+}
+
+// SetUserAdmin grants or revokes admin rights of user with given id.
+// Users that have already been erased are reported as not found.
+func (s *Storage) SetUserAdmin(ctx context.Context, id int64, isAdmin bool) error {
+	const op = "storage.sqlite.SetUserAdmin"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE users
+		SET is_admin = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status != 'deleted'`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, isAdmin, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res)
+}
+
+// SetUserEmail replaces email of user with given id, returns storage.ErrUserExists
+// if another user has it. Users that have already been erased are reported as not found.
+func (s *Storage) SetUserEmail(ctx context.Context, id int64, email string) error {
+	const op = "storage.sqlite.SetUserEmail"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE users
+		SET email = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status != 'deleted'`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, email, id)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res)
+}
+
+// UpdatePassHash replaces password hash and pepper version of user with given id
+func (s *Storage) UpdatePassHash(ctx context.Context, id int64, passHash []byte, pepperVersion int) error {
+	const op = "storage.sqlite.UpdatePassHash"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE users
+		SET pass_hash = ?, pepper_version = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, passHash, pepperVersion, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res)
+}
+
+// RequestUserDeletion marks user with given id as pending deletion.
+// The row is kept until EraseUser is called after the retention period.
+func (s *Storage) RequestUserDeletion(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.RequestUserDeletion"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE users
+		SET status = 'pending_deletion', deletion_requested_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status NOT IN ('pending_deletion', 'deleted')`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res)
+}
+
+// UsersPendingErasure returns ids of up to limit users whose deletion was requested before given time
+func (s *Storage) UsersPendingErasure(ctx context.Context, requestedBefore time.Time, limit int) ([]int64, error) {
+	const op = "storage.sqlite.UsersPendingErasure"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM users
+		WHERE status = 'pending_deletion' AND deletion_requested_at < ?
+		ORDER BY deletion_requested_at LIMIT ?`,
+		requestedBefore.UTC().Format(timestampLayout), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// EraseUser anonymizes user pending deletion: personal data and the password hash are removed,
+// the email is replaced with a unique placeholder and the row is kept for audit history.
+// Sessions of the user are deleted since they hold addresses and user agents, as well as
+// the clients of audit events the user made; events made by others about the user keep theirs.
+func (s *Storage) EraseUser(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.EraseUser"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE users SET
+		email = 'deleted-' || id || '@erased.invalid',
+		pass_hash = x'',
+		is_admin = FALSE,
+		display_name = '',
+		locale = '',
+		timezone = '',
+		avatar_url = '',
+		metadata = '{}',
+		status = 'deleted',
+		updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'pending_deletion'`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := affectedOne(op, res); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// an event without an actor, such as a failed login, was made by its subject
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM audit_event_clients WHERE actor_id = ? OR (actor_id = 0 AND subject_id = ?)`, id, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
 // IsAdmin returns true if user with given id is admin
 func (s *Storage) IsAdmin(ctx context.Context, UserId int64) (bool, error) {
 	const op = "storage.sqlite.IsAdmin"
-	stmt, err := s.db.Prepare(`SELECT is_admin FROM users WHERE id = ?`)
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+	stmt, err := s.db.PrepareContext(ctx, `SELECT is_admin FROM users WHERE id = ?`)
 	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
+	defer stmt.Close()
 
 	row := stmt.QueryRowContext(ctx, UserId)
 
@@ -103,10 +512,14 @@ func (s *Storage) IsAdmin(ctx context.Context, UserId int64) (bool, error) {
 
 func (s *Storage) App(ctx context.Context, id int) (models.App, error) {
 	const op = "storage.sqlite.App"
-	stmt, err := s.db.Prepare(`SELECT id, name, secret FROM apps WHERE id = ?`)
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, name, secret FROM apps WHERE id = ?`)
 	if err != nil {
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
+	defer stmt.Close()
 
 	row := stmt.QueryRowContext(ctx, id)
 	var app models.App
@@ -119,3 +532,119 @@ func (s *Storage) App(ctx context.Context, id int) (models.App, error) {
 	}
 	return app, nil
 }
+
+// AppByName returns app with given name
+func (s *Storage) AppByName(ctx context.Context, name string) (models.App, error) {
+	const op = "storage.sqlite.AppByName"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	row := s.db.QueryRowContext(ctx, `SELECT id, name, secret FROM apps WHERE name = ?`, name)
+
+	var app models.App
+	if err := row.Scan(&app.Id, &app.Name, &app.Secret); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return app, nil
+}
+
+// Apps returns all apps ordered by id
+func (s *Storage) Apps(ctx context.Context) ([]models.App, error) {
+	const op = "storage.sqlite.Apps"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, secret FROM apps ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var apps []models.App
+	for rows.Next() {
+		var app models.App
+		if err := rows.Scan(&app.Id, &app.Name, &app.Secret); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return apps, nil
+}
+
+// SaveApp stores a new app, returns storage.ErrAppExists if the name or the secret is taken
+func (s *Storage) SaveApp(ctx context.Context, name string, secret string) (int, error) {
+	const op = "storage.sqlite.SaveApp"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer s.observe(op, time.Now())
+
+	res, err := s.db.ExecContext(ctx, `INSERT INTO apps (name, secret) VALUES (?, ?)`, name, secret)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return int(id), nil
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	var metadata string
+	var createdAt, updatedAt sql.NullTime
+
+	err := row.Scan(
+		&user.Id,
+		&user.Email,
+		&user.PassHash,
+		&user.PepperVersion,
+		&user.IsAdmin,
+		&user.Status,
+		&user.DisplayName,
+		&user.Locale,
+		&user.Timezone,
+		&user.AvatarURL,
+		&metadata,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user.Metadata = []byte(metadata)
+	user.CreatedAt = createdAt.Time
+	user.UpdatedAt = updatedAt.Time
+	return user, nil
+}
+
+// affectedOne returns storage.ErrUserNotFound if the statement did not change any user
+func affectedOne(op string, res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	return nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
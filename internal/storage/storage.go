@@ -6,4 +6,12 @@ var (
 	ErrUserExists   = errors.New("User already exists")
 	ErrUserNotFound = errors.New("User not found")
 	ErrAppNotFound  = errors.New("App not found")
+	ErrAppExists    = errors.New("App already exists")
+
+	ErrSessionNotFound = errors.New("Session not found")
+
+	ErrAuditEventNotFound = errors.New("Audit event not found")
+	ErrCheckpointNotFound = errors.New("Audit checkpoint not found")
+
+	ErrNotMigrated = errors.New("Database schema is not migrated")
 )
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantErr  string
+		records  bool
+	}{
+		{name: "default is none"},
+		{name: "none", exporter: ExporterNone},
+		{name: "file", exporter: ExporterFile, records: true},
+		{name: "unknown", exporter: "jaeger", wantErr: `unknown exporter "jaeger"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "spans.json")
+
+			provider, err := Setup(ctx, Options{
+				ServiceName: "auth-test",
+				Exporter:    tt.exporter,
+				FilePath:    path,
+				SampleRatio: 1,
+			})
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			_, span := otel.Tracer("test").Start(ctx, "test-span")
+			span.End()
+			require.NoError(t, provider.Shutdown(ctx))
+
+			data, err := os.ReadFile(path)
+			if !tt.records {
+				require.ErrorIs(t, err, os.ErrNotExist)
+				return
+			}
+			require.NoError(t, err)
+			// spans are exported on shutdown with the service name of the resource
+			require.Contains(t, string(data), `"Name":"test-span"`)
+			require.Contains(t, string(data), `"Value":"auth-test"`)
+		})
+	}
+}
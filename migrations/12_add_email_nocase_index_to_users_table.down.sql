@@ -0,0 +1,2 @@
+-- the original case of emails is not restored, lower-cased emails are valid for the previous version too
+DROP INDEX IF EXISTS idx_users_email_nocase;
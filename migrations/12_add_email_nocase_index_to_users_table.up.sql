@@ -0,0 +1,5 @@
+-- fails if emails differing only in case exist, run cmd/emaildedup to find them
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_nocase ON users (email COLLATE NOCASE);
+-- the index guarantees that lower-casing ASCII letters creates no duplicates. SQLite lower() folds
+-- only ASCII, emails that differ from the canonical form in other ways are rewritten by cmd/emaildedup -backfill
+UPDATE users SET email = lower(email) WHERE email != lower(email);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_sessions_revoked_at;
+DROP INDEX IF EXISTS idx_sessions_expires_at;
+-- events written since the up migration lose their client and can no longer be verified by auditverify
+ALTER TABLE audit_events DROP COLUMN client_digest;
+DROP TABLE IF EXISTS audit_event_clients;
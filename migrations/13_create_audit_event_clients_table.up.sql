@@ -0,0 +1,21 @@
+-- audit_events is append-only and its hash chain covers every column, so the client of an event
+-- (IP address and user agent) is kept in a separate table that the erasure job can delete from.
+-- The chain covers a salted digest of the client instead, see audit.ClientDigest.
+-- Events written before this migration keep the client in audit_events and can not be scrubbed.
+CREATE TABLE IF NOT EXISTS audit_event_clients
+(
+    event_id   INTEGER PRIMARY KEY,
+    actor_id   INTEGER NOT NULL DEFAULT 0,
+    subject_id INTEGER NOT NULL DEFAULT 0,
+    ip         TEXT    NOT NULL DEFAULT '',
+    user_agent TEXT    NOT NULL DEFAULT '',
+    salt       BLOB    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_event_clients_actor_id ON audit_event_clients (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_event_clients_subject_id ON audit_event_clients (subject_id);
+
+ALTER TABLE audit_events ADD COLUMN client_digest TEXT NOT NULL DEFAULT '';
+
+-- ended sessions are pruned by the erasure job
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_revoked_at ON sessions (revoked_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_deletion_requested_at;
+ALTER TABLE users DROP COLUMN deletion_requested_at;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN deletion_requested_at DATETIME;
+CREATE INDEX IF NOT EXISTS idx_users_deletion_requested_at ON users (status, deletion_requested_at);
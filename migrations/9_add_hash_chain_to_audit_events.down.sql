@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS audit_checkpoints_no_delete;
+DROP TRIGGER IF EXISTS audit_checkpoints_no_update;
+DROP TABLE IF EXISTS audit_checkpoints;
+ALTER TABLE audit_events DROP COLUMN hash;
+ALTER TABLE audit_events DROP COLUMN prev_hash;
\ No newline at end of file
@@ -0,0 +1,24 @@
+-- events written before this migration stay unchained, auditverify reports them separately
+ALTER TABLE audit_events ADD COLUMN prev_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN hash TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS audit_checkpoints
+(
+    id         INTEGER PRIMARY KEY,
+    event_id   INTEGER  NOT NULL,
+    hash       TEXT     NOT NULL,
+    signature  BLOB     NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TRIGGER IF NOT EXISTS audit_checkpoints_no_update
+    BEFORE UPDATE ON audit_checkpoints
+BEGIN
+    SELECT RAISE(ABORT, 'audit_checkpoints is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_checkpoints_no_delete
+    BEFORE DELETE ON audit_checkpoints
+BEGIN
+    SELECT RAISE(ABORT, 'audit_checkpoints is append-only');
+END;
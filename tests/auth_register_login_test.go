@@ -109,6 +109,24 @@ func TestRegister_FailCases(t *testing.T) {
 			password:    "",
 			expectedErr: "email and password is required",
 		},
+		{
+			name:        "Register with Invalid Email",
+			email:       "not an email",
+			password:    randomFakePassword(),
+			expectedErr: "invalid email",
+		},
+		{
+			name:        "Register with Short Password",
+			email:       gofakeit.Email(),
+			password:    "a",
+			expectedErr: "password must be at least",
+		},
+		{
+			name:        "Register with Too Long Password",
+			email:       gofakeit.Email(),
+			password:    gofakeit.Password(true, true, true, true, false, 73),
+			expectedErr: "password must be at most 72 bytes",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"usekit-auth/tests/suite"
+)
+
+func TestGateway_RegisterLogin_HappyPath(t *testing.T) {
+	_, st := suite.New(t)
+	if st.Cfg.Gateway.Port == 0 {
+		t.Skip("gateway is disabled")
+	}
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	var registered struct {
+		UserId string `json:"user_id"`
+	}
+	code := postGateway(t, st, "/v1/auth/register", map[string]any{"email": email, "password": pass}, &registered)
+	require.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, registered.UserId)
+
+	var loggedIn struct {
+		Token string `json:"token"`
+	}
+	code = postGateway(t, st, "/v1/auth/login", map[string]any{"email": email, "password": pass, "app_id": appId}, &loggedIn)
+	require.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, loggedIn.Token)
+}
+
+func TestGateway_Register_Validation(t *testing.T) {
+	_, st := suite.New(t)
+	if st.Cfg.Gateway.Port == 0 {
+		t.Skip("gateway is disabled")
+	}
+
+	var status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	code := postGateway(t, st, "/v1/auth/register", map[string]any{"email": "", "password": ""}, &status)
+	require.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, 3, status.Code) // InvalidArgument
+	assert.Contains(t, status.Message, "email and password is required")
+}
+
+func TestGateway_GetUpdateUser(t *testing.T) {
+	_, st := suite.New(t)
+	if st.Cfg.Gateway.Port == 0 {
+		t.Skip("gateway is disabled")
+	}
+	email := gofakeit.Email()
+	userId, token := registerLoginGateway(t, st, email)
+	otherId, _ := registerLoginGateway(t, st, gofakeit.Email())
+
+	var user struct {
+		User struct {
+			Id string `json:"id"`
+		} `json:"user"`
+	}
+	code := callGateway(t, st, http.MethodGet, "/v1/users/"+userId, token, nil, &user)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, userId, user.User.Id)
+
+	var status struct {
+		Code int `json:"code"`
+	}
+	code = callGateway(t, st, http.MethodGet, "/v1/users/"+userId, "", nil, &status)
+	require.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, 16, status.Code) // Unauthenticated
+
+	code = callGateway(t, st, http.MethodGet, "/v1/users/"+otherId, token, nil, &status)
+	require.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, 7, status.Code) // PermissionDenied
+
+	code = callGateway(t, st, http.MethodGet, "/v1/users/by-email?email="+url.QueryEscape(email), token, nil, &user)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, userId, user.User.Id)
+
+	var updated struct {
+		User struct {
+			DisplayName string `json:"display_name"`
+		} `json:"user"`
+	}
+	// field mask paths are lowerCamelCase in JSON
+	code = callGateway(t, st, http.MethodPatch, "/v1/users/"+userId, token,
+		map[string]any{"user": map[string]any{"display_name": "Gateway User"}, "update_mask": "displayName"}, &updated)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Gateway User", updated.User.DisplayName)
+
+	var export struct {
+		Archive string `json:"archive"`
+	}
+	code = callGateway(t, st, http.MethodGet, "/v1/users/"+userId+"/export", token, nil, &export)
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, export.Archive, email)
+
+	code = callGateway(t, st, http.MethodGet, "/v1/users/"+otherId+"/export", token, nil, &status)
+	require.Equal(t, http.StatusForbidden, code)
+}
+
+func TestGateway_AdminRoutes_NotAdmin(t *testing.T) {
+	_, st := suite.New(t)
+	if st.Cfg.Gateway.Port == 0 {
+		t.Skip("gateway is disabled")
+	}
+	_, token := registerLoginGateway(t, st, gofakeit.Email())
+	otherId, _ := registerLoginGateway(t, st, gofakeit.Email())
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodGet, path: "/v1/users?role=admin"},
+		{method: http.MethodPost, path: "/v1/users/" + otherId + "/disable"},
+		{method: http.MethodPost, path: "/v1/users/" + otherId + "/enable"},
+		{method: http.MethodDelete, path: "/v1/users/" + otherId},
+		{method: http.MethodGet, path: "/v1/audit-events?outcome=failure&since=2026-01-01T00:00:00Z"},
+	}
+
+	for _, route := range routes {
+		var status struct {
+			Code int `json:"code"`
+		}
+		code := callGateway(t, st, route.method, route.path, token, nil, &status)
+		require.Equal(t, http.StatusForbidden, code, "%s %s", route.method, route.path)
+		assert.Equal(t, 7, status.Code) // PermissionDenied
+
+		code = callGateway(t, st, route.method, route.path, "", nil, &status)
+		require.Equal(t, http.StatusUnauthorized, code, "%s %s", route.method, route.path)
+	}
+}
+
+// registerLoginGateway registers a user with email and returns the user id and a token
+func registerLoginGateway(t *testing.T, st *suite.Suite, email string) (string, string) {
+	t.Helper()
+	pass := randomFakePassword()
+
+	var registered struct {
+		UserId string `json:"user_id"`
+	}
+	code := postGateway(t, st, "/v1/auth/register", map[string]any{"email": email, "password": pass}, &registered)
+	require.Equal(t, http.StatusOK, code)
+
+	var loggedIn struct {
+		Token string `json:"token"`
+	}
+	code = postGateway(t, st, "/v1/auth/login", map[string]any{"email": email, "password": pass, "app_id": appId}, &loggedIn)
+	require.Equal(t, http.StatusOK, code)
+
+	return registered.UserId, loggedIn.Token
+}
+
+func postGateway(t *testing.T, st *suite.Suite, path string, body any, out any) int {
+	t.Helper()
+	return callGateway(t, st, http.MethodPost, path, "", body, out)
+}
+
+// callGateway sends body as JSON with the bearer token if it is not empty and decodes the response into out
+func callGateway(t *testing.T, st *suite.Suite, method, path, token string, body any, out any) int {
+	t.Helper()
+
+	var payload io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		require.NoError(t, err)
+		payload = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, st.GatewayURL+path, payload)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := st.GatewayClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	return resp.StatusCode
+}
@@ -0,0 +1,30 @@
+package tests
+
+import (
+	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"testing"
+	"usekit-auth/tests/suite"
+)
+
+func TestHealth_Serving(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	for _, service := range []string{"", authv1.Auth_ServiceDesc.ServiceName} {
+		resp, err := st.HealthClient.Check(ctx, &healthv1.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		assert.Equal(t, healthv1.HealthCheckResponse_SERVING, resp.GetStatus(), "service %q", service)
+	}
+}
+
+func TestHealth_UnknownService(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.HealthClient.Check(ctx, &healthv1.HealthCheckRequest{Service: "unknown.Service"})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"usekit-auth/tests/suite"
+)
+
+func withBearer(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestSessions_RevokedTokenIsRejected(t *testing.T) {
+	ctx, st := suite.New(t)
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+	userId := respReg.GetUserId()
+
+	var tokens []string
+	for range 2 {
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: pass, AppId: appId})
+		require.NoError(t, err)
+		tokens = append(tokens, respLogin.GetToken())
+	}
+	token, revokedToken := tokens[0], tokens[1]
+
+	_, err = st.AuthClient.IsAdmin(withBearer(ctx, revokedToken), &authv1.IsAdminRequest{UserId: userId})
+	require.NoError(t, err)
+
+	respSessions, err := st.AuthClient.ListSessions(withBearer(ctx, token), &authv1.ListSessionsRequest{})
+	require.NoError(t, err)
+	require.Len(t, respSessions.GetSessions(), 2)
+
+	var revokedId string
+	for _, session := range respSessions.GetSessions() {
+		if !session.GetCurrent() {
+			revokedId = session.GetId()
+		}
+	}
+	require.NotEmpty(t, revokedId)
+
+	_, err = st.AuthClient.RevokeSession(withBearer(ctx, token), &authv1.RevokeSessionRequest{SessionId: revokedId})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.IsAdmin(withBearer(ctx, revokedToken), &authv1.IsAdminRequest{UserId: userId})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = st.AuthClient.GetUser(withBearer(ctx, revokedToken), &authv1.GetUserRequest{UserId: userId})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = st.AuthClient.ListSessions(withBearer(ctx, revokedToken), &authv1.ListSessionsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// the session that revoked the other one keeps working
+	respIsAdmin, err := st.AuthClient.IsAdmin(withBearer(ctx, token), &authv1.IsAdminRequest{UserId: userId})
+	require.NoError(t, err)
+	assert.False(t, respIsAdmin.GetIsAdmin())
+}
+
+func TestGateway_RevokeOtherSessions(t *testing.T) {
+	_, st := suite.New(t)
+	if st.Cfg.Gateway.Port == 0 {
+		t.Skip("gateway is disabled")
+	}
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	var registered struct {
+		UserId string `json:"user_id"`
+	}
+	code := postGateway(t, st, "/v1/auth/register", map[string]any{"email": email, "password": pass}, &registered)
+	require.Equal(t, http.StatusOK, code)
+
+	var tokens []string
+	for range 2 {
+		var loggedIn struct {
+			Token string `json:"token"`
+		}
+		code = postGateway(t, st, "/v1/auth/login", map[string]any{"email": email, "password": pass, "app_id": appId}, &loggedIn)
+		require.Equal(t, http.StatusOK, code)
+		tokens = append(tokens, loggedIn.Token)
+	}
+	token, otherToken := tokens[0], tokens[1]
+
+	var revoked struct {
+		Revoked string `json:"revoked"`
+	}
+	code = callGateway(t, st, http.MethodPost, "/v1/sessions/revoke-others", token, nil, &revoked)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "1", revoked.Revoked) // int64 is a string in JSON
+
+	var status struct {
+		Code int `json:"code"`
+	}
+	code = callGateway(t, st, http.MethodGet, "/v1/users/"+registered.UserId+"/is-admin", otherToken, nil, &status)
+	require.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, 16, status.Code) // Unauthenticated
+
+	var sessions struct {
+		Sessions []struct {
+			Current bool `json:"current"`
+		} `json:"sessions"`
+	}
+	code = callGateway(t, st, http.MethodGet, "/v1/sessions", token, nil, &sessions)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, sessions.Sessions, 1)
+	assert.True(t, sessions.Sessions[0].Current)
+}
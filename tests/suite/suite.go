@@ -2,10 +2,17 @@ package suite
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	authv1 "github.com/moon-light-night/usekit-proto/gen/go/auth.v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
 	"net"
+	"net/http"
+	"os"
 	"strconv"
 	"testing"
 	"usekit-auth/internal/config"
@@ -16,9 +23,13 @@ const (
 )
 
 type Suite struct {
-	*testing.T                   // instance of object for executing testing functions inside test suite
-	Cfg        *config.Config    // app config
-	AuthClient authv1.AuthClient // client for interaction with grpc server
+	*testing.T                         // instance of object for executing testing functions inside test suite
+	Cfg          *config.Config        // app config
+	AuthClient   authv1.AuthClient     // client for interaction with grpc server
+	HealthClient healthv1.HealthClient // client for the standard health service
+	// GatewayClient and GatewayURL reach the HTTP/JSON gateway, with TLS when the gRPC server uses it
+	GatewayClient *http.Client
+	GatewayURL    string
 }
 
 func New(t *testing.T) (context.Context, *Suite) {
@@ -36,18 +47,66 @@ func New(t *testing.T) (context.Context, *Suite) {
 		cancelContext()
 	})
 
+	tlsConfig, err := clientTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("grpc tls config failed: %v", err)
+	}
+	creds := insecure.NewCredentials()
+	gatewayScheme := "http"
+	gatewayClient := &http.Client{}
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+		gatewayScheme = "https"
+		gatewayClient.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
 	cc, err := grpc.DialContext(context.Background(),
 		grpcAddress(cfg),
-		grpc.WithTransportCredentials(insecure.NewCredentials())) // use insecure connection for tests
+		grpc.WithTransportCredentials(creds))
 	if err != nil {
 		t.Fatalf("grpc server connection failed: %v:", err)
 	}
 
 	return ctx, &Suite{
-		T:          t,
-		Cfg:        cfg,
-		AuthClient: authv1.NewAuthClient(cc),
+		T:             t,
+		Cfg:           cfg,
+		AuthClient:    authv1.NewAuthClient(cc),
+		HealthClient:  healthv1.NewHealthClient(cc),
+		GatewayClient: gatewayClient,
+		GatewayURL:    gatewayScheme + "://" + net.JoinHostPort(grpcHost, strconv.Itoa(cfg.Gateway.Port)),
+	}
+}
+
+// clientTLSConfig returns the client TLS config if the server is configured with TLS, nil otherwise.
+// TEST_TLS_CA_FILE is the CA the server certificate is verified with,
+// TEST_TLS_CERT_FILE and TEST_TLS_KEY_FILE are the client certificate for mutual TLS.
+func clientTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.GRPC.TLS.CertFile == "" {
+		return nil, nil
 	}
+
+	tlsConfig := &tls.Config{ServerName: grpcHost}
+
+	if caFile := os.Getenv("TEST_TLS_CA_FILE"); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+	}
+
+	if certFile := os.Getenv("TEST_TLS_CERT_FILE"); certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, os.Getenv("TEST_TLS_KEY_FILE"))
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
 }
 
 func grpcAddress(cfg *config.Config) string {